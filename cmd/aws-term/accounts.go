@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ysaakpr/aws-term/internal/cache"
+	"github.com/ysaakpr/aws-term/internal/config"
+	"github.com/ysaakpr/aws-term/internal/ui"
+)
+
+// accountListing is an account with its cached roles, as printed by "accounts list"
+type accountListing struct {
+	AccountId    string   `json:"account_id"`
+	AccountName  string   `json:"account_name"`
+	Alias        string   `json:"alias,omitempty"`
+	EmailAddress string   `json:"email_address,omitempty"`
+	Roles        []string `json:"roles"`
+}
+
+// runAccountsCommand handles "aws-term accounts <subcommand>"
+func runAccountsCommand(args []string) {
+	if len(args) == 0 || args[0] != "list" {
+		ui.PrintError("Usage: aws-term accounts list [options] [profile-name]")
+		exit(1)
+	}
+
+	fs := flag.NewFlagSet("accounts list", flag.ExitOnError)
+	format := fs.String("format", "table", "Output format: table, json or csv")
+	nameFilter := fs.String("name", "", "Only show accounts whose name or alias contains this text")
+	idFilter := fs.String("id", "", "Only show accounts whose ID contains this text")
+	emailFilter := fs.String("email", "", "Only show accounts whose email contains this text")
+	roleFilter := fs.String("role", "", "Only show roles whose name contains this text")
+	fs.Parse(args[1:])
+
+	cfg, err := config.Load()
+	if err != nil {
+		cfg = &config.Config{Profiles: []config.Profile{}}
+	}
+
+	profile, err := lookupProfile(cfg, fs.Arg(0))
+	if err != nil {
+		ui.PrintError(fmt.Sprintf("Failed to select profile: %v", err))
+		exit(1)
+	}
+
+	dir, err := cache.LoadDirectory(profile.SSOUrl)
+	if err != nil {
+		ui.PrintError(fmt.Sprintf("Failed to load account cache: %v", err))
+		exit(1)
+	}
+	if !dir.HasAccounts() {
+		ui.PrintError(fmt.Sprintf("No cached accounts for profile '%s'", profile.Name))
+		ui.PrintInfo(fmt.Sprintf("Run 'aws-term %s' once to populate the cache", profile.Name))
+		exit(1)
+	}
+
+	listings := filterAccounts(profile, dir, *nameFilter, *idFilter, *emailFilter, *roleFilter)
+
+	if jsonOutput {
+		writeResult("accounts", listings)
+		return
+	}
+
+	switch *format {
+	case "table":
+		if !dir.AccountsFresh(cache.DefaultAccountsTTL) {
+			ui.PrintInfo(fmt.Sprintf("Cached list is from %s; log in to refresh it.", dir.AccountsUpdatedAt.Local().Format(time.RFC1123)))
+		}
+		printAccountsTable(listings)
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(listings); err != nil {
+			ui.PrintError(fmt.Sprintf("Failed to write JSON: %v", err))
+			exit(1)
+		}
+	case "csv":
+		if err := writeAccountsCSV(listings); err != nil {
+			ui.PrintError(fmt.Sprintf("Failed to write CSV: %v", err))
+			exit(1)
+		}
+	default:
+		ui.PrintError(fmt.Sprintf("Unknown format '%s' (use table, json or csv)", *format))
+		exit(1)
+	}
+}
+
+// filterAccounts applies case-insensitive substring filters to the cached directory.
+// Roles come from the role cache regardless of its age, since this is an offline view.
+func filterAccounts(profile *config.Profile, dir *cache.Directory, name, id, email, role string) []accountListing {
+	contains := func(value, filter string) bool {
+		return filter == "" || strings.Contains(strings.ToLower(value), strings.ToLower(filter))
+	}
+
+	listings := []accountListing{}
+	for _, acc := range dir.Accounts {
+		alias, _ := profile.AliasFor(acc.AccountId)
+		nameMatches := contains(acc.AccountName, name) || (alias.Name != "" && contains(alias.Name, name))
+		if !nameMatches || !contains(acc.AccountId, id) || !contains(acc.EmailAddress, email) {
+			continue
+		}
+
+		roles := []string{}
+		for _, r := range dir.Roles[acc.AccountId] {
+			if contains(r.RoleName, role) {
+				roles = append(roles, r.RoleName)
+			}
+		}
+		if role != "" && len(roles) == 0 {
+			continue
+		}
+
+		listings = append(listings, accountListing{
+			AccountId:    acc.AccountId,
+			AccountName:  acc.AccountName,
+			Alias:        alias.Name,
+			EmailAddress: acc.EmailAddress,
+			Roles:        roles,
+		})
+	}
+	return listings
+}
+
+func printAccountsTable(listings []accountListing) {
+	if len(listings) == 0 {
+		ui.PrintInfo("No matching accounts.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ACCOUNT ID\tNAME\tALIAS\tEMAIL\tROLES")
+	for _, l := range listings {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", l.AccountId, l.AccountName, l.Alias, l.EmailAddress, strings.Join(l.Roles, ", "))
+	}
+	w.Flush()
+}
+
+// writeAccountsCSV writes one row per account/role pair so the output can be
+// filtered with standard tools. Accounts without cached roles get an empty role.
+func writeAccountsCSV(listings []accountListing) error {
+	w := csv.NewWriter(os.Stdout)
+	if err := w.Write([]string{"account_id", "account_name", "alias", "email_address", "role_name"}); err != nil {
+		return err
+	}
+	for _, l := range listings {
+		roles := l.Roles
+		if len(roles) == 0 {
+			roles = []string{""}
+		}
+		for _, r := range roles {
+			if err := w.Write([]string{l.AccountId, l.AccountName, l.Alias, l.EmailAddress, r}); err != nil {
+				return err
+			}
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// lookupProfile finds a profile by name, falling back to the default profile
+// or the only configured profile when no name is given
+func lookupProfile(cfg *config.Config, name string) (*config.Profile, error) {
+	if name != "" {
+		if p := cfg.GetProfileByName(name); p != nil {
+			return p, nil
+		}
+		return nil, fmt.Errorf("profile '%s' not found", name)
+	}
+
+	if p := cfg.GetDefaultProfile(); p != nil {
+		return p, nil
+	}
+	if len(cfg.Profiles) == 1 {
+		return &cfg.Profiles[0], nil
+	}
+	if len(cfg.Profiles) == 0 {
+		return nil, errors.New("no profiles configured, use --add to create one")
+	}
+	return nil, errors.New("multiple profiles configured, specify one by name")
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/ysaakpr/aws-term/internal/clipboard"
+)
+
+const (
+	// defaultClipboardTimeout is how long copied credentials stay on the clipboard
+	defaultClipboardTimeout = 45 * time.Second
+
+	// clipboardFingerprintEnv passes the fingerprint of copied credentials to
+	// the process that clears them, so the secret is not on its command line
+	clipboardFingerprintEnv = "AWS_TERM_CLIPBOARD_FINGERPRINT"
+)
+
+// errOSC52Clear refuses to copy with OSC 52 when the copy should be cleared:
+// the clear has to go through this process's terminal, and aws-term exits
+// long before the timeout
+var errOSC52Clear = errors.New("OSC 52 copies cannot be cleared after aws-term exits")
+
+// scheduleClear clears the clipboard after timeout if it still holds the text
+// with the given fingerprint. Clipboard tools work from any process, so a
+// detached aws-term clears the clipboard even after this one has exited.
+// Tests replace it.
+var scheduleClear = func(timeout time.Duration, fingerprint string) error {
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to schedule clipboard clear: %w", err)
+	}
+	cmd := exec.Command(self, "clipboard-clear", timeout.String())
+	cmd.Env = append(os.Environ(), clipboardFingerprintEnv+"="+fingerprint)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to schedule clipboard clear: %w", err)
+	}
+	return cmd.Process.Release()
+}
+
+// copySecret copies text and clears it from the clipboard after timeout,
+// unless something else has been copied since. A zero timeout keeps it.
+// With OSC 52 it only copies text that is kept, returning errOSC52Clear
+// otherwise.
+func copySecret(b clipboard.Backend, text string, timeout time.Duration) error {
+	if _, ok := b.(*clipboard.OSC52); ok && timeout > 0 {
+		return errOSC52Clear
+	}
+
+	if err := b.Copy(text); err != nil {
+		return err
+	}
+	if timeout <= 0 {
+		return nil
+	}
+	return scheduleClear(timeout, clipboard.Fingerprint(text))
+}
+
+// runClipboardClearCommand handles the internal "aws-term clipboard-clear
+// DURATION" started by copySecret: it waits, then clears the clipboard if it
+// still holds the copied credentials
+func runClipboardClearCommand(args []string) {
+	fingerprint := os.Getenv(clipboardFingerprintEnv)
+	if len(args) != 1 || fingerprint == "" {
+		os.Exit(1)
+	}
+	wait, err := time.ParseDuration(args[0])
+	if err != nil {
+		os.Exit(1)
+	}
+
+	time.Sleep(wait)
+
+	b, err := clipboard.Detect()
+	if err != nil {
+		os.Exit(1)
+	}
+	if err := clipboard.ClearIf(b, fingerprint); err != nil {
+		os.Exit(1)
+	}
+}
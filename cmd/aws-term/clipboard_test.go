@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ysaakpr/aws-term/internal/clipboard"
+)
+
+// memoryClipboard is a Backend and Reader holding the clipboard in memory
+type memoryClipboard struct {
+	text string
+}
+
+func (m *memoryClipboard) Name() string { return "memory" }
+
+func (m *memoryClipboard) Copy(text string) error {
+	m.text = text
+	return nil
+}
+
+func (m *memoryClipboard) Paste() (string, error) {
+	return m.text, nil
+}
+
+// runClearsNow replaces scheduleClear with one that clears b straight away,
+// recording the timeout it was scheduled for
+func runClearsNow(t *testing.T, b clipboard.Backend) *time.Duration {
+	t.Helper()
+	var scheduled time.Duration
+	saved := scheduleClear
+	scheduleClear = func(timeout time.Duration, fingerprint string) error {
+		scheduled = timeout
+		return clipboard.ClearIf(b, fingerprint)
+	}
+	t.Cleanup(func() { scheduleClear = saved })
+	return &scheduled
+}
+
+func TestCopySecretClears(t *testing.T) {
+	b := &memoryClipboard{}
+	scheduled := runClearsNow(t, b)
+
+	if err := copySecret(b, "export AWS_SESSION_TOKEN=secret", time.Minute); err != nil {
+		t.Fatalf("copySecret() error = %v", err)
+	}
+	if *scheduled != time.Minute {
+		t.Errorf("clear scheduled after %s, want 1m", *scheduled)
+	}
+	if b.text != "" {
+		t.Errorf("clipboard = %q after the clear, want it empty", b.text)
+	}
+
+	// A zero timeout keeps the copy
+	*scheduled = 0
+	if err := copySecret(b, "kept", 0); err != nil {
+		t.Fatalf("copySecret() error = %v", err)
+	}
+	if *scheduled != 0 || b.text != "kept" {
+		t.Errorf("clipboard = %q, cleared after %s; want it kept", b.text, *scheduled)
+	}
+}
+
+func TestCopySecretOSC52(t *testing.T) {
+	var out bytes.Buffer
+	b := &clipboard.OSC52{Out: &out}
+	scheduled := runClearsNow(t, b)
+
+	// The clear could not be sent once aws-term has exited, so nothing is copied
+	if err := copySecret(b, "secret", time.Minute); !errors.Is(err, errOSC52Clear) {
+		t.Fatalf("copySecret() error = %v, want %v", err, errOSC52Clear)
+	}
+	if out.Len() != 0 || *scheduled != 0 {
+		t.Errorf("copySecret() wrote %q and scheduled a clear after %s, want neither", out.String(), *scheduled)
+	}
+
+	if err := copySecret(b, "secret", 0); err != nil {
+		t.Fatalf("copySecret() without a timeout error = %v", err)
+	}
+	if out.Len() == 0 {
+		t.Error("copySecret() without a timeout did not copy")
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ysaakpr/aws-term/internal/browser"
+	"github.com/ysaakpr/aws-term/internal/config"
+	"github.com/ysaakpr/aws-term/internal/console"
+	"github.com/ysaakpr/aws-term/internal/ui"
+)
+
+// runConsoleCommand handles "aws-term console": it signs in, picks a role and
+// opens the AWS web console with that role's credentials
+func runConsoleCommand(args []string) {
+	fs := flag.NewFlagSet("console", flag.ExitOnError)
+	sessionOpts := addSessionFlags(fs)
+	service := fs.String("service", "", "Console service to open, e.g. s3 or ec2, or a full console URL")
+	consoleRegion := fs.String("console-region", "", "Region to open the console in")
+	federationURL := fs.String("federation-url", "", "Sign-in federation endpoint (default: "+console.DefaultFederationURL+")")
+	isolate := fs.String("isolate", "", "Open each account in its own browser context: firefox-container, chrome or none")
+	printURL := fs.Bool("print", false, "Print the sign-in URL instead of opening a browser")
+	fs.Parse(args)
+
+	ui.PrintHeader()
+
+	cfg, err := config.Load()
+	if err != nil {
+		cfg = &config.Config{Profiles: []config.Profile{}}
+	}
+
+	profile := selectProfile(cfg, fs.Arg(0))
+
+	// Flags override the profile's console settings
+	settings := config.ConsoleSettings{}
+	if profile.Console != nil {
+		settings = *profile.Console
+	}
+	if *service != "" {
+		settings.Service = *service
+	}
+	if *consoleRegion != "" {
+		settings.Region = *consoleRegion
+	}
+	if *federationURL != "" {
+		settings.FederationURL = *federationURL
+	}
+	if *isolate != "" {
+		settings.Isolation = *isolate
+	}
+	if err := console.ValidateIsolation(settings.Isolation); err != nil {
+		ui.PrintError(err.Error())
+		exit(1)
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	sess := startSession(ctx, profile, sessionOpts)
+
+	ui.PrintInfo("Creating console sign-in URL...")
+	client := console.NewClient(settings.FederationURL)
+	loginURL, err := client.LoginURL(ctx, sess.Credentials, console.Destination(settings.Service, settings.Region))
+	if err != nil {
+		exitIfCancelled(err)
+		ui.PrintError(fmt.Sprintf("Failed to create console sign-in URL: %v", err))
+		exit(1)
+	}
+
+	// A cached login skips the browser picker, so choose one now
+	if sess.Browser == "" && !*printURL {
+		sess.Browser = loginBrowser(profile, sessionOpts)
+	}
+
+	if *printURL || sess.Browser == "" {
+		if jsonOutput {
+			writeResult("console", newConsoleResult(sess, loginURL, false))
+			return
+		}
+		if !*printURL {
+			ui.PrintInfo("No browser available, open this URL to sign in (valid for 15 minutes):")
+		}
+		fmt.Println(loginURL)
+		return
+	}
+
+	browserName, openURL, opts, err := isolatedLaunch(sess, settings, loginURL)
+	if err != nil {
+		ui.PrintError(fmt.Sprintf("Failed to prepare browser context: %v", err))
+		exit(1)
+	}
+
+	if err := browser.OpenURLWithOptions(browserName, openURL, opts); err != nil {
+		ui.PrintError(fmt.Sprintf("Failed to open browser: %v", err))
+		ui.PrintInfo("Open this URL to sign in (valid for 15 minutes):")
+		fmt.Println(loginURL)
+		exit(1)
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Opened the AWS console for %s as %s", sess.Label(), sess.Role.RoleName))
+	writeResult("console", newConsoleResult(sess, loginURL, true))
+}
+
+// isolatedLaunch returns the browser, URL and launch options that open the
+// console sign-in URL in the account's own browser context, when the profile
+// asks for one. Otherwise the login browser is used unchanged.
+func isolatedLaunch(sess *session, settings config.ConsoleSettings, loginURL string) (string, string, browser.Options, error) {
+	name, opts := sess.Browser, sess.BrowserOptions
+	accountId := sess.Account.AccountId
+
+	switch settings.Isolation {
+	case console.IsolationFirefoxContainer:
+		if !strings.HasSuffix(name, "Firefox") {
+			name = "Firefox"
+		}
+		// Containers are not available in private windows
+		opts.Private = false
+
+		alias, _ := sess.Profile.AliasFor(accountId)
+		displayName := sess.Profile.AccountDisplayName(accountId, sess.Account.AccountName)
+		container := console.ContainerName(displayName)
+		ui.PrintInfo(fmt.Sprintf("Opening in Firefox container '%s'", container))
+		return name, console.ContainerURL(container, console.ContainerColor(accountId, alias.Color, sess.IsProduction), loginURL), opts, nil
+
+	case console.IsolationChrome:
+		// A Windows browser started from WSL cannot use a data directory
+		// inside the Linux file system
+		if browser.IsWindowsBrowser(name) {
+			return "", "", opts, fmt.Errorf("chrome isolation needs a Linux browser, not %s: pick one with --choose-browser or use firefox-container isolation", name)
+		}
+		switch name {
+		case "Chrome", "Chromium", "Brave", "Edge", "Vivaldi", "Opera":
+		default:
+			name = "Chrome"
+		}
+
+		baseDir := settings.ChromeDataDir
+		if baseDir == "" {
+			stateDir, err := config.GetStateDir()
+			if err != nil {
+				return "", "", opts, err
+			}
+			baseDir = stateDir
+		}
+		dataDir := console.ChromeUserDataDir(baseDir, accountId)
+		if err := os.MkdirAll(dataDir, 0700); err != nil {
+			return "", "", opts, fmt.Errorf("failed to create Chrome data directory: %w", err)
+		}
+
+		// A profile directory belongs to the default user data directory
+		opts.ChromeProfile = ""
+		opts.UserDataDir = dataDir
+		ui.PrintInfo(fmt.Sprintf("Opening in Chrome with data directory %s", dataDir))
+	}
+
+	return name, loginURL, opts, nil
+}
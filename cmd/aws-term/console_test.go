@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ysaakpr/aws-term/internal/config"
+	"github.com/ysaakpr/aws-term/internal/console"
+	"github.com/ysaakpr/aws-term/internal/sso"
+)
+
+func TestIsolatedLaunchChrome(t *testing.T) {
+	settings := config.ConsoleSettings{Isolation: console.IsolationChrome, ChromeDataDir: t.TempDir()}
+
+	tests := []struct {
+		browser string
+		want    string
+		wantErr bool
+	}{
+		{browser: "Brave", want: "Brave"},
+		{browser: "Firefox", want: "Chrome"},
+		{browser: "Windows Chrome", wantErr: true},
+		{browser: "Windows default", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.browser, func(t *testing.T) {
+			sess := &session{Profile: &config.Profile{}, Account: &sso.Account{AccountId: "111111111111"}, Browser: tt.browser}
+
+			name, _, opts, err := isolatedLaunch(sess, settings, "https://signin.aws.amazon.com/federation")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("isolatedLaunch() with %s succeeded", tt.browser)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("isolatedLaunch() error = %v", err)
+			}
+			if name != tt.want {
+				t.Errorf("browser = %s, want %s", name, tt.want)
+			}
+			if !strings.HasPrefix(opts.UserDataDir, settings.ChromeDataDir) {
+				t.Errorf("UserDataDir = %s, want it under %s", opts.UserDataDir, settings.ChromeDataDir)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ysaakpr/aws-term/internal/cache"
+	"github.com/ysaakpr/aws-term/internal/config"
+	"github.com/ysaakpr/aws-term/internal/ecr"
+)
+
+const (
+	// dockerHelperPrefix is the executable name prefix Docker looks for credential helpers under
+	dockerHelperPrefix = "docker-credential-"
+
+	// errCredentialsNotFound tells Docker to fall back to its own credential store
+	errCredentialsNotFound = "credentials not found in native keychain"
+)
+
+// dockerCredentials is the credential helper protocol's response to "get"
+type dockerCredentials struct {
+	ServerURL string `json:"ServerURL"`
+	Username  string `json:"Username"`
+	Secret    string `json:"Secret"`
+}
+
+// runDockerCredentialCommand implements the Docker credential helper protocol
+// for ECR registries: "get", "list", "store" and "erase" read their input on
+// stdin and write JSON to stdout. It runs as "aws-term docker-credential" or
+// through a docker-credential-aws-term link to the binary.
+func runDockerCredentialCommand(args []string) {
+	// The protocol owns stdout: responses and errors go there for Docker,
+	// everything else, including any login prompts, goes to stderr
+	out := os.Stdout
+	os.Stdout = os.Stderr
+	// Starting the session may exit on an error, which must reach Docker too
+	helperOut = out
+
+	fail := func(msg string) {
+		fmt.Fprintln(out, msg)
+		os.Exit(1)
+	}
+
+	if len(args) != 1 {
+		fail("Usage: aws-term docker-credential get|list|store|erase")
+	}
+
+	switch args[0] {
+	case "get":
+		serverURL, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			fail(fmt.Sprintf("failed to read server URL: %v", err))
+		}
+		serverURL = strings.TrimSpace(serverURL)
+
+		creds, err := ecrCredentials(serverURL)
+		if err != nil {
+			fail(err.Error())
+		}
+		if err := json.NewEncoder(out).Encode(creds); err != nil {
+			fail(fmt.Sprintf("failed to write credentials: %v", err))
+		}
+
+	case "list":
+		if err := json.NewEncoder(out).Encode(configuredRegistries()); err != nil {
+			fail(fmt.Sprintf("failed to write registries: %v", err))
+		}
+
+	case "store", "erase":
+		// ECR logins are issued on demand, so there is nothing to store or erase
+		io.Copy(io.Discard, os.Stdin)
+
+	default:
+		fail(fmt.Sprintf("unknown credential helper action '%s'", args[0]))
+	}
+}
+
+// ecrCredentials starts a session with the target configured for the
+// registry and returns an ECR login for it. Registries that are not ECR, or
+// have no target, get the not-found error so Docker keeps looking elsewhere.
+func ecrCredentials(serverURL string) (*dockerCredentials, error) {
+	registry, ok := ecr.ParseRegistry(serverURL)
+	if !ok {
+		return nil, errors.New(errCredentialsNotFound)
+	}
+
+	profile, err := dockerHelperProfile()
+	if err != nil {
+		return nil, err
+	}
+
+	target := os.Getenv("AWS_TERM_TARGET")
+	if target == "" {
+		target = profile.ECRTarget(registry.Host, registry.AccountId)
+	}
+	if target == "" {
+		return nil, errors.New(errCredentialsNotFound)
+	}
+
+	fs := flag.NewFlagSet("docker-credential", flag.ContinueOnError)
+	sessionOpts := addSessionFlags(fs)
+	fs.Parse([]string{"--target", target})
+
+	// Docker runs the helper without a terminal, so it cannot sign in: it
+	// needs cached credentials or a cached SSO login
+	if !signedIn(profile, sessionOpts) {
+		return nil, fmt.Errorf("not signed in to profile '%s': run aws-term to sign in", profile.Name)
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	sess := startCachedSession(ctx, profile, sessionOpts)
+
+	endpoint := ""
+	if profile.ECR != nil {
+		endpoint = profile.ECR.Endpoint
+	}
+	auth, err := ecr.GetAuthorization(ctx, sess.Credentials, registry.Region, endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dockerCredentials{
+		ServerURL: serverURL,
+		Username:  auth.Username,
+		Secret:    auth.Password,
+	}, nil
+}
+
+// signedIn reports whether credentials for the selection are cached, or the
+// SSO login is, so a session can start without signing in
+func signedIn(profile *config.Profile, flags *sessionFlags) bool {
+	if creds, err := cache.LoadRoleCredentials(profile.SSOUrl, credentialsKey(profile, flags)); err == nil && creds.Valid() {
+		return true
+	}
+	token, err := cache.LoadToken(profile.SSOUrl)
+	return err == nil && token.Valid(sessionRegion(profile, flags))
+}
+
+// dockerHelperProfile returns the profile named by AWS_TERM_PROFILE, or the default profile
+func dockerHelperProfile() (*config.Profile, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		cfg = &config.Config{Profiles: []config.Profile{}}
+	}
+	return lookupProfile(cfg, os.Getenv("AWS_TERM_PROFILE"))
+}
+
+// configuredRegistries lists the registry hostnames mapped to targets, in the
+// server URL → username form Docker expects from "list"
+func configuredRegistries() map[string]string {
+	registries := map[string]string{}
+
+	profile, err := dockerHelperProfile()
+	if err != nil || profile.ECR == nil {
+		return registries
+	}
+
+	for host := range profile.ECR.Registries {
+		if registry, ok := ecr.ParseRegistry(host); ok {
+			registries["https://"+registry.Host] = "AWS"
+		}
+	}
+	return registries
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ysaakpr/aws-term/internal/config"
+	"github.com/ysaakpr/aws-term/internal/eks"
+	"github.com/ysaakpr/aws-term/internal/ui"
+)
+
+// runEKSTokenCommand handles "aws-term eks-token": it prints a Kubernetes
+// ExecCredential with an EKS token for the selected role, for use as a kubectl
+// exec credential plugin
+func runEKSTokenCommand(args []string) {
+	fs := flag.NewFlagSet("eks-token", flag.ExitOnError)
+	sessionOpts := addSessionFlags(fs)
+	clusterName := fs.String("cluster", "", "Name of the EKS cluster")
+	clusterRegion := fs.String("cluster-region", "", "Region of the cluster (default: the SSO region)")
+	fs.Parse(args)
+
+	// kubectl reads the ExecCredential from stdout, so all other output,
+	// including any login prompts, goes to stderr
+	out := os.Stdout
+	os.Stdout = os.Stderr
+
+	if *clusterName == "" {
+		ui.PrintError("Usage: aws-term eks-token --cluster NAME [options] [profile-name]")
+		exit(1)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		cfg = &config.Config{Profiles: []config.Profile{}}
+	}
+
+	profile := selectProfile(cfg, fs.Arg(0))
+
+	ctx, stop := signalContext()
+	defer stop()
+	sess := startCachedSession(ctx, profile, sessionOpts)
+
+	region := *clusterRegion
+	if region == "" {
+		region = sess.Client.Region
+	}
+
+	token, err := eks.GetToken(ctx, sess.Credentials, *clusterName, region, profile.STSEndpoint)
+	if err != nil {
+		exitIfCancelled(err)
+		ui.PrintError(fmt.Sprintf("Failed to create EKS token: %v", err))
+		exit(1)
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(eks.NewExecCredential(token, os.Getenv("KUBERNETES_EXEC_INFO"))); err != nil {
+		ui.PrintError(fmt.Sprintf("Failed to write ExecCredential: %v", err))
+		exit(1)
+	}
+}
+
+// runKubeconfigCommand handles "aws-term kubeconfig": it adds a kubeconfig
+// user whose exec plugin runs "aws-term eks-token" for a cluster and target
+func runKubeconfigCommand(args []string) {
+	fs := flag.NewFlagSet("kubeconfig", flag.ExitOnError)
+	clusterName := fs.String("cluster", "", "Name of the EKS cluster")
+	clusterRegion := fs.String("cluster-region", "", "Region of the cluster (default: the SSO region)")
+	target := fs.String("target", "", "Target whose credentials are used for the cluster")
+	account := fs.String("account", "", "Account to use, by ID, name or alias (instead of --target)")
+	role := fs.String("role", "", "Role to use in the account (instead of --target)")
+	userName := fs.String("user", "", "Name of the kubeconfig user (default: aws-term-<profile>-<cluster>)")
+	contextName := fs.String("context", "", "Existing kubeconfig context to switch to the new user")
+	kubeconfigPath := fs.String("kubeconfig", "", "Kubeconfig file to update (default: $KUBECONFIG or ~/.kube/config)")
+	fs.Parse(args)
+
+	if *clusterName == "" {
+		ui.PrintError("Usage: aws-term kubeconfig --cluster NAME (--target NAME | --account ID --role NAME) [options] [profile-name]")
+		exit(1)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		cfg = &config.Config{Profiles: []config.Profile{}}
+	}
+
+	profile, err := lookupProfile(cfg, fs.Arg(0))
+	if err != nil {
+		ui.PrintError(fmt.Sprintf("Failed to select profile: %v", err))
+		exit(1)
+	}
+
+	// The plugin runs without a terminal most of the time, so the account and
+	// role have to be fixed up front
+	pluginArgs := []string{"eks-token", "--cluster", *clusterName}
+	if *clusterRegion != "" {
+		pluginArgs = append(pluginArgs, "--cluster-region", *clusterRegion)
+	}
+	switch {
+	case *target != "":
+		if _, err := profile.TargetByName(*target); err != nil {
+			ui.PrintError(err.Error())
+			exit(1)
+		}
+		pluginArgs = append(pluginArgs, "--target", *target)
+	case *account != "" && *role != "":
+		pluginArgs = append(pluginArgs, "--account", *account, "--role", *role)
+	default:
+		ui.PrintError("Specify --target, or both --account and --role")
+		exit(1)
+	}
+	pluginArgs = append(pluginArgs, profile.Name)
+
+	if *userName == "" {
+		*userName = fmt.Sprintf("aws-term-%s-%s", profile.Name, *clusterName)
+	}
+
+	// Prefer the absolute path so kubectl finds aws-term without PATH changes
+	command, err := os.Executable()
+	if err != nil {
+		command = "aws-term"
+	}
+
+	path := *kubeconfigPath
+	if path == "" {
+		path, err = eks.DefaultKubeconfigPath()
+		if err != nil {
+			ui.PrintError(err.Error())
+			exit(1)
+		}
+	}
+
+	user := eks.ExecUser{Name: *userName, Command: command, Args: pluginArgs}
+	if err := eks.WriteUser(path, user, *contextName); err != nil {
+		ui.PrintError(fmt.Sprintf("Failed to update kubeconfig: %v", err))
+		exit(1)
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Wrote user '%s' to %s", *userName, path))
+	writeResult("kubeconfig", kubeconfigResult{User: *userName, Path: path, Context: *contextName})
+	if *contextName != "" {
+		ui.PrintInfo(fmt.Sprintf("Context '%s' now uses '%s'", *contextName, *userName))
+	} else {
+		ui.PrintInfo(fmt.Sprintf("Use it with: kubectl config set-context <context> --user %s", *userName))
+	}
+}
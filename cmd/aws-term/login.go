@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ysaakpr/aws-term/internal/browser"
+	"github.com/ysaakpr/aws-term/internal/clipboard"
+	"github.com/ysaakpr/aws-term/internal/sso"
+	"github.com/ysaakpr/aws-term/internal/ui"
+)
+
+// plainStatusInterval is how often a "still waiting" line is printed while
+// waiting for sign-in without a terminal
+const plainStatusInterval = 30 * time.Second
+
+// loginDisplay shows the progress of an SSO sign-in. On a terminal, waiting
+// for approval is a live status line with shortcuts; otherwise a line is
+// printed every plainStatusInterval.
+type loginDisplay struct {
+	browserName string
+	browserOpts browser.Options
+	showQRCode  bool
+	live        bool
+
+	// cancel stops the sign-in when Ctrl+C is pressed on the live status line
+	cancel func()
+
+	// clipboard receives the user code; nil when there is no clipboard
+	clipboard clipboard.Backend
+
+	verificationURL string
+	userCode        string
+	status          *ui.LiveStatus
+	lastPrinted     time.Time
+}
+
+// newLoginDisplay returns a display that opens the verification URL in
+// browserName. An empty browserName only prints the URL, for signing in on
+// another device.
+func newLoginDisplay(browserName string, browserOpts browser.Options, showQRCode bool, cancel func()) *loginDisplay {
+	d := &loginDisplay{
+		browserName: browserName,
+		browserOpts: browserOpts,
+		showQRCode:  showQRCode,
+		live:        ui.CanRedraw() && !ui.Quiet(),
+		cancel:      cancel,
+	}
+	if b, err := clipboard.Detect(); err == nil {
+		d.clipboard = b
+	}
+	return d
+}
+
+// Handle is the sso.LoginHandler for the display
+func (d *loginDisplay) Handle(event sso.LoginEvent) {
+	switch event.Type {
+	case sso.EventRegistering:
+		ui.PrintInfo("Registering client with AWS SSO...")
+
+	case sso.EventStartingAuthorization:
+		ui.PrintInfo("Starting device authorization...")
+
+	case sso.EventVerification:
+		d.verificationURL, d.userCode = event.VerificationURL, event.UserCode
+		printVerification(event, d.browserName != "")
+
+		// The code is typed on the sign-in page, so have it ready to paste
+		if d.clipboard != nil {
+			if err := d.clipboard.Copy(event.UserCode); err == nil && !ui.Quiet() {
+				fmt.Fprintf(os.Stderr, "  %sCode copied to the clipboard%s\n\n", ui.ColorGreen, ui.ColorReset)
+			}
+		}
+
+		if d.showQRCode {
+			if err := ui.PrintQRCode(event.VerificationURL); err != nil {
+				ui.PrintError(fmt.Sprintf("Failed to render QR code: %v", err))
+			}
+			fmt.Fprintln(os.Stderr)
+		}
+
+		if d.browserName != "" {
+			if err := d.openBrowser(); err != nil {
+				ui.PrintError(fmt.Sprintf("Failed to open browser: %v", err))
+				fmt.Fprintln(os.Stderr, "Please open the URL manually in your browser.")
+			}
+		}
+
+	case sso.EventWaiting:
+		if d.live {
+			d.status = ui.StartLiveStatus(fmt.Sprintf("Waiting for sign-in with code %s", d.userCode), event.ExpiresAt, d.shortcuts(), d.cancel)
+		} else {
+			ui.PrintInfo(fmt.Sprintf("Waiting for authorization with code %s, expires in %s... (press Ctrl+C to cancel)", d.userCode, ui.FormatCountdown(time.Until(event.ExpiresAt))))
+			d.lastPrinted = time.Now()
+		}
+
+	case sso.EventPending:
+		if !d.live && time.Since(d.lastPrinted) >= plainStatusInterval {
+			ui.PrintInfo(fmt.Sprintf("Still waiting for authorization, expires in %s", ui.FormatCountdown(time.Until(event.ExpiresAt))))
+			d.lastPrinted = time.Now()
+		}
+
+	case sso.EventSlowDown:
+		msg := fmt.Sprintf("Polling every %s", event.Interval)
+		if d.status != nil {
+			d.status.Note(msg)
+		} else {
+			ui.PrintInfo(msg)
+		}
+
+	case sso.EventAuthorized:
+		d.Close()
+		ui.PrintSuccess("Authorization successful!")
+
+	case sso.EventExpired:
+		d.Close()
+		ui.PrintWarning("The sign-in code expired, requesting a new one...")
+	}
+}
+
+// Close stops the live status line, if it is shown
+func (d *loginDisplay) Close() {
+	if d.status != nil {
+		d.status.Stop()
+		d.status = nil
+	}
+}
+
+// shortcuts are the keys offered on the live status line
+func (d *loginDisplay) shortcuts() []ui.Shortcut {
+	var shortcuts []ui.Shortcut
+	if d.browserName != "" {
+		shortcuts = append(shortcuts, ui.Shortcut{Key: 'o', Label: "open browser", Action: func() string {
+			if err := d.openBrowser(); err != nil {
+				return fmt.Sprintf("Failed to open browser: %v", err)
+			}
+			return "Opened " + d.browserName
+		}})
+	}
+	if d.clipboard != nil {
+		shortcuts = append(shortcuts, ui.Shortcut{Key: 'c', Label: "copy URL", Action: func() string {
+			if err := d.clipboard.Copy(d.verificationURL); err != nil {
+				return err.Error()
+			}
+			return "Copied the URL to the clipboard"
+		}})
+	}
+	return shortcuts
+}
+
+func (d *loginDisplay) openBrowser() error {
+	return browser.OpenURLWithOptions(d.browserName, d.verificationURL, d.browserOpts)
+}
+
+// exitLoginFailed explains a failed sign-in and exits with a code for its cause
+func exitLoginFailed(err error) {
+	exitIfCancelled(err)
+	fmt.Fprintln(os.Stderr)
+	switch {
+	case errors.Is(err, sso.ErrAccessDenied):
+		ui.PrintError("Sign-in was denied in the browser. Run aws-term again to retry.")
+		exit(exitAccessDenied)
+	case errors.Is(err, sso.ErrDeviceCodeExpired):
+		ui.PrintError("The sign-in code expired before it was approved. Run aws-term again to get a new code.")
+		exit(exitLoginExpired)
+	case errors.Is(err, sso.ErrInvalidClient):
+		ui.PrintError(fmt.Sprintf("IAM Identity Center rejected the client registration; check the SSO URL and region: %v", err))
+		exit(exitInvalidClient)
+	}
+	ui.PrintError(fmt.Sprintf("Authentication failed: %v", err))
+	exit(1)
+}
+
+// printVerification prints the banner with the verification URL and code
+func printVerification(event sso.LoginEvent, openingBrowser bool) {
+	fmt.Fprintln(os.Stderr)
+	rule := strings.Repeat(ui.Symbols.HeavyRule, 44)
+	fmt.Fprintf(os.Stderr, "%s%s%s%s\n", ui.ColorBold, ui.ColorCyan, rule, ui.ColorReset)
+	if openingBrowser {
+		fmt.Fprintf(os.Stderr, "%s  Opening browser for AWS SSO login...%s\n", ui.ColorYellow, ui.ColorReset)
+	} else {
+		fmt.Fprintf(os.Stderr, "%s  Sign in to AWS SSO on any device%s\n", ui.ColorYellow, ui.ColorReset)
+	}
+	fmt.Fprintf(os.Stderr, "%s%s%s\n", ui.ColorCyan, rule, ui.ColorReset)
+	fmt.Fprintln(os.Stderr)
+	if openingBrowser {
+		fmt.Fprintf(os.Stderr, "  If browser doesn't open, visit:\n")
+	} else {
+		fmt.Fprintf(os.Stderr, "  Open this URL in a browser:\n")
+	}
+	fmt.Fprintf(os.Stderr, "  %s%s%s\n", ui.ColorBlue, event.VerificationURL, ui.ColorReset)
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintf(os.Stderr, "  Verification code: %s%s%s\n", ui.ColorBold, event.UserCode, ui.ColorReset)
+	fmt.Fprintln(os.Stderr)
+}
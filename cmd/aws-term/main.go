@@ -2,16 +2,22 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/ysaakpr/aws-term/internal/browser"
+	"github.com/ysaakpr/aws-term/internal/cache"
+	"github.com/ysaakpr/aws-term/internal/clipboard"
 	"github.com/ysaakpr/aws-term/internal/config"
+	"github.com/ysaakpr/aws-term/internal/history"
 	"github.com/ysaakpr/aws-term/internal/sso"
 	"github.com/ysaakpr/aws-term/internal/ui"
 )
@@ -20,19 +26,105 @@ var (
 	version = "0.2.0"
 )
 
+// Exit codes for failed sign-ins, so scripts can tell them apart from other errors
+const (
+	exitAccessDenied  = 3
+	exitLoginExpired  = 4
+	exitInvalidClient = 5
+)
+
 func main() {
+	// Docker runs credential helpers as docker-credential-<name> <action>
+	if strings.HasPrefix(filepath.Base(os.Args[0]), dockerHelperPrefix) {
+		ui.ConfigureTerminal()
+		runDockerCredentialCommand(os.Args[1:])
+		os.Exit(0)
+	}
+
+	// --output and --quiet apply to every command, so take them out before
+	// any flags are parsed
+	args, global, err := parseGlobalFlags(os.Args[1:])
+	if err != nil {
+		ui.ConfigureTerminal()
+		ui.PrintError(err.Error())
+		os.Exit(1)
+	}
+	os.Args = append(os.Args[:1], args...)
+
+	// eks-token and docker-credential write the JSON their callers expect anyway
+	if global.Output == "json" && !slices.Contains([]string{"eks-token", "docker-credential", "clipboard-clear"}, firstArg(args)) {
+		enableJSONOutput()
+	}
+	// The theme has to be in place before anything is printed
+	var themeSettings *config.ThemeSettings
+	if cfg, err := config.Load(); err == nil {
+		themeSettings = cfg.Theme
+	}
+	theme, themeErr := ui.ThemeFor(themeSettings)
+	ui.ApplyTheme(theme)
+	ui.ConfigureTerminal()
+	ui.SetQuiet(global.Quiet)
+	if themeErr != nil {
+		ui.PrintWarning(fmt.Sprintf("Ignoring the theme in the config: %v", themeErr))
+	}
+
+	// Dispatch subcommands before parsing the top-level flags
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "accounts":
+			runAccountsCommand(os.Args[2:])
+			os.Exit(0)
+		case "console":
+			runConsoleCommand(os.Args[2:])
+			os.Exit(0)
+		case "eks-token":
+			runEKSTokenCommand(os.Args[2:])
+			os.Exit(0)
+		case "kubeconfig":
+			runKubeconfigCommand(os.Args[2:])
+			os.Exit(0)
+		case "docker-credential":
+			runDockerCredentialCommand(os.Args[2:])
+			os.Exit(0)
+		case "status", "whoami":
+			runStatusCommand(os.Args[2:])
+			os.Exit(0)
+		case "clipboard-clear":
+			runClipboardClearCommand(os.Args[2:])
+			os.Exit(0)
+		}
+	}
+
 	// Parse command line flags
 	showVersion := flag.Bool("version", false, "Show version information")
 	showHelp := flag.Bool("help", false, "Show help information")
 	addProfile := flag.Bool("add", false, "Add a new SSO profile")
 	listProfiles := flag.Bool("list", false, "List all configured profiles")
 	setDefault := flag.String("set-default", "", "Set a profile as default")
-	regionFlag := flag.String("region", "", "AWS region for SSO (default: us-east-1)")
+	favorite := flag.String("favorite", "", "Star an account or role (ACCOUNT_ID[/ROLE]) so it is pinned in pickers")
+	unfavorite := flag.String("unfavorite", "", "Remove a starred account or role (ACCOUNT_ID[/ROLE])")
+	clearHistory := flag.Bool("clear-history", false, "Clear recently used accounts and roles")
+	clearFavorites := flag.Bool("clear-favorites", false, "Clear starred accounts and roles")
+	copyExports := flag.Bool("copy", false, "Copy the export commands to the clipboard")
+	shellFormat := flag.String("shell-format", "", "Format of the export commands: posix, fish, powershell or cmd (default: from $SHELL)")
+	clipboardTimeout := flag.Duration("clipboard-timeout", defaultClipboardTimeout, "Clear copied credentials from the clipboard after this long (0 keeps them)")
+	sessionOpts := addSessionFlags(flag.CommandLine)
 
 	flag.Parse()
 
+	if *shellFormat != "" {
+		if _, err := sso.ExportCredentials(&sso.Credentials{}, *shellFormat); err != nil {
+			ui.PrintError(err.Error())
+			exit(1)
+		}
+	}
+
 	// Handle version flag
 	if *showVersion {
+		if jsonOutput {
+			writeResult("version", map[string]string{"version": version})
+			os.Exit(0)
+		}
 		fmt.Printf("aws-term version %s\n", version)
 		os.Exit(0)
 	}
@@ -64,12 +156,13 @@ func main() {
 			cfg.SetDefault(*setDefault)
 			if err := cfg.Save(); err != nil {
 				ui.PrintError(fmt.Sprintf("Failed to save config: %v", err))
-				os.Exit(1)
+				exit(1)
 			}
 			ui.PrintSuccess(fmt.Sprintf("Set '%s' as the default profile", *setDefault))
+			writeResult("profile", newProfileResult(cfg.GetProfileByName(*setDefault)))
 		} else {
 			ui.PrintError(fmt.Sprintf("Profile '%s' not found", *setDefault))
-			os.Exit(1)
+			exit(1)
 		}
 		os.Exit(0)
 	}
@@ -86,142 +179,89 @@ func main() {
 		profileName = flag.Arg(0)
 	}
 
-	// Select profile to use
-	var selectedProfile *config.Profile
-
-	if profileName != "" {
-		// User specified a profile name
-		selectedProfile = cfg.GetProfileByName(profileName)
-		if selectedProfile == nil {
-			ui.PrintError(fmt.Sprintf("Profile '%s' not found", profileName))
-			ui.PrintInfo("Use --list to see available profiles or --add to create a new one")
-			os.Exit(1)
-		}
-	} else if len(cfg.Profiles) == 0 {
-		// No profiles configured, prompt for new one
-		selectedProfile = promptNewProfile(cfg)
-	} else if len(cfg.Profiles) == 1 {
-		// Only one profile, use it
-		selectedProfile = &cfg.Profiles[0]
-		ui.PrintInfo(fmt.Sprintf("Using profile: %s", selectedProfile.Name))
-	} else {
-		// Multiple profiles, check for default or prompt selection
-		defaultProfile := cfg.GetDefaultProfile()
-		if defaultProfile != nil {
-			ui.PrintInfo(fmt.Sprintf("Using default profile: %s", defaultProfile.Name))
-			selectedProfile = defaultProfile
-		} else {
-			// No default, show selection
-			selected, err := ui.SelectProfile(cfg.Profiles)
-			if err != nil {
-				ui.PrintError(fmt.Sprintf("Failed to select profile: %v", err))
-				os.Exit(1)
-			}
-			selectedProfile = selected
-		}
-	}
-
-	// Determine region
-	region := selectedProfile.Region
-	if *regionFlag != "" {
-		region = *regionFlag
-	}
-	if region == "" {
-		region = sso.ExtractRegionFromURL(selectedProfile.SSOUrl)
+	// Handle clear history/favorites flags, scoped to a profile if one was given
+	if *clearHistory || *clearFavorites {
+		clearSelectionState(profileName, *clearHistory, *clearFavorites)
+		writeResult("ok", nil)
+		os.Exit(0)
 	}
 
-	// Detect available browsers
-	browsers := browser.DetectBrowsers()
-	if len(browsers) == 0 {
-		ui.PrintError("No supported browsers found (Chrome, Safari, Firefox)")
-		os.Exit(1)
-	}
+	// Select profile to use
+	selectedProfile := selectProfile(cfg, profileName)
 
-	// Select browser
-	selectedBrowser, err := ui.SelectBrowser(browsers)
-	if err != nil {
-		ui.PrintError(fmt.Sprintf("Failed to select browser: %v", err))
-		os.Exit(1)
+	// Handle favorite flags now that the profile is known
+	if *favorite != "" || *unfavorite != "" {
+		updateFavorites(selectedProfile.Name, *favorite, *unfavorite)
+		writeResult("ok", nil)
+		os.Exit(0)
 	}
 
-	// Create SSO client and authenticate
-	ctx := context.Background()
-	ssoClient := sso.NewSSOClient(selectedProfile.SSOUrl, region)
+	// Sign in and pick the account and role
+	ctx, stop := signalContext()
+	sess := startSession(ctx, selectedProfile, sessionOpts)
+	creds := sess.Credentials
 
-	// Authenticate using device authorization flow
-	if err := ssoClient.Authenticate(ctx, selectedBrowser); err != nil {
-		ui.PrintError(fmt.Sprintf("Authentication failed: %v", err))
-		os.Exit(1)
-	}
+	// Hand signal handling back before a shell takes over the terminal
+	stop()
 
-	// List available accounts
-	ui.PrintInfo("Fetching available accounts...")
-	accounts, err := ssoClient.ListAccounts(ctx)
+	// Save credentials to a file for sourcing
+	credFile, err := sso.WriteCredentialsToFile(creds)
 	if err != nil {
-		ui.PrintError(fmt.Sprintf("Failed to list accounts: %v", err))
-		os.Exit(1)
-	}
-
-	if len(accounts) == 0 {
-		ui.PrintError("No accounts available for this SSO configuration")
-		os.Exit(1)
+		ui.PrintError(fmt.Sprintf("Failed to write credentials file: %v", err))
 	}
 
-	// Select account
-	selectedAccount, err := sso.SelectAccount(accounts)
-	if err != nil {
-		ui.PrintError(fmt.Sprintf("Failed to select account: %v", err))
-		os.Exit(1)
-	}
+	// Remember the session so "aws-term status" can describe these credentials
+	recordSession(sess)
 
-	// List roles for the selected account
-	ui.PrintInfo(fmt.Sprintf("Fetching roles for %s...", selectedAccount.AccountName))
-	roles, err := ssoClient.ListRoles(ctx, selectedAccount.AccountId)
-	if err != nil {
-		ui.PrintError(fmt.Sprintf("Failed to list roles: %v", err))
-		os.Exit(1)
+	// Determine the user's shell
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/bash"
 	}
 
-	if len(roles) == 0 {
-		ui.PrintError("No roles available for this account")
-		os.Exit(1)
+	// Export commands in the format of the user's shell
+	format := *shellFormat
+	if format == "" {
+		format = sso.ShellFormatFor(shell)
 	}
-
-	// Select role
-	selectedRole, err := sso.SelectRole(roles)
+	exports, err := sso.ExportCredentials(creds, format)
 	if err != nil {
-		ui.PrintError(fmt.Sprintf("Failed to select role: %v", err))
-		os.Exit(1)
+		ui.PrintError(err.Error())
+		exit(1)
 	}
 
-	// Get credentials for the selected role
-	ui.PrintInfo("Getting credentials...")
-	creds, err := ssoClient.GetRoleCredentials(ctx, selectedAccount.AccountId, selectedRole.RoleName)
-	if err != nil {
-		ui.PrintError(fmt.Sprintf("Failed to get credentials: %v", err))
-		os.Exit(1)
+	// Scripts get the credentials as a result instead of a shell
+	if jsonOutput {
+		if *copyExports {
+			copyExportCommands(exports, *clipboardTimeout)
+		}
+		writeResult("session", newSessionResult(sess, credFile))
+		return
 	}
 
-	// Save credentials to a file for sourcing
-	credFile, err := sso.WriteCredentialsToFile(creds)
-	if err != nil {
-		ui.PrintError(fmt.Sprintf("Failed to write credentials file: %v", err))
+	// Quiet mode prints only the export commands, for eval "$(aws-term -q)"
+	if ui.Quiet() {
+		fmt.Print(exports)
+		if *copyExports {
+			copyExportCommands(exports, *clipboardTimeout)
+		}
+		return
 	}
 
 	// Print success and show how to use credentials
 	ui.PrintSuccess("Credentials obtained successfully!")
 	fmt.Println()
-	fmt.Printf("  %sAccount:%s  %s (%s)\n", ui.ColorBold, ui.ColorReset, selectedAccount.AccountName, selectedAccount.AccountId)
-	fmt.Printf("  %sRole:%s     %s\n", ui.ColorBold, ui.ColorReset, selectedRole.RoleName)
+	fmt.Printf("  %sAccount:%s  %s\n", ui.ColorBold, ui.ColorReset, sess.Label())
+	fmt.Printf("  %sRole:%s     %s\n", ui.ColorBold, ui.ColorReset, sess.Role.RoleName)
+	if sess.AssumedRoleArn != "" {
+		fmt.Printf("  %sAssumed:%s  %s\n", ui.ColorBold, ui.ColorReset, sess.AssumedRoleArn)
+	}
+	if sess.IsProduction {
+		fmt.Printf("  %sEnv:%s      %s%sPRODUCTION%s\n", ui.ColorBold, ui.ColorReset, ui.ColorBold, ui.ColorRed, ui.ColorReset)
+	}
 	fmt.Printf("  %sExpires:%s  %s\n", ui.ColorBold, ui.ColorReset, creds.Expiration.Local().Format(time.RFC1123))
 	fmt.Println()
 
-	// Determine the user's shell
-	shell := os.Getenv("SHELL")
-	if shell == "" {
-		shell = "/bin/bash"
-	}
-
 	fmt.Printf("To use these credentials, you can either:\n\n")
 	if credFile != "" {
 		fmt.Printf("  1. Source the credentials file:\n")
@@ -230,22 +270,60 @@ func main() {
 	} else {
 		fmt.Printf("  Copy these export commands:\n\n")
 	}
-	fmt.Printf("     export AWS_ACCESS_KEY_ID=\"%s\"\n", creds.AccessKeyId)
-	fmt.Printf("     export AWS_SECRET_ACCESS_KEY=\"%s\"\n", creds.SecretAccessKey)
-	fmt.Printf("     export AWS_SESSION_TOKEN=\"%s\"\n\n", creds.SessionToken)
+	for _, line := range strings.SplitAfter(exports, "\n") {
+		if line = strings.TrimRight(line, "\r\n"); line != "" {
+			fmt.Printf("     %s\n", line)
+		}
+	}
+	fmt.Println()
+
+	if *copyExports {
+		copyExportCommands(exports, *clipboardTimeout)
+	}
 
 	// Print helpful verification commands
-	fmt.Printf("%s%s─── Verify your session ───%s\n\n", ui.ColorBold, ui.ColorYellow, ui.ColorReset)
+	rule := strings.Repeat(ui.Symbols.Rule, 3)
+	fmt.Printf("%s%s%s Verify your session %s%s\n\n", ui.ColorBold, ui.ColorYellow, rule, rule, ui.ColorReset)
 	fmt.Printf("  After setting credentials, run:\n\n")
 	fmt.Printf("    %saws sts get-caller-identity%s\n", ui.ColorCyan, ui.ColorReset)
 	fmt.Printf("    # Shows: Account ID, User ID, and ARN\n\n")
 	fmt.Printf("    %saws s3 ls%s\n", ui.ColorCyan, ui.ColorReset)
 	fmt.Printf("    # Lists S3 buckets (if you have permission)\n\n")
 
-	// Ask if user wants to spawn a new shell with credentials
+	// Ask if user wants to spawn a new shell with credentials, unless the
+	// output is going somewhere other than the terminal
+	if !ui.IsTerminal(os.Stdout) {
+		return
+	}
 	response := ui.PromptInput("Open a new shell with these credentials? (Y/n)")
 	if response == "" || strings.ToLower(response) == "y" || strings.ToLower(response) == "yes" {
-		spawnShellWithCredentials(shell, creds, selectedAccount.AccountName, selectedRole.RoleName)
+		spawnShellWithCredentials(shell, sess)
+	}
+}
+
+// copyExportCommands copies the export commands and says when they will be cleared
+func copyExportCommands(exports string, timeout time.Duration) {
+	b, err := clipboard.Detect()
+	if err == nil {
+		err = copySecret(b, exports, timeout)
+	}
+	if errors.Is(err, errOSC52Clear) {
+		ui.PrintWarning(fmt.Sprintf("Not copying the export commands: %v", err))
+		ui.PrintInfo("Use --clipboard-timeout 0 to copy them anyway; they then stay on the clipboard")
+		return
+	}
+	if err != nil {
+		ui.PrintError(fmt.Sprintf("Failed to copy export commands: %v", err))
+		return
+	}
+
+	if timeout > 0 {
+		ui.PrintInfo(fmt.Sprintf("Copied the export commands with %s; they are cleared from the clipboard in %s", b.Name(), timeout))
+	} else {
+		ui.PrintInfo(fmt.Sprintf("Copied the export commands with %s", b.Name()))
+	}
+	if !ui.Quiet() {
+		fmt.Fprintln(os.Stderr)
 	}
 }
 
@@ -254,6 +332,11 @@ func printHelp() {
 
 Usage:
   aws-term [options] [profile-name]
+  aws-term accounts list [options] [profile-name]
+  aws-term console [options] [profile-name]
+  aws-term eks-token --cluster NAME [options] [profile-name]
+  aws-term kubeconfig --cluster NAME --target NAME [options] [profile-name]
+  aws-term docker-credential get|list|store|erase
 
 Options:
   --help            Show this help message
@@ -262,6 +345,29 @@ Options:
   --list            List all configured profiles
   --set-default     Set a profile as the default
   --region          AWS region for SSO (default: auto-detect or us-east-1)
+  --favorite        Star an account or role (ACCOUNT_ID[/ROLE]) for the profile
+  --unfavorite      Remove a starred account or role (ACCOUNT_ID[/ROLE])
+  --clear-history   Clear recently used accounts and roles
+  --clear-favorites Clear starred accounts and roles
+  --account         Account to use, by ID, name or alias (skips the picker)
+  --role            Role to use in the selected account (skips the picker)
+  --target          Named target from the profile (account, role and role chain)
+  --no-browser      Print the login URL and code instead of opening a browser
+                    (automatic when no display is available)
+  --choose-browser  Pick the browser again instead of using the remembered one
+  --qr              Show the login URL as a QR code in the terminal
+  --force           Allow roles outside a production account's allowed roles
+  --flat            Pick account and role from a single "Account / Role" list
+  --refresh         Ignore cached accounts and roles and fetch them again
+  --login           Sign in again even if the cached SSO login is still valid
+  --copy            Copy the export commands to the clipboard
+  --shell-format    Export command format: posix, fish, powershell or cmd
+                    (default: from $SHELL)
+  --clipboard-timeout
+                    Clear copied credentials after this long (default 45s, 0 keeps them)
+  -q, --quiet       Print only results, errors and sign-in instructions
+  --output json     Write results as JSON on stdout and messages on stderr
+                    (works with every command)
 
 Examples:
   aws-term                    # Use default profile or show selection
@@ -269,6 +375,33 @@ Examples:
   aws-term --add              # Add a new profile
   aws-term --set-default dev  # Set 'dev' as the default profile
   aws-term --region eu-west-1 # Use a specific region
+  aws-term --favorite 123456789012/Admin prod  # Pin a role in 'prod'
+  aws-term --clear-history    # Forget recently used accounts
+  aws-term --flat             # Choose account and role in one step
+  aws-term --account payments-prod --role ReadOnly
+  aws-term --target data-admin production  # SSO role, then the target's role chain
+  aws-term --no-browser --qr  # Log in from an SSH session with your phone
+  aws-term --copy --shell-format fish  # Copy fish 'set -gx' commands
+  eval "$(aws-term -q --target data-admin prod)"  # Set credentials in this shell
+  aws-term --output json --target data-admin prod | jq -r .data.credentials.access_key_id
+
+Commands:
+  accounts list     List cached accounts and roles without logging in
+                    --format table|json|csv, --name, --id, --email, --role
+  console           Sign in and open the AWS web console for the chosen role
+                    --service, --console-region, --federation-url, --print,
+                    --isolate firefox-container|chrome|none
+  eks-token         Print a kubectl ExecCredential with an EKS token
+                    --cluster, --cluster-region (plus login and selection options)
+  kubeconfig        Add a kubeconfig user that runs eks-token for a cluster
+                    --cluster, --target or --account/--role, --user, --context,
+                    --kubeconfig, --cluster-region
+  status, whoami    Show the account, role and expiry of the credentials in
+                    this shell and the cached SSO login of each profile
+                    --verify, --sts-endpoint
+  docker-credential Docker credential helper for ECR registries
+                    (also runs as a docker-credential-aws-term link)
+                    (accepts the login and selection options above)
 
 Workflow:
   1. Select an SSO profile (or create one)
@@ -280,10 +413,20 @@ Workflow:
 
 Configuration:
   Profiles are stored in ~/.aws-terminal/config.json
+  History and favorites are stored in ~/.aws-terminal/state/
 `)
 }
 
 func listAllProfiles(cfg *config.Config) {
+	if jsonOutput {
+		profiles := make([]profileResult, 0, len(cfg.Profiles))
+		for i := range cfg.Profiles {
+			profiles = append(profiles, newProfileResult(&cfg.Profiles[i]))
+		}
+		writeResult("profiles", profiles)
+		return
+	}
+
 	if len(cfg.Profiles) == 0 {
 		ui.PrintInfo("No profiles configured. Use --add to create one.")
 		return
@@ -299,12 +442,228 @@ func listAllProfiles(cfg *config.Config) {
 		if p.Region != "" {
 			regionInfo = fmt.Sprintf(" [%s]", p.Region)
 		}
-		fmt.Printf("  • %s%s%s%s%s\n", ui.ColorBold, p.Name, ui.ColorReset, regionInfo, defaultMarker)
+		fmt.Printf("  %s %s%s%s%s%s\n", ui.Symbols.Bullet, ui.ColorBold, p.Name, ui.ColorReset, regionInfo, defaultMarker)
 		fmt.Printf("    %s%s%s\n", ui.ColorBlue, p.SSOUrl, ui.ColorReset)
 	}
 	fmt.Println()
 }
 
+// browserOptions converts a profile's browser settings into launch options
+func browserOptions(settings *config.BrowserSettings) browser.Options {
+	if settings == nil {
+		return browser.Options{}
+	}
+	return browser.Options{
+		Command:        settings.Command,
+		ChromeProfile:  settings.ChromeProfile,
+		FirefoxProfile: settings.FirefoxProfile,
+		Private:        settings.Private,
+		NewWindow:      settings.NewWindow,
+	}
+}
+
+// selectProfileBrowser picks the browser for login. A custom command or a
+// configured browser that is installed skips the picker, as does the browser
+// remembered from the last run unless choose is set. An empty result means no
+// browser is available and the login URL is only printed.
+func selectProfileBrowser(profileName string, settings *config.BrowserSettings, choose bool) string {
+	if settings != nil && settings.Command != "" {
+		return browser.CustomBrowser
+	}
+
+	browsers := browser.DetectBrowsers()
+	if len(browsers) == 0 {
+		ui.PrintInfo("No supported browsers found, printing the login URL instead")
+		return ""
+	}
+
+	preferred := ""
+	if settings != nil && settings.Name != "" {
+		preferred = settings.Name
+	}
+
+	choices, err := history.LoadBrowserChoices()
+	if err != nil {
+		ui.PrintError(fmt.Sprintf("Failed to load remembered browser: %v", err))
+	}
+	if preferred == "" && !choose {
+		preferred = choices.Profiles[profileName]
+	}
+
+	if preferred != "" {
+		for _, b := range browsers {
+			if strings.EqualFold(b, preferred) {
+				ui.PrintInfo(fmt.Sprintf("Using %s... (--choose-browser to pick another)", b))
+				return b
+			}
+		}
+		ui.PrintInfo(fmt.Sprintf("Browser '%s' not found", preferred))
+	}
+
+	selected, err := ui.SelectBrowser(browsers)
+	if err != nil {
+		exitIfCancelled(err)
+		ui.PrintError(fmt.Sprintf("Failed to select browser: %v", err))
+		exit(1)
+	}
+
+	choices.Profiles[profileName] = selected
+	if err := choices.Save(); err != nil {
+		ui.PrintError(fmt.Sprintf("Failed to remember browser: %v", err))
+	}
+	return selected
+}
+
+// refreshAccounts lists accounts in the background. The channel receives the
+// new list, or is closed without a value if listing fails.
+func refreshAccounts(ctx context.Context, ssoClient *sso.SSOClient) <-chan []sso.Account {
+	ch := make(chan []sso.Account, 1)
+	go func() {
+		defer close(ch)
+		accounts, err := ssoClient.ListAccounts(ctx)
+		if err != nil {
+			return
+		}
+		ch <- accounts
+	}()
+	return ch
+}
+
+// refreshedAccountList returns the accounts listed in the background, if the
+// listing has finished
+func refreshedAccountList(ch <-chan []sso.Account) ([]sso.Account, bool) {
+	select {
+	case accounts, ok := <-ch:
+		return accounts, ok
+	default:
+		return nil, false
+	}
+}
+
+// prefetchRolesInBackground lists the roles of every account in the
+// background. The channel receives the roles by account ID, or is closed
+// without a value if listing fails or ctx is cancelled.
+func prefetchRolesInBackground(ctx context.Context, ssoClient *sso.SSOClient, accounts []sso.Account) <-chan map[string][]sso.Role {
+	ch := make(chan map[string][]sso.Role, 1)
+	go func() {
+		defer close(ch)
+		roleMap, err := ssoClient.PrefetchRoles(ctx, accounts, sso.DefaultPrefetchConcurrency)
+		if err != nil {
+			return
+		}
+		ch <- roleMap
+	}()
+	return ch
+}
+
+// prefetchedRoles returns the roles listed in the background, if the listing
+// has finished
+func prefetchedRoles(ch <-chan map[string][]sso.Role) (map[string][]sso.Role, bool) {
+	select {
+	case roleMap, ok := <-ch:
+		return roleMap, ok
+	default:
+		return nil, false
+	}
+}
+
+// prefetchRoles returns the roles of every account, reusing the cache while it
+// is fresh and otherwise fetching them in parallel and updating the cache
+func prefetchRoles(ctx context.Context, login *portalLogin, accounts []sso.Account, dir *cache.Directory, refresh bool) map[string][]sso.Role {
+	if !refresh && dir.RolesFresh(cache.DefaultRolesTTL) {
+		return dir.Roles
+	}
+
+	ui.PrintInfo(fmt.Sprintf("Fetching roles for %d accounts...", len(accounts)))
+	var roleMap map[string][]sso.Role
+	err := login.retry(ctx, func() (err error) {
+		roleMap, err = login.client.PrefetchRoles(ctx, accounts, sso.DefaultPrefetchConcurrency)
+		return err
+	})
+	if err != nil {
+		exitIfCancelled(err)
+		ui.PrintError(fmt.Sprintf("Failed to list roles: %v", err))
+		exit(1)
+	}
+
+	dir.SetRoles(roleMap)
+	if err := dir.Save(); err != nil {
+		ui.PrintError(fmt.Sprintf("Failed to save role cache: %v", err))
+	}
+	return roleMap
+}
+
+func clearSelectionState(profileName string, clearHistory, clearFavorites bool) {
+	scope := "all profiles"
+	if profileName != "" {
+		scope = fmt.Sprintf("profile '%s'", profileName)
+	}
+
+	if clearHistory {
+		hist, _ := history.Load()
+		hist.Clear(profileName)
+		if err := hist.Save(); err != nil {
+			ui.PrintError(fmt.Sprintf("Failed to save selection history: %v", err))
+			exit(1)
+		}
+		ui.PrintSuccess(fmt.Sprintf("Cleared selection history for %s", scope))
+	}
+
+	if clearFavorites {
+		favs, _ := history.LoadFavorites()
+		favs.Clear(profileName)
+		if err := favs.Save(); err != nil {
+			ui.PrintError(fmt.Sprintf("Failed to save favorites: %v", err))
+			exit(1)
+		}
+		ui.PrintSuccess(fmt.Sprintf("Cleared favorites for %s", scope))
+	}
+}
+
+// parseFavorite parses an ACCOUNT_ID[/ROLE] argument
+func parseFavorite(profileName, spec string) history.Favorite {
+	accountId, roleName, _ := strings.Cut(spec, "/")
+	return history.Favorite{
+		Profile:   profileName,
+		AccountId: strings.TrimSpace(accountId),
+		RoleName:  strings.TrimSpace(roleName),
+	}
+}
+
+func updateFavorites(profileName, add, remove string) {
+	favs, err := history.LoadFavorites()
+	if err != nil {
+		ui.PrintError(fmt.Sprintf("Failed to load favorites: %v", err))
+		exit(1)
+	}
+
+	if add != "" {
+		fav := parseFavorite(profileName, add)
+		if fav.AccountId == "" {
+			ui.PrintError("Favorite must be given as ACCOUNT_ID or ACCOUNT_ID/ROLE")
+			exit(1)
+		}
+		if favs.Add(fav) {
+			ui.PrintSuccess(fmt.Sprintf("Starred %s in profile '%s'", add, profileName))
+		} else {
+			ui.PrintInfo(fmt.Sprintf("%s is already starred in profile '%s'", add, profileName))
+		}
+	}
+
+	if remove != "" {
+		if favs.Remove(parseFavorite(profileName, remove)) {
+			ui.PrintSuccess(fmt.Sprintf("Removed %s from favorites in profile '%s'", remove, profileName))
+		} else {
+			ui.PrintInfo(fmt.Sprintf("%s is not starred in profile '%s'", remove, profileName))
+		}
+	}
+
+	if err := favs.Save(); err != nil {
+		ui.PrintError(fmt.Sprintf("Failed to save favorites: %v", err))
+		exit(1)
+	}
+}
+
 func addNewProfile(cfg *config.Config) {
 	profile := promptNewProfile(cfg)
 	if profile == nil {
@@ -314,10 +673,11 @@ func addNewProfile(cfg *config.Config) {
 	cfg.AddProfile(*profile)
 	if err := cfg.Save(); err != nil {
 		ui.PrintError(fmt.Sprintf("Failed to save config: %v", err))
-		os.Exit(1)
+		exit(1)
 	}
 
 	ui.PrintSuccess(fmt.Sprintf("Profile '%s' added successfully!", profile.Name))
+	writeResult("profile", newProfileResult(profile))
 }
 
 func promptNewProfile(cfg *config.Config) *config.Profile {
@@ -387,7 +747,11 @@ func promptNewProfile(cfg *config.Config) *config.Profile {
 	return profile
 }
 
-func spawnShellWithCredentials(shell string, creds *sso.Credentials, accountName, roleName string) {
+func spawnShellWithCredentials(shell string, sess *session) {
+	creds, profile, account := sess.Credentials, sess.Profile, sess.Account
+	roleName, isProduction := sess.Role.RoleName, sess.IsProduction
+	displayName := profile.AccountDisplayName(account.AccountId, account.AccountName)
+
 	// Set environment variables
 	os.Setenv("AWS_ACCESS_KEY_ID", creds.AccessKeyId)
 	os.Setenv("AWS_SECRET_ACCESS_KEY", creds.SecretAccessKey)
@@ -395,11 +759,19 @@ func spawnShellWithCredentials(shell string, creds *sso.Credentials, accountName
 
 	// Add markers to show we're in an AWS session
 	os.Setenv("AWS_TERM_SESSION", "1")
-	os.Setenv("AWS_TERM_ACCOUNT", accountName)
+	os.Setenv("AWS_TERM_ACCOUNT", account.AccountName)
+	os.Setenv("AWS_TERM_ACCOUNT_ID", account.AccountId)
+	os.Setenv("AWS_TERM_ACCOUNT_ALIAS", displayName)
 	os.Setenv("AWS_TERM_ROLE", roleName)
+	if isProduction {
+		os.Setenv("AWS_TERM_ENVIRONMENT", "prod")
+	}
+	if sess.AssumedRoleArn != "" {
+		os.Setenv("AWS_TERM_ASSUMED_ROLE_ARN", sess.AssumedRoleArn)
+	}
 
 	fmt.Printf("\n%sStarting new shell with AWS credentials...%s\n", ui.ColorCyan, ui.ColorReset)
-	fmt.Printf("%sAccount: %s | Role: %s%s\n", ui.ColorYellow, accountName, roleName, ui.ColorReset)
+	fmt.Printf("%sAccount: %s | Role: %s%s\n", ui.ColorYellow, displayName, roleName, ui.ColorReset)
 	fmt.Printf("%sType 'exit' to return to your original shell.%s\n\n", ui.ColorYellow, ui.ColorReset)
 
 	// Spawn new shell
@@ -411,10 +783,23 @@ func spawnShellWithCredentials(shell string, creds *sso.Credentials, accountName
 
 	// On macOS/Linux, we can set a custom prompt indicator
 	if runtime.GOOS != "windows" {
-		// Try to prepend AWS indicator to existing prompt
+		// Try to prepend AWS indicator to existing prompt, in the alias color if
+		// one is set. Production sessions are always red.
+		prefix := fmt.Sprintf("[aws:%s/%s]", displayName, roleName)
+		color := ""
+		if alias, ok := profile.AliasFor(account.AccountId); ok {
+			color = ui.ColorByName(alias.Color)
+		}
+		if isProduction {
+			prefix = fmt.Sprintf("[PROD aws:%s/%s]", displayName, roleName)
+			color = ui.ColorBold + ui.ColorRed
+		}
+		if color != "" {
+			prefix = fmt.Sprintf("\\[%s\\]%s\\[%s\\]", color, prefix, ui.ColorReset)
+		}
 		for i, env := range cmd.Env {
 			if strings.HasPrefix(env, "PS1=") {
-				cmd.Env[i] = fmt.Sprintf("PS1=[aws:%s] %s", roleName, strings.TrimPrefix(env, "PS1="))
+				cmd.Env[i] = fmt.Sprintf("PS1=%s %s", prefix, strings.TrimPrefix(env, "PS1="))
 				break
 			}
 		}
@@ -426,3 +811,117 @@ func spawnShellWithCredentials(shell string, creds *sso.Credentials, accountName
 		fmt.Printf("\n%sAWS session ended.%s\n", ui.ColorCyan, ui.ColorReset)
 	}
 }
+
+// confirmProduction enforces the production guards before credentials are
+// issued: the role must be allowed (unless forced) and the user has to type
+// the account name, or the name of the production target, to continue. It
+// exits if either check fails.
+func confirmProduction(profile *config.Profile, account *sso.Account, roleName, target string, force bool) {
+	displayName := profile.AccountDisplayName(account.AccountId, account.AccountName)
+	subject := fmt.Sprintf("production account %s", displayName)
+	if target != "" {
+		subject = fmt.Sprintf("production target '%s'", target)
+	}
+
+	if !profile.ProductionRoleAllowed(roleName) {
+		if !force {
+			ui.PrintError(fmt.Sprintf("Role '%s' is not allowed in %s", roleName, subject))
+			ui.PrintInfo(fmt.Sprintf("Allowed roles: %s (use --force to override)", strings.Join(profile.Production.AllowedRoles, ", ")))
+			exit(1)
+		}
+		ui.PrintWarning(fmt.Sprintf("Using role '%s' outside the allowed production roles (--force)", roleName))
+	}
+
+	confirmName := displayName
+	if target != "" {
+		confirmName = target
+		ui.PrintWarning(fmt.Sprintf("Target '%s' (via %s) is tagged PRODUCTION", target, displayName))
+	} else {
+		ui.PrintWarning(fmt.Sprintf("%s (%s) is a PRODUCTION account", displayName, account.AccountId))
+	}
+	// Run by kubectl or docker there is nobody to type the confirmation
+	if !ui.IsTerminal(os.Stdin) {
+		ui.PrintError(fmt.Sprintf("Using %s needs a typed confirmation, but stdin is not a terminal", subject))
+		ui.PrintInfo("Run the command in a terminal once; eks-token and docker-credential reuse the credentials until they expire")
+		exit(1)
+	}
+	fmt.Println()
+	if !ui.ConfirmTyped(fmt.Sprintf("Type '%s' to continue", confirmName), confirmName) {
+		ui.PrintError("Confirmation did not match, aborting")
+		exit(1)
+	}
+}
+
+// accountLabel formats accounts for pickers and summaries, putting the
+// configured alias and environment tag in front of the Identity Center name.
+// Production accounts without an environment tag are marked [prod].
+func accountLabel(profile *config.Profile) ui.AccountLabel {
+	return func(acc sso.Account) string {
+		label := ui.DefaultAccountLabel(acc)
+		alias, ok := profile.AliasFor(acc.AccountId)
+		if ok {
+			label = fmt.Sprintf("%s (%s, %s)", alias.Name, acc.AccountName, acc.AccountId)
+		}
+
+		env, color := alias.Environment, ui.ColorByName(alias.Color)
+		if isProd, _ := profile.IsProduction(acc.AccountId, acc.AccountName); isProd {
+			if env == "" {
+				env = "prod"
+			}
+			color = ui.ColorRed
+		}
+		if env != "" {
+			if color == "" {
+				color = ui.ColorYellow
+			}
+			label += fmt.Sprintf(" %s[%s]%s", color, env, ui.ColorReset)
+		}
+		return label
+	}
+}
+
+// matchAccount finds the account named on the command line by ID, Identity
+// Center name or configured alias. Names and aliases match case-insensitively.
+func matchAccount(profile *config.Profile, accounts []sso.Account, query string) (*sso.Account, error) {
+	var matches []*sso.Account
+	for i := range accounts {
+		acc := &accounts[i]
+		if acc.AccountId == query {
+			return acc, nil
+		}
+		alias, _ := profile.AliasFor(acc.AccountId)
+		if strings.EqualFold(acc.AccountName, query) || (alias.Name != "" && strings.EqualFold(alias.Name, query)) {
+			matches = append(matches, acc)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no account matches '%s'", query)
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, fmt.Errorf("'%s' matches %d accounts, use the account ID instead", query, len(matches))
+	}
+}
+
+// matchRole finds the role named on the command line, ignoring case
+func matchRole(roles []sso.Role, query string) (*sso.Role, error) {
+	for i := range roles {
+		if strings.EqualFold(roles[i].RoleName, query) {
+			return &roles[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no role matches '%s'", query)
+}
+
+// filterPairsByRole keeps the account/role pairs whose role is named query
+func filterPairsByRole(pairs []sso.AccountRole, query string) []sso.AccountRole {
+	var filtered []sso.AccountRole
+	for _, p := range pairs {
+		if strings.EqualFold(p.Role.RoleName, query) {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ysaakpr/aws-term/internal/config"
+	"github.com/ysaakpr/aws-term/internal/sso"
+	"github.com/ysaakpr/aws-term/internal/ui"
+)
+
+func TestRefreshedAccountList(t *testing.T) {
+	// A refresh still running is not waited for
+	pending := make(chan []sso.Account)
+	if _, ok := refreshedAccountList(pending); ok {
+		t.Error("refreshedAccountList() returned accounts from a running refresh")
+	}
+
+	done := make(chan []sso.Account, 1)
+	done <- []sso.Account{{AccountId: "111111111111"}}
+	if accounts, ok := refreshedAccountList(done); !ok || len(accounts) != 1 {
+		t.Errorf("refreshedAccountList() = %v, %v, want the refreshed list", accounts, ok)
+	}
+
+	failed := make(chan []sso.Account)
+	close(failed)
+	if _, ok := refreshedAccountList(failed); ok {
+		t.Error("refreshedAccountList() returned accounts from a failed refresh")
+	}
+
+	if _, ok := refreshedAccountList(nil); ok {
+		t.Error("refreshedAccountList(nil) returned accounts")
+	}
+}
+
+// testProfile has aliases and production rules for the matching and label tests
+var testProfile = &config.Profile{
+	Aliases: map[string]config.AccountAlias{
+		"111111111111": {Name: "shared", Color: "blue"},
+		"222222222222": {Name: "payments", Environment: "staging"},
+		"333333333333": {Name: "Billing"},
+		"444444444444": {Name: "edge", Environment: "prod"},
+	},
+	Production: &config.ProductionRules{NamePattern: "-prod$"},
+}
+
+var testAccounts = []sso.Account{
+	{AccountId: "111111111111", AccountName: "shared-services"},
+	{AccountId: "222222222222", AccountName: "payments-staging"},
+	{AccountId: "333333333333", AccountName: "billing-dev"},
+	{AccountId: "444444444444", AccountName: "edge-network"},
+	{AccountId: "555555555555", AccountName: "Billing"},
+	{AccountId: "666666666666", AccountName: "payments-prod"},
+}
+
+func TestMatchAccount(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    string
+		wantErr string
+	}{
+		{name: "account ID", query: "222222222222", want: "222222222222"},
+		{name: "account name", query: "billing-dev", want: "333333333333"},
+		{name: "account name ignoring case", query: "Payments-Prod", want: "666666666666"},
+		{name: "alias", query: "shared", want: "111111111111"},
+		{name: "alias ignoring case", query: "EDGE", want: "444444444444"},
+		{name: "ID wins over a name", query: "555555555555", want: "555555555555"},
+		{name: "alias and name of different accounts", query: "billing", wantErr: "'billing' matches 2 accounts, use the account ID instead"},
+		{name: "no match", query: "sandbox", wantErr: "no account matches 'sandbox'"},
+		{name: "names must match in full", query: "payments", want: "222222222222"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := matchAccount(testProfile, testAccounts, tt.query)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("matchAccount(%q) error = %v, want %q", tt.query, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("matchAccount(%q) error = %v", tt.query, err)
+			}
+			if got.AccountId != tt.want {
+				t.Errorf("matchAccount(%q) = %s, want %s", tt.query, got.AccountId, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchRole(t *testing.T) {
+	roles := []sso.Role{{RoleName: "AdministratorAccess"}, {RoleName: "ReadOnly"}}
+
+	tests := []struct {
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{query: "ReadOnly", want: "ReadOnly"},
+		{query: "administratoraccess", want: "AdministratorAccess"},
+		{query: "Read", wantErr: true},
+		{query: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := matchRole(roles, tt.query)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("matchRole(%q) = %s, want an error", tt.query, got.RoleName)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("matchRole(%q) error = %v", tt.query, err)
+			continue
+		}
+		if got.RoleName != tt.want {
+			t.Errorf("matchRole(%q) = %s, want %s", tt.query, got.RoleName, tt.want)
+		}
+	}
+}
+
+func TestAccountLabel(t *testing.T) {
+	label := accountLabel(testProfile)
+
+	tests := []struct {
+		name    string
+		account sso.Account
+		want    string
+	}{
+		{
+			name:    "no alias",
+			account: testAccounts[4],
+			want:    "Billing (555555555555)",
+		},
+		{
+			name:    "alias without environment",
+			account: testAccounts[0],
+			want:    "shared (shared-services, 111111111111)",
+		},
+		{
+			name:    "environment in the default color",
+			account: testAccounts[1],
+			want:    "payments (payments-staging, 222222222222) " + ui.ColorYellow + "[staging]" + ui.ColorReset,
+		},
+		{
+			name:    "production alias",
+			account: testAccounts[3],
+			want:    "edge (edge-network, 444444444444) " + ui.ColorRed + "[prod]" + ui.ColorReset,
+		},
+		{
+			name:    "production name pattern",
+			account: testAccounts[5],
+			want:    "payments-prod (666666666666) " + ui.ColorRed + "[prod]" + ui.ColorReset,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := label(tt.account); got != tt.want {
+				t.Errorf("accountLabel() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
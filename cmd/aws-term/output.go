@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ysaakpr/aws-term/internal/config"
+	"github.com/ysaakpr/aws-term/internal/ui"
+)
+
+// outputSchemaVersion is raised only when a field is removed or changes
+// meaning; new fields may be added without raising it
+const outputSchemaVersion = 1
+
+// jsonOutput is set by --output json: each command writes one result to
+// resultOut and everything meant for people goes to stderr
+var (
+	jsonOutput bool
+	resultOut  io.Writer = os.Stdout
+)
+
+// helperOut is set while aws-term runs as a credential helper, whose caller
+// reads errors from stdout: a failing command writes its error there
+var helperOut io.Writer
+
+// result is the object written to stdout in JSON mode
+type result struct {
+	SchemaVersion int          `json:"schema_version"`
+	Type          string       `json:"type"`
+	Data          any          `json:"data,omitempty"`
+	Error         *resultError `json:"error,omitempty"`
+}
+
+// resultError describes why a command failed. Code is stable; Message is for people.
+type resultError struct {
+	Code     string `json:"code"`
+	ExitCode int    `json:"exit_code"`
+	Message  string `json:"message"`
+}
+
+type profileResult struct {
+	Name    string `json:"name"`
+	SSOUrl  string `json:"sso_url"`
+	Region  string `json:"region,omitempty"`
+	Default bool   `json:"default"`
+}
+
+type sessionResult struct {
+	Profile         string            `json:"profile"`
+	Region          string            `json:"region"`
+	AccountId       string            `json:"account_id"`
+	AccountName     string            `json:"account_name"`
+	Alias           string            `json:"alias,omitempty"`
+	Role            string            `json:"role"`
+	Target          string            `json:"target,omitempty"`
+	AssumedRoleArn  string            `json:"assumed_role_arn,omitempty"`
+	Production      bool              `json:"production"`
+	Expiration      time.Time         `json:"expiration"`
+	CredentialsFile string            `json:"credentials_file,omitempty"`
+	Credentials     credentialsResult `json:"credentials"`
+}
+
+type credentialsResult struct {
+	AccessKeyId     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	SessionToken    string `json:"session_token"`
+}
+
+type consoleResult struct {
+	AccountId string `json:"account_id"`
+	Role      string `json:"role"`
+	URL       string `json:"url"`
+	Opened    bool   `json:"opened"`
+}
+
+type kubeconfigResult struct {
+	User    string `json:"user"`
+	Path    string `json:"path"`
+	Context string `json:"context,omitempty"`
+}
+
+// globalFlags are the flags that apply to every command
+type globalFlags struct {
+	Output string
+	Quiet  bool
+}
+
+// parseGlobalFlags removes --output FORMAT and --quiet (-q) from args
+// wherever they appear, so they work before or after a subcommand
+func parseGlobalFlags(args []string) ([]string, globalFlags, error) {
+	flags := globalFlags{Output: "text"}
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			rest = append(rest, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		switch name {
+		case "output":
+			if !hasValue {
+				if i+1 >= len(args) {
+					return nil, flags, fmt.Errorf("flag needs an argument: --output")
+				}
+				i++
+				value = args[i]
+			}
+			flags.Output = value
+		case "quiet", "q":
+			quiet := true
+			if hasValue {
+				var err error
+				if quiet, err = strconv.ParseBool(value); err != nil {
+					return nil, flags, fmt.Errorf("invalid value '%s' for --quiet", value)
+				}
+			}
+			flags.Quiet = quiet
+		default:
+			rest = append(rest, arg)
+		}
+	}
+
+	if flags.Output != "text" && flags.Output != "json" {
+		return nil, flags, fmt.Errorf("unknown output format '%s' (use text or json)", flags.Output)
+	}
+	return rest, flags, nil
+}
+
+// firstArg returns the first argument, or "" if there is none
+func firstArg(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
+
+// enableJSONOutput keeps stdout for results and sends all other output to stderr
+func enableJSONOutput() {
+	jsonOutput = true
+	resultOut = os.Stdout
+	os.Stdout = os.Stderr
+}
+
+// writeResult writes a result of the given type in JSON mode; otherwise it does nothing
+func writeResult(resultType string, data any) {
+	if !jsonOutput {
+		return
+	}
+	enc := json.NewEncoder(resultOut)
+	enc.SetIndent("", "  ")
+	enc.Encode(result{SchemaVersion: outputSchemaVersion, Type: resultType, Data: data})
+}
+
+// exit exits with code, first reporting the error for a non-zero code
+func exit(code int) {
+	if code != 0 {
+		writeError(code)
+	}
+	os.Exit(code)
+}
+
+// writeError reports a failed command where its caller looks for errors: as
+// a line on helperOut for a credential helper, or as an error result in JSON
+// mode. The message is the last error printed with ui.PrintError.
+func writeError(code int) {
+	message := ui.LastError()
+	if code == exitCancelled {
+		message = ui.ErrCancelled.Error()
+	} else if message == "" {
+		message = "command failed"
+	}
+
+	switch {
+	case helperOut != nil:
+		fmt.Fprintln(helperOut, message)
+	case jsonOutput:
+		enc := json.NewEncoder(resultOut)
+		enc.SetIndent("", "  ")
+		enc.Encode(result{
+			SchemaVersion: outputSchemaVersion,
+			Type:          "error",
+			Error:         &resultError{Code: errorCode(code), ExitCode: code, Message: message},
+		})
+	}
+}
+
+// errorCode names an exit code for the error result
+func errorCode(exitCode int) string {
+	switch exitCode {
+	case exitAccessDenied:
+		return "access_denied"
+	case exitLoginExpired:
+		return "login_expired"
+	case exitInvalidClient:
+		return "invalid_client"
+	case exitCancelled:
+		return "cancelled"
+	default:
+		return "error"
+	}
+}
+
+func newProfileResult(p *config.Profile) profileResult {
+	return profileResult{Name: p.Name, SSOUrl: p.SSOUrl, Region: p.Region, Default: p.Default}
+}
+
+func newSessionResult(sess *session, credFile string) sessionResult {
+	creds := sess.Credentials
+	res := sessionResult{
+		Profile:         sess.Profile.Name,
+		Region:          sess.Client.Region,
+		AccountId:       sess.Account.AccountId,
+		AccountName:     sess.Account.AccountName,
+		Role:            sess.Role.RoleName,
+		Target:          sess.Target,
+		AssumedRoleArn:  sess.AssumedRoleArn,
+		Production:      sess.IsProduction,
+		Expiration:      creds.Expiration.UTC(),
+		CredentialsFile: credFile,
+		Credentials: credentialsResult{
+			AccessKeyId:     creds.AccessKeyId,
+			SecretAccessKey: creds.SecretAccessKey,
+			SessionToken:    creds.SessionToken,
+		},
+	}
+	if alias, ok := sess.Profile.AliasFor(sess.Account.AccountId); ok {
+		res.Alias = alias.Name
+	}
+	return res
+}
+
+func newConsoleResult(sess *session, url string, opened bool) consoleResult {
+	return consoleResult{AccountId: sess.Account.AccountId, Role: sess.Role.RoleName, URL: url, Opened: opened}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ysaakpr/aws-term/internal/ui"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestParseGlobalFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		rest    []string
+		flags   globalFlags
+		wantErr string
+	}{
+		{
+			name:  "no flags",
+			args:  []string{"work"},
+			rest:  []string{"work"},
+			flags: globalFlags{Output: "text"},
+		},
+		{
+			name:  "before the subcommand",
+			args:  []string{"--output", "json", "-q", "console", "work"},
+			rest:  []string{"console", "work"},
+			flags: globalFlags{Output: "json", Quiet: true},
+		},
+		{
+			name:  "after the subcommand",
+			args:  []string{"console", "--account", "payments", "--output=json", "work", "--quiet"},
+			rest:  []string{"console", "--account", "payments", "work"},
+			flags: globalFlags{Output: "json", Quiet: true},
+		},
+		{
+			name:  "single dash and explicit quiet",
+			args:  []string{"-output=text", "--quiet=false", "--list"},
+			rest:  []string{"--list"},
+			flags: globalFlags{Output: "text"},
+		},
+		{
+			name:  "stops at double dash",
+			args:  []string{"exec", "--output=json", "--", "kubectl", "--output", "yaml", "-q"},
+			rest:  []string{"exec", "--", "kubectl", "--output", "yaml", "-q"},
+			flags: globalFlags{Output: "json"},
+		},
+		{
+			name:    "unknown format",
+			args:    []string{"--output", "yaml"},
+			wantErr: "unknown output format 'yaml' (use text or json)",
+		},
+		{
+			name:    "missing format",
+			args:    []string{"work", "--output"},
+			wantErr: "flag needs an argument: --output",
+		},
+		{
+			name:    "bad quiet value",
+			args:    []string{"--quiet=maybe"},
+			wantErr: "invalid value 'maybe' for --quiet",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rest, flags, err := parseGlobalFlags(tt.args)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("parseGlobalFlags() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseGlobalFlags() error = %v", err)
+			}
+			if !reflect.DeepEqual(rest, tt.rest) {
+				t.Errorf("parseGlobalFlags() args = %q, want %q", rest, tt.rest)
+			}
+			if flags != tt.flags {
+				t.Errorf("parseGlobalFlags() flags = %+v, want %+v", flags, tt.flags)
+			}
+		})
+	}
+}
+
+// captureResults sends JSON results to a buffer for the rest of the test
+func captureResults(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var out bytes.Buffer
+	savedJSON, savedOut := jsonOutput, resultOut
+	jsonOutput, resultOut = true, &out
+	t.Cleanup(func() { jsonOutput, resultOut = savedJSON, savedOut })
+	return &out
+}
+
+// checkGolden compares got with testdata/name, rewriting the file with -update
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s changed:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+func TestWriteResultGolden(t *testing.T) {
+	out := captureResults(t)
+
+	writeResult("session", sessionResult{
+		Profile:     "work",
+		Region:      "eu-west-1",
+		AccountId:   "111111111111",
+		AccountName: "payments-prod",
+		Alias:       "payments",
+		Role:        "ReadOnly",
+		Production:  true,
+		Expiration:  time.Date(2026, 3, 1, 13, 0, 0, 0, time.UTC),
+		Credentials: credentialsResult{
+			AccessKeyId:     "ASIAEXAMPLE",
+			SecretAccessKey: "secret",
+			SessionToken:    "token",
+		},
+	})
+	checkGolden(t, "session.json", out.Bytes())
+
+	out.Reset()
+	writeResult("ok", nil)
+	checkGolden(t, "ok.json", out.Bytes())
+}
+
+func TestWriteErrorGolden(t *testing.T) {
+	out := captureResults(t)
+
+	ui.PrintError("Sign-in was denied in the browser. Run aws-term again to retry.")
+	writeError(exitAccessDenied)
+	checkGolden(t, "error.json", out.Bytes())
+
+	// A cancelled command reports the cancellation, not the last error
+	out.Reset()
+	writeError(exitCancelled)
+	checkGolden(t, "cancelled.json", out.Bytes())
+}
+
+func TestWriteResultText(t *testing.T) {
+	var out bytes.Buffer
+	saved := resultOut
+	resultOut = &out
+	t.Cleanup(func() { resultOut = saved })
+
+	writeResult("ok", nil)
+	if out.Len() != 0 {
+		t.Errorf("writeResult() in text mode wrote %q", out.String())
+	}
+}
+
+func TestWriteErrorHelper(t *testing.T) {
+	results := captureResults(t)
+	var out bytes.Buffer
+	helperOut = &out
+	t.Cleanup(func() { helperOut = nil })
+
+	// A credential helper's caller reads a plain message from stdout, even with --output json
+	ui.PrintError("Failed to select target: no target named 'ci-reader'")
+	writeError(1)
+	if got, want := out.String(), "Failed to select target: no target named 'ci-reader'\n"; got != want {
+		t.Errorf("helper output = %q, want %q", got, want)
+	}
+	if results.Len() != 0 {
+		t.Errorf("writeError() in helper mode wrote a JSON result %q", results.String())
+	}
+}
@@ -0,0 +1,546 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ysaakpr/aws-term/internal/browser"
+	"github.com/ysaakpr/aws-term/internal/cache"
+	"github.com/ysaakpr/aws-term/internal/config"
+	"github.com/ysaakpr/aws-term/internal/history"
+	"github.com/ysaakpr/aws-term/internal/rolechain"
+	"github.com/ysaakpr/aws-term/internal/sso"
+	"github.com/ysaakpr/aws-term/internal/ui"
+)
+
+// sessionFlags are the login and selection flags shared by the shell and the
+// subcommands that need credentials
+type sessionFlags struct {
+	Region        *string
+	Account       *string
+	Role          *string
+	Target        *string
+	Flat          *bool
+	NoBrowser     *bool
+	ChooseBrowser *bool
+	QRCode        *bool
+	Force         *bool
+	Refresh       *bool
+	Login         *bool
+}
+
+// addSessionFlags registers the session flags on fs
+func addSessionFlags(fs *flag.FlagSet) *sessionFlags {
+	return &sessionFlags{
+		Region:        fs.String("region", "", "AWS region for SSO (default: us-east-1)"),
+		Account:       fs.String("account", "", "Account to use, by ID, name or alias"),
+		Role:          fs.String("role", "", "Role to use in the selected account"),
+		Target:        fs.String("target", "", "Named target from the profile: account, role and optional role chain"),
+		Flat:          fs.Bool("flat", false, "Pick account and role from a single list"),
+		NoBrowser:     fs.Bool("no-browser", false, "Print the login URL and code instead of opening a browser"),
+		ChooseBrowser: fs.Bool("choose-browser", false, "Pick the browser again instead of using the remembered one"),
+		QRCode:        fs.Bool("qr", false, "Show the login URL as a QR code in the terminal"),
+		Force:         fs.Bool("force", false, "Allow roles that are not allowed for production accounts"),
+		Refresh:       fs.Bool("refresh", false, "Ignore cached accounts and roles and fetch them again"),
+		Login:         fs.Bool("login", false, "Sign in again even if the cached SSO login is still valid"),
+	}
+}
+
+// session is a signed-in profile with the selected account, role and credentials
+type session struct {
+	Profile      *config.Profile
+	Client       *sso.SSOClient
+	Account      *sso.Account
+	Role         *sso.Role
+	Credentials  *sso.Credentials
+	IsProduction bool
+
+	// Target is the name of the target used, if any. AssumedRoleArn is the
+	// role at the end of its role chain, if it has one.
+	Target         string
+	AssumedRoleArn string
+
+	// Browser is the browser used for login, or "" when the cached login was
+	// used or no browser was opened
+	Browser        string
+	BrowserOptions browser.Options
+}
+
+// Label formats the session's account for display
+func (s *session) Label() string {
+	return accountLabel(s.Profile)(*s.Account)
+}
+
+// selectProfile picks the profile to use: the one named, the only one, the
+// default, or one chosen interactively. With no profiles it prompts for a new one.
+func selectProfile(cfg *config.Config, profileName string) *config.Profile {
+	var selectedProfile *config.Profile
+
+	if profileName != "" {
+		// User specified a profile name
+		selectedProfile = cfg.GetProfileByName(profileName)
+		if selectedProfile == nil {
+			ui.PrintError(fmt.Sprintf("Profile '%s' not found", profileName))
+			ui.PrintInfo("Use --list to see available profiles or --add to create a new one")
+			exit(1)
+		}
+	} else if len(cfg.Profiles) == 0 {
+		// No profiles configured, prompt for new one
+		selectedProfile = promptNewProfile(cfg)
+		if selectedProfile == nil {
+			exit(1)
+		}
+	} else if len(cfg.Profiles) == 1 {
+		// Only one profile, use it
+		selectedProfile = &cfg.Profiles[0]
+		ui.PrintInfo(fmt.Sprintf("Using profile: %s", selectedProfile.Name))
+	} else {
+		// Multiple profiles, check for default or prompt selection
+		defaultProfile := cfg.GetDefaultProfile()
+		if defaultProfile != nil {
+			ui.PrintInfo(fmt.Sprintf("Using default profile: %s", defaultProfile.Name))
+			selectedProfile = defaultProfile
+		} else {
+			// No default, show selection
+			selected, err := ui.SelectProfile(cfg.Profiles)
+			if err != nil {
+				exitIfCancelled(err)
+				ui.PrintError(fmt.Sprintf("Failed to select profile: %v", err))
+				exit(1)
+			}
+			selectedProfile = selected
+		}
+	}
+
+	return selectedProfile
+}
+
+// loginBrowser picks the browser to open for the profile, or returns "" when
+// --no-browser is given or no display is available
+func loginBrowser(profile *config.Profile, flags *sessionFlags) string {
+	if *flags.NoBrowser {
+		return ""
+	}
+	if browser.IsHeadless() {
+		ui.PrintInfo("No display detected, printing the URL instead of opening a browser")
+		return ""
+	}
+	return selectProfileBrowser(profile.Name, profile.Browser, *flags.ChooseBrowser)
+}
+
+// sessionRegion returns the SSO region: --region, the profile's region, or
+// the one in the start URL
+func sessionRegion(profile *config.Profile, flags *sessionFlags) string {
+	if *flags.Region != "" {
+		return *flags.Region
+	}
+	if profile.Region != "" {
+		return profile.Region
+	}
+	return sso.ExtractRegionFromURL(profile.SSOUrl)
+}
+
+// portalLogin keeps the SSO client signed in to a profile. A cached token
+// the portal rejects (revoked, or signed out elsewhere) is dropped and the
+// user signs in again.
+type portalLogin struct {
+	profile     *config.Profile
+	flags       *sessionFlags
+	client      *sso.SSOClient
+	region      string
+	token       *cache.Token
+	browserOpts browser.Options
+
+	// cached is set while the client uses the cached token
+	cached bool
+
+	// browser is the browser opened to sign in, if any
+	browser string
+}
+
+// start uses the cached token while it is valid, otherwise it signs in.
+// It exits on failure.
+func (l *portalLogin) start(ctx context.Context) {
+	if !*l.flags.Login && l.token.Valid(l.region) {
+		l.client.SetAccessToken(l.token.AccessToken, l.token.ExpiresAt)
+		l.cached = true
+		ui.PrintInfo(fmt.Sprintf("Using cached SSO login (expires %s, --login to sign in again)", l.token.ExpiresAt.Local().Format(time.Kitchen)))
+		return
+	}
+	l.signIn(ctx)
+}
+
+// signIn runs the device authorization flow and caches the new token. It
+// exits on failure.
+func (l *portalLogin) signIn(ctx context.Context) {
+	// Pick a browser, unless running headless where the login URL is only printed
+	l.browser = loginBrowser(l.profile, l.flags)
+	loginCtx, cancelLogin := context.WithCancel(ctx)
+	display := newLoginDisplay(l.browser, l.browserOpts, *l.flags.QRCode, cancelLogin)
+	l.client.OnEvent = display.Handle
+
+	err := l.client.Authenticate(loginCtx)
+	display.Close()
+	cancelLogin()
+	if err != nil {
+		exitLoginFailed(err)
+	}
+	l.cached = false
+
+	l.token.Region = l.region
+	l.token.AccessToken, l.token.ExpiresAt = l.client.AccessToken()
+	if err := l.token.Save(); err != nil {
+		ui.PrintError(fmt.Sprintf("Failed to cache login: %v", err))
+	}
+}
+
+// retry runs a portal call. If the portal rejected the cached token, the
+// token is dropped and the call runs once more after signing in again.
+func (l *portalLogin) retry(ctx context.Context, call func() error) error {
+	err := call()
+	if err == nil || !l.cached || !sso.IsUnauthorized(err) {
+		return err
+	}
+
+	ui.PrintWarning("The cached SSO login was rejected, signing in again")
+	if err := l.token.Delete(); err != nil {
+		ui.PrintError(fmt.Sprintf("Failed to remove cached login: %v", err))
+	}
+	l.signIn(ctx)
+	return call()
+}
+
+// startSession signs in to the profile (or reuses its cached login), selects an account and role (from the
+// flags or the pickers), applies the production guards and gets credentials.
+// It exits on failure.
+func startSession(ctx context.Context, selectedProfile *config.Profile, flags *sessionFlags) *session {
+	// A target supplies the account and role unless they are given explicitly
+	accountQuery, roleQuery := *flags.Account, *flags.Role
+	var target *config.Target
+	if *flags.Target != "" {
+		var err error
+		target, err = selectedProfile.TargetByName(*flags.Target)
+		if err != nil {
+			ui.PrintError(fmt.Sprintf("Failed to select target: %v", err))
+			exit(1)
+		}
+		if accountQuery == "" {
+			accountQuery = target.Account
+		}
+		if roleQuery == "" {
+			roleQuery = target.Role
+		}
+	}
+
+	region := sessionRegion(selectedProfile, flags)
+	ssoClient := sso.NewSSOClient(selectedProfile.SSOUrl, region)
+	browserOpts := browserOptions(selectedProfile.Browser)
+
+	// Reuse the cached SSO login while it is valid, otherwise sign in with
+	// the device authorization flow and cache the new token
+	token, err := cache.LoadToken(selectedProfile.SSOUrl)
+	if err != nil {
+		ui.PrintError(fmt.Sprintf("Failed to load cached login: %v", err))
+	}
+	login := &portalLogin{profile: selectedProfile, flags: flags, client: ssoClient, region: region, token: token, browserOpts: browserOpts}
+	login.start(ctx)
+
+	// Load the cached account and role directory for this start URL
+	dir, err := cache.LoadDirectory(selectedProfile.SSOUrl)
+	if err != nil {
+		ui.PrintError(fmt.Sprintf("Failed to load account cache: %v", err))
+	}
+
+	// List available accounts. A cached list is shown straight away; once it
+	// is stale it is refreshed in the background while the user picks.
+	var accounts []sso.Account
+	var refreshedAccounts <-chan []sso.Account
+
+	switch {
+	case *flags.Refresh || !dir.HasAccounts():
+		ui.PrintInfo("Fetching available accounts...")
+		err = login.retry(ctx, func() (err error) {
+			accounts, err = ssoClient.ListAccounts(ctx)
+			return err
+		})
+		if err != nil {
+			exitIfCancelled(err)
+			ui.PrintError(fmt.Sprintf("Failed to list accounts: %v", err))
+			exit(1)
+		}
+		dir.SetAccounts(accounts)
+		if err := dir.Save(); err != nil {
+			ui.PrintError(fmt.Sprintf("Failed to save account cache: %v", err))
+		}
+	case !dir.AccountsFresh(cache.DefaultAccountsTTL):
+		accounts = dir.Accounts
+		refreshedAccounts = refreshAccounts(ctx, ssoClient)
+	default:
+		accounts = dir.Accounts
+	}
+
+	if len(accounts) == 0 {
+		ui.PrintError("No accounts available for this SSO configuration")
+		exit(1)
+	}
+
+	// Load selection history and favorites to order the pickers. A history
+	// that failed to load is not saved, so it cannot overwrite the file.
+	hist, histErr := history.Load()
+	if histErr != nil {
+		ui.PrintError(fmt.Sprintf("Failed to load selection history: %v", histErr))
+	}
+	favs, err := history.LoadFavorites()
+	if err != nil {
+		ui.PrintError(fmt.Sprintf("Failed to load favorites: %v", err))
+	}
+
+	var selectedAccount *sso.Account
+	var selectedRole *sso.Role
+	label := accountLabel(selectedProfile)
+
+	if *flags.Flat && accountQuery == "" {
+		// Pick account and role in a single step from all prefetched roles
+		roleMap := prefetchRoles(ctx, login, accounts, dir, *flags.Refresh)
+		pairs := sso.FlattenAccountRoles(accounts, roleMap)
+		if roleQuery != "" {
+			pairs = filterPairsByRole(pairs, roleQuery)
+		}
+		if len(pairs) == 0 {
+			ui.PrintError("No roles available for this SSO configuration")
+			exit(1)
+		}
+
+		selected, err := ui.SelectAccountRoleFromGroups(ui.AccountRoleGroups(history.AccountRoleSections(selectedProfile.Name, pairs, hist, favs)), label)
+		if err != nil {
+			exitIfCancelled(err)
+			ui.PrintError(fmt.Sprintf("Failed to select role: %v", err))
+			exit(1)
+		}
+		selectedAccount, selectedRole = &selected.Account, &selected.Role
+	} else {
+		// Select account, directly if one was named on the command line
+		var rolesCh <-chan map[string][]sso.Role
+		if accountQuery != "" {
+			selectedAccount, err = matchAccount(selectedProfile, accounts, accountQuery)
+			if err == nil {
+				ui.PrintInfo(fmt.Sprintf("Using account: %s", label(*selectedAccount)))
+			}
+		} else {
+			// Prefetch the roles of every account while the user picks one,
+			// unless the cached roles are still fresh
+			if *flags.Refresh || !dir.RolesFresh(cache.DefaultRolesTTL) {
+				prefetchCtx, stopPrefetch := context.WithCancel(ctx)
+				defer stopPrefetch()
+				rolesCh = prefetchRolesInBackground(prefetchCtx, ssoClient, accounts)
+			}
+			selectedAccount, err = ui.SelectAccountFromGroups(ui.AccountGroups(history.AccountSections(selectedProfile.Name, accounts, hist, favs)), label)
+		}
+		if err != nil {
+			exitIfCancelled(err)
+			ui.PrintError(fmt.Sprintf("Failed to select account: %v", err))
+			exit(1)
+		}
+
+		// List roles for the selected account, preferring prefetched roles
+		roles, cached := dir.RolesFor(selectedAccount.AccountId, cache.DefaultRolesTTL)
+		if *flags.Refresh {
+			cached = false
+		}
+		if roleMap, ok := prefetchedRoles(rolesCh); ok {
+			dir.SetRoles(roleMap)
+			if err := dir.Save(); err != nil {
+				ui.PrintError(fmt.Sprintf("Failed to save role cache: %v", err))
+			}
+			roles, cached = roleMap[selectedAccount.AccountId]
+		}
+		if !cached {
+			ui.PrintInfo(fmt.Sprintf("Fetching roles for %s...", selectedProfile.AccountDisplayName(selectedAccount.AccountId, selectedAccount.AccountName)))
+			err = login.retry(ctx, func() (err error) {
+				roles, err = ssoClient.ListRoles(ctx, selectedAccount.AccountId)
+				return err
+			})
+			if err != nil {
+				exitIfCancelled(err)
+				ui.PrintError(fmt.Sprintf("Failed to list roles: %v", err))
+				exit(1)
+			}
+			dir.SetAccountRoles(selectedAccount.AccountId, roles)
+			if err := dir.Save(); err != nil {
+				ui.PrintError(fmt.Sprintf("Failed to save role cache: %v", err))
+			}
+		}
+
+		if len(roles) == 0 {
+			ui.PrintError("No roles available for this account")
+			exit(1)
+		}
+
+		// Select role, directly if one was named on the command line
+		if roleQuery != "" {
+			selectedRole, err = matchRole(roles, roleQuery)
+			if err == nil {
+				ui.PrintInfo(fmt.Sprintf("Using role: %s", selectedRole.RoleName))
+			}
+		} else {
+			selectedRole, err = ui.SelectRoleFromGroups(ui.RoleGroups(history.RoleSections(selectedProfile.Name, selectedAccount.AccountId, roles, hist, favs)))
+		}
+		if err != nil {
+			exitIfCancelled(err)
+			ui.PrintError(fmt.Sprintf("Failed to select role: %v", err))
+			exit(1)
+		}
+	}
+
+	// Store the account list refreshed in the background for the next run.
+	// A refresh that has not finished yet is not waited for; the cache stays
+	// stale and a later run refreshes it again.
+	if fresh, ok := refreshedAccountList(refreshedAccounts); ok {
+		dir.SetAccounts(fresh)
+		if err := dir.Save(); err != nil {
+			ui.PrintError(fmt.Sprintf("Failed to save account cache: %v", err))
+		}
+	}
+
+	// Production accounts and targets need an allowed role and typed confirmation
+	isProduction, err := selectedProfile.IsProduction(selectedAccount.AccountId, selectedAccount.AccountName)
+	if err != nil {
+		ui.PrintError(fmt.Sprintf("Failed to check production rules: %v", err))
+		exit(1)
+	}
+	// A target tagged as production gets the guards too, as its role chain
+	// may lead into a production account
+	productionTarget := ""
+	if !isProduction && target != nil && target.IsProduction() {
+		isProduction, productionTarget = true, *flags.Target
+	}
+	if isProduction {
+		confirmProduction(selectedProfile, selectedAccount, selectedRole.RoleName, productionTarget, *flags.Force)
+	}
+
+	// Remember the selection so it is offered first next time
+	if histErr == nil {
+		hist.Record(history.Entry{
+			Profile:     selectedProfile.Name,
+			AccountId:   selectedAccount.AccountId,
+			AccountName: selectedAccount.AccountName,
+			RoleName:    selectedRole.RoleName,
+		})
+		if err := hist.Save(); err != nil {
+			ui.PrintError(fmt.Sprintf("Failed to save selection history: %v", err))
+		}
+	}
+
+	// Get credentials for the selected role
+	ui.PrintInfo("Getting credentials...")
+	var creds *sso.Credentials
+	err = login.retry(ctx, func() (err error) {
+		creds, err = ssoClient.GetRoleCredentials(ctx, selectedAccount.AccountId, selectedRole.RoleName)
+		return err
+	})
+	if err != nil {
+		exitIfCancelled(err)
+		ui.PrintError(fmt.Sprintf("Failed to get credentials: %v", err))
+		exit(1)
+	}
+
+	sess := &session{
+		Profile:        selectedProfile,
+		Client:         ssoClient,
+		Account:        selectedAccount,
+		Role:           selectedRole,
+		Credentials:    creds,
+		IsProduction:   isProduction,
+		Browser:        login.browser,
+		BrowserOptions: browserOpts,
+	}
+
+	// Continue along the target's role chain with the SSO role's credentials
+	if target != nil {
+		sess.Target = *flags.Target
+		if len(target.Chain) > 0 {
+			assumeRoleChain(ctx, sess, target.Chain, region)
+		}
+	}
+
+	return sess
+}
+
+// assumeRoleChain assumes the roles of a target's chain and replaces the
+// session's credentials with those of the last role. It exits on failure.
+func assumeRoleChain(ctx context.Context, sess *session, chain []config.RoleHop, region string) {
+	ui.PrintInfo(fmt.Sprintf("Assuming %d chained role(s)...", len(chain)))
+
+	result, err := rolechain.Assume(ctx, sess.Credentials, chain, rolechain.Options{
+		Region:   region,
+		Endpoint: sess.Profile.STSEndpoint,
+		Vars: map[string]string{
+			"profile":    sess.Profile.Name,
+			"target":     sess.Target,
+			"account_id": sess.Account.AccountId,
+			"role":       sess.Role.RoleName,
+		},
+		MFACode: func(serial string) (string, error) {
+			if !ui.IsTerminal(os.Stdin) {
+				return "", fmt.Errorf("an MFA code is needed for %s, but stdin is not a terminal", serial)
+			}
+			code := ui.PromptInput(fmt.Sprintf("MFA code for %s", serial))
+			if code == "" {
+				return "", fmt.Errorf("no MFA code entered")
+			}
+			return code, nil
+		},
+	})
+	if err != nil {
+		exitIfCancelled(err)
+		ui.PrintError(fmt.Sprintf("Failed to assume role chain: %v", err))
+		exit(1)
+	}
+
+	sess.Credentials = result.Credentials
+	sess.AssumedRoleArn = result.AssumedRoleArn
+}
+
+// credentialsKey identifies the selection made by flags in the credentials cache
+func credentialsKey(profile *config.Profile, flags *sessionFlags) string {
+	return strings.Join([]string{profile.Name, sessionRegion(profile, flags), *flags.Target, *flags.Account, *flags.Role}, "|")
+}
+
+// startCachedSession is startSession for commands other tools run on every
+// request (eks-token, docker-credential): the credentials are cached per
+// selection and reused until shortly before they expire
+func startCachedSession(ctx context.Context, profile *config.Profile, flags *sessionFlags) *session {
+	region := sessionRegion(profile, flags)
+	cached, err := cache.LoadRoleCredentials(profile.SSOUrl, credentialsKey(profile, flags))
+	if err != nil {
+		ui.PrintError(fmt.Sprintf("Failed to load cached credentials: %v", err))
+	}
+	if !*flags.Login && !*flags.Refresh && cached.Valid() {
+		ui.PrintInfo(fmt.Sprintf("Using cached credentials (expire %s)", cached.Credentials.Expiration.Local().Format(time.Kitchen)))
+		return &session{
+			Profile:        profile,
+			Client:         sso.NewSSOClient(profile.SSOUrl, region),
+			Account:        &cached.Account,
+			Role:           &cached.Role,
+			Credentials:    &cached.Credentials,
+			IsProduction:   cached.IsProduction,
+			Target:         *flags.Target,
+			AssumedRoleArn: cached.AssumedRoleArn,
+			BrowserOptions: browserOptions(profile.Browser),
+		}
+	}
+
+	sess := startSession(ctx, profile, flags)
+
+	cached.Account, cached.Role = *sess.Account, *sess.Role
+	cached.AssumedRoleArn, cached.IsProduction = sess.AssumedRoleArn, sess.IsProduction
+	cached.Credentials = *sess.Credentials
+	if err := cached.Save(); err != nil {
+		ui.PrintError(fmt.Sprintf("Failed to cache credentials: %v", err))
+	}
+	return sess
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ysaakpr/aws-term/internal/ui"
+)
+
+// exitCancelled is the exit code when the user cancels, as a shell reports for SIGINT
+const exitCancelled = 130
+
+// cancelGrace is how long a cancelled run may take to wind down before it is
+// stopped, e.g. when it is blocked reading a prompt
+const cancelGrace = 2 * time.Second
+
+// signalContext returns a context that is cancelled on SIGINT or SIGTERM, so
+// network calls and the login poll loop stop and their callers exit with
+// exitCancelled. If the run has not exited after cancelGrace, or a second
+// signal arrives, the terminal is restored and the process exits. stop
+// returns signal handling to the default, e.g. before starting a shell.
+func signalContext() (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			signal.Stop(signals)
+			close(done)
+		})
+	}
+
+	go func() {
+		select {
+		case <-signals:
+		case <-done:
+			return
+		}
+		cancel()
+
+		select {
+		case <-signals:
+		case <-time.After(cancelGrace):
+		case <-done:
+			return
+		}
+		exitOnCancel()
+	}()
+
+	return ctx, stop
+}
+
+// isCancelled reports whether err comes from the user cancelling, either with
+// a signal or by quitting a picker
+func isCancelled(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, ui.ErrCancelled)
+}
+
+// exitIfCancelled exits with exitCancelled if err comes from the user cancelling
+func exitIfCancelled(err error) {
+	if isCancelled(err) {
+		exitOnCancel()
+	}
+}
+
+// exitOnCancel restores the terminal and exits with exitCancelled
+func exitOnCancel() {
+	ui.RestoreTerminal()
+	fmt.Fprintln(os.Stderr, "\nCancelled.")
+	exit(exitCancelled)
+}
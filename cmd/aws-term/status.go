@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ysaakpr/aws-term/internal/cache"
+	"github.com/ysaakpr/aws-term/internal/config"
+	"github.com/ysaakpr/aws-term/internal/history"
+	"github.com/ysaakpr/aws-term/internal/rolechain"
+	"github.com/ysaakpr/aws-term/internal/sso"
+	"github.com/ysaakpr/aws-term/internal/ui"
+)
+
+// Where the status of the credentials in the environment comes from
+const (
+	sourceSession = "session"
+	sourceSTS     = "sts"
+)
+
+type statusResult struct {
+	// Credentials is nil when there are no AWS credentials in the environment
+	Credentials *credentialsStatus `json:"credentials"`
+	Logins      []loginStatus      `json:"logins"`
+}
+
+type credentialsStatus struct {
+	Source      string     `json:"source"`
+	AccessKeyId string     `json:"access_key_id"`
+	Profile     string     `json:"profile,omitempty"`
+	Region      string     `json:"region,omitempty"`
+	AccountId   string     `json:"account_id,omitempty"`
+	AccountName string     `json:"account_name,omitempty"`
+	Role        string     `json:"role,omitempty"`
+	Target      string     `json:"target,omitempty"`
+	Arn         string     `json:"arn,omitempty"`
+	Expiration  *time.Time `json:"expiration,omitempty"`
+	Expired     bool       `json:"expired"`
+
+	// Verified is set when STS confirmed the credentials
+	Verified bool `json:"verified"`
+
+	// Error is set when STS could not be asked or rejected the credentials
+	Error string `json:"error,omitempty"`
+}
+
+type loginStatus struct {
+	Profile   string           `json:"profile"`
+	SSOUrl    string           `json:"sso_url"`
+	Region    string           `json:"region"`
+	State     cache.TokenState `json:"state"`
+	ExpiresAt *time.Time       `json:"expires_at,omitempty"`
+}
+
+// runStatusCommand handles "aws-term status" (and "aws-term whoami"): it
+// describes the AWS credentials in the environment and the cached SSO login
+// of each profile
+func runStatusCommand(args []string) {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	verify := fs.Bool("verify", false, "Ask STS who the credentials belong to even if aws-term issued them")
+	stsEndpoint := fs.String("sts-endpoint", "", "STS endpoint for GetCallerIdentity (default: $AWS_ENDPOINT_URL_STS, the profile's sts_endpoint or AWS)")
+	fs.Parse(args)
+
+	cfg, err := config.Load()
+	if err != nil {
+		cfg = &config.Config{Profiles: []config.Profile{}}
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	status := statusResult{
+		Credentials: environmentCredentials(ctx, cfg, *verify, *stsEndpoint),
+		Logins:      cachedLogins(cfg),
+	}
+
+	if jsonOutput {
+		writeResult("status", status)
+		return
+	}
+	printStatus(status)
+}
+
+// environmentCredentials describes the credentials in AWS_ACCESS_KEY_ID and
+// friends: from the session aws-term recorded when it issued them, or from
+// STS GetCallerIdentity if it did not issue them or verify is set
+func environmentCredentials(ctx context.Context, cfg *config.Config, verify bool, stsEndpoint string) *credentialsStatus {
+	creds := &sso.Credentials{
+		AccessKeyId:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyId == "" {
+		return nil
+	}
+
+	status := &credentialsStatus{Source: sourceSTS, AccessKeyId: creds.AccessKeyId}
+	sessions, _ := history.LoadSessions()
+	if s, ok := sessions.Lookup(creds.AccessKeyId); ok {
+		status.Source = sourceSession
+		status.Profile, status.Region, status.Target = s.Profile, s.Region, s.Target
+		status.AccountId, status.AccountName, status.Role = s.AccountId, s.AccountName, s.RoleName
+		status.Arn = s.AssumedRoleArn
+		status.setExpiration(s.Expiration)
+		if !verify {
+			return status
+		}
+	} else {
+		// Credentials from elsewhere may still say when they expire
+		if expiration, err := time.Parse(time.RFC3339, os.Getenv("AWS_CREDENTIAL_EXPIRATION")); err == nil {
+			status.setExpiration(expiration)
+		}
+		status.Region = firstNonEmpty(os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"))
+	}
+
+	profile := cfg.GetProfileByName(status.Profile)
+	endpoint := stsEndpoint
+	if endpoint == "" {
+		endpoint = os.Getenv("AWS_ENDPOINT_URL_STS")
+	}
+	if endpoint == "" && profile != nil {
+		endpoint = profile.STSEndpoint
+	}
+
+	identity, err := rolechain.CallerIdentity(ctx, creds, rolechain.Options{
+		Region:   firstNonEmpty(status.Region, "us-east-1"),
+		Endpoint: endpoint,
+	})
+	if err != nil {
+		exitIfCancelled(err)
+		status.Error = err.Error()
+		return status
+	}
+
+	status.Verified = true
+	status.AccountId, status.Arn = identity.AccountId, identity.Arn
+	if status.Role == "" {
+		status.Role = sso.RoleFromARN(identity.Arn)
+	}
+	if status.AccountName == "" {
+		status.Profile, status.AccountName = findAccount(cfg, identity.AccountId)
+	}
+	return status
+}
+
+func (s *credentialsStatus) setExpiration(expiration time.Time) {
+	expiration = expiration.UTC()
+	s.Expiration = &expiration
+	s.Expired = !time.Now().Before(expiration)
+}
+
+// findAccount looks for an account in the cached account lists of the
+// profiles, returning the first profile that has it and the account's name
+func findAccount(cfg *config.Config, accountId string) (string, string) {
+	for i := range cfg.Profiles {
+		profile := &cfg.Profiles[i]
+		dir, err := cache.LoadDirectory(profile.SSOUrl)
+		if err != nil {
+			continue
+		}
+		for _, account := range dir.Accounts {
+			if account.AccountId == accountId {
+				return profile.Name, profile.AccountDisplayName(account.AccountId, account.AccountName)
+			}
+		}
+	}
+	return "", ""
+}
+
+// cachedLogins reports the cached SSO login of each profile, for the region
+// the profile signs in to by default
+func cachedLogins(cfg *config.Config) []loginStatus {
+	logins := make([]loginStatus, 0, len(cfg.Profiles))
+	for _, profile := range cfg.Profiles {
+		region := profile.Region
+		if region == "" {
+			region = sso.ExtractRegionFromURL(profile.SSOUrl)
+		}
+
+		login := loginStatus{Profile: profile.Name, SSOUrl: profile.SSOUrl, Region: region, State: cache.TokenMissing}
+		if token, err := cache.LoadToken(profile.SSOUrl); err == nil {
+			login.State = token.State(region)
+			if login.State != cache.TokenMissing {
+				expiresAt := token.ExpiresAt.UTC()
+				login.ExpiresAt = &expiresAt
+			}
+		}
+		logins = append(logins, login)
+	}
+	return logins
+}
+
+func printStatus(status statusResult) {
+	fmt.Printf("\n%sCredentials in this shell:%s\n\n", ui.ColorBold, ui.ColorReset)
+	if creds := status.Credentials; creds == nil {
+		fmt.Printf("  No AWS credentials in the environment\n")
+	} else {
+		account := creds.AccountId
+		if creds.AccountName != "" {
+			account = fmt.Sprintf("%s (%s)", creds.AccountName, creds.AccountId)
+		}
+		printStatusField("Key", creds.AccessKeyId)
+		printStatusField("Account", account)
+		printStatusField("Role", creds.Role)
+		printStatusField("Target", creds.Target)
+		printStatusField("ARN", creds.Arn)
+		printStatusField("Profile", creds.Profile)
+		printStatusField("Region", creds.Region)
+		switch {
+		case creds.Expiration == nil:
+			printStatusField("Expires", "unknown")
+		case creds.Expired:
+			printStatusField("Expires", ui.ColorRed+"expired "+describeExpiry(*creds.Expiration)+ui.ColorReset)
+		default:
+			printStatusField("Expires", describeExpiry(*creds.Expiration))
+		}
+		switch {
+		case creds.Source == sourceSession && creds.Verified:
+			printStatusField("Source", "issued by aws-term, confirmed by STS")
+		case creds.Source == sourceSession:
+			printStatusField("Source", "issued by aws-term")
+		default:
+			printStatusField("Source", "STS GetCallerIdentity")
+		}
+		if creds.Error != "" {
+			fmt.Printf("  %s%s %s%s\n", ui.ColorRed, ui.Symbols.Failure, creds.Error, ui.ColorReset)
+		}
+	}
+
+	fmt.Printf("\n%sSSO logins:%s\n\n", ui.ColorBold, ui.ColorReset)
+	if len(status.Logins) == 0 {
+		fmt.Printf("  No profiles configured\n")
+	}
+	width := 0
+	for _, login := range status.Logins {
+		width = max(width, len(login.Profile))
+	}
+	for _, login := range status.Logins {
+		fmt.Printf("  %s %-*s  %s\n", ui.Symbols.Bullet, width, login.Profile, describeLogin(login))
+	}
+	fmt.Println()
+}
+
+// printStatusField prints a labelled line, skipping empty values
+func printStatusField(label, value string) {
+	if value == "" {
+		return
+	}
+	fmt.Printf("  %s%-8s%s %s\n", ui.ColorBold, label+":", ui.ColorReset, value)
+}
+
+// describeLogin colors a cached login's state and says when it expires
+func describeLogin(login loginStatus) string {
+	switch login.State {
+	case cache.TokenValid:
+		return fmt.Sprintf("%ssigned in%s, expires %s", ui.ColorGreen, ui.ColorReset, describeExpiry(*login.ExpiresAt))
+	case cache.TokenExpiring:
+		return fmt.Sprintf("%sexpiring%s, %s; the next run signs in again", ui.ColorYellow, ui.ColorReset, describeExpiry(*login.ExpiresAt))
+	case cache.TokenExpired:
+		return fmt.Sprintf("%sexpired%s %s", ui.ColorRed, ui.ColorReset, describeExpiry(*login.ExpiresAt))
+	case cache.TokenOtherRegion:
+		return fmt.Sprintf("%ssigned in to another region%s", ui.ColorYellow, ui.ColorReset)
+	default:
+		return "not signed in"
+	}
+}
+
+// describeExpiry says how long until t, or how long ago it was
+func describeExpiry(t time.Time) string {
+	d := time.Until(t)
+	if d <= 0 {
+		return fmt.Sprintf("%s ago", formatDuration(-d))
+	}
+	return fmt.Sprintf("in %s (%s)", formatDuration(d), t.Local().Format(time.RFC1123))
+}
+
+// formatDuration formats d to the minute, e.g. 1h5m, or in seconds below a minute
+func formatDuration(d time.Duration) string {
+	if d < time.Minute {
+		return d.Round(time.Second).String()
+	}
+	s := strings.TrimSuffix(d.Round(time.Minute).String(), "0s")
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// recordSession remembers the credentials of a session, so "aws-term status"
+// can describe them in the shell they are used in
+func recordSession(sess *session) {
+	sessions, _ := history.LoadSessions()
+	sessions.Record(history.Session{
+		AccessKeyId:    sess.Credentials.AccessKeyId,
+		Profile:        sess.Profile.Name,
+		Region:         sess.Client.Region,
+		AccountId:      sess.Account.AccountId,
+		AccountName:    sess.Profile.AccountDisplayName(sess.Account.AccountId, sess.Account.AccountName),
+		RoleName:       sess.Role.RoleName,
+		Target:         sess.Target,
+		AssumedRoleArn: sess.AssumedRoleArn,
+		Expiration:     sess.Credentials.Expiration,
+	})
+	if err := sessions.Save(); err != nil {
+		ui.PrintError(fmt.Sprintf("Failed to record session: %v", err))
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ysaakpr/aws-term/internal/config"
+	"github.com/ysaakpr/aws-term/internal/history"
+	"github.com/ysaakpr/aws-term/internal/rolechain/ststest"
+)
+
+// setCredentialsEnv puts credentials in the environment the way an
+// exported session would, clearing the variables a test does not set
+func setCredentialsEnv(t *testing.T, vars map[string]string) {
+	t.Helper()
+	for _, name := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN",
+		"AWS_CREDENTIAL_EXPIRATION", "AWS_REGION", "AWS_DEFAULT_REGION", "AWS_ENDPOINT_URL_STS"} {
+		t.Setenv(name, vars[name])
+	}
+}
+
+func TestEnvironmentCredentials(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cfg := &config.Config{Profiles: []config.Profile{}}
+
+	live := history.Session{
+		AccessKeyId: "ASIALIVE",
+		Profile:     "work",
+		Region:      "eu-west-1",
+		AccountId:   "222222222222",
+		AccountName: "payments-prod",
+		RoleName:    "ReadOnly",
+		Expiration:  time.Now().Add(time.Hour),
+	}
+	sessions := &history.Sessions{}
+	sessions.Record(live)
+	if err := sessions.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	server := ststest.NewServer()
+	defer server.Close()
+
+	t.Run("no credentials", func(t *testing.T) {
+		setCredentialsEnv(t, nil)
+		if got := environmentCredentials(context.Background(), cfg, false, server.URL); got != nil {
+			t.Errorf("environmentCredentials() = %+v, want nil", got)
+		}
+	})
+
+	t.Run("recorded session", func(t *testing.T) {
+		setCredentialsEnv(t, map[string]string{"AWS_ACCESS_KEY_ID": "ASIALIVE", "AWS_SECRET_ACCESS_KEY": "secret", "AWS_REGION": "us-west-2"})
+		before := len(server.Requests())
+
+		got := environmentCredentials(context.Background(), cfg, false, server.URL)
+		if got.Source != sourceSession || got.Profile != "work" || got.Region != "eu-west-1" ||
+			got.AccountId != "222222222222" || got.AccountName != "payments-prod" || got.Role != "ReadOnly" {
+			t.Errorf("environmentCredentials() = %+v, want the recorded session", got)
+		}
+		if got.Expiration == nil || !got.Expiration.Equal(live.Expiration) || got.Expired {
+			t.Errorf("expiration = %v, expired %v; want %v, not expired", got.Expiration, got.Expired, live.Expiration)
+		}
+		if got.Verified || len(server.Requests()) != before {
+			t.Error("environmentCredentials() asked STS without verify")
+		}
+	})
+
+	t.Run("recorded session verified", func(t *testing.T) {
+		setCredentialsEnv(t, map[string]string{"AWS_ACCESS_KEY_ID": "ASIALIVE", "AWS_SECRET_ACCESS_KEY": "secret"})
+
+		got := environmentCredentials(context.Background(), cfg, true, server.URL)
+		if got.Source != sourceSession || !got.Verified || got.Error != "" {
+			t.Fatalf("environmentCredentials() = %+v, want the session verified", got)
+		}
+		// STS has the final word on the account; the recorded role and name are kept
+		if got.AccountId != "111111111111" || got.Arn != server.CallerArn || got.Role != "ReadOnly" || got.AccountName != "payments-prod" {
+			t.Errorf("environmentCredentials() = %+v, want the STS identity with the recorded role", got)
+		}
+	})
+
+	t.Run("credentials from elsewhere", func(t *testing.T) {
+		expiration := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+		setCredentialsEnv(t, map[string]string{
+			"AWS_ACCESS_KEY_ID":         "ASIAOTHER",
+			"AWS_SECRET_ACCESS_KEY":     "secret",
+			"AWS_SESSION_TOKEN":         "token",
+			"AWS_CREDENTIAL_EXPIRATION": expiration.Format(time.RFC3339),
+			"AWS_DEFAULT_REGION":        "ap-south-1",
+		})
+
+		got := environmentCredentials(context.Background(), cfg, false, server.URL)
+		if got.Source != sourceSTS || !got.Verified || got.Region != "ap-south-1" {
+			t.Fatalf("environmentCredentials() = %+v, want credentials verified with STS in ap-south-1", got)
+		}
+		if got.AccountId != "111111111111" || got.Role != "Admin" || got.Profile != "" {
+			t.Errorf("environmentCredentials() = %+v, want account 111111111111 and role Admin from the ARN", got)
+		}
+		if got.Expiration == nil || !got.Expiration.Equal(expiration) || !got.Expired {
+			t.Errorf("expiration = %v, expired %v; want %v, expired", got.Expiration, got.Expired, expiration)
+		}
+
+		requests := server.Requests()
+		last := requests[len(requests)-1]
+		if last.Action != ststest.ActionGetCallerIdentity || last.AccessKeyId != "ASIAOTHER" {
+			t.Errorf("last STS request = %s signed with %s, want GetCallerIdentity with ASIAOTHER", last.Action, last.AccessKeyId)
+		}
+	})
+}
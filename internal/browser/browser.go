@@ -2,11 +2,37 @@ package browser
 
 import (
 	"fmt"
-	"os"
-	"os/exec"
 	"runtime"
+	"strings"
+
+	"github.com/ysaakpr/aws-term/internal/platform"
 )
 
+// CustomBrowser is the browser name used when a custom launch command is configured
+const CustomBrowser = "Custom"
+
+// Options control how a browser is launched
+type Options struct {
+	// Command is a custom launch command. A {url} placeholder is replaced with
+	// the URL; without one the URL is appended as the last argument.
+	Command string
+
+	// ChromeProfile is passed to Chromium-based browsers as --profile-directory
+	ChromeProfile string
+
+	// FirefoxProfile is passed to Firefox as -P
+	FirefoxProfile string
+
+	// Private opens an incognito/private window
+	Private bool
+
+	// NewWindow opens the URL in a new window instead of a tab
+	NewWindow bool
+
+	// UserDataDir runs Chromium-based browsers with a separate --user-data-dir
+	UserDataDir string
+}
+
 // Browser represents a detected browser
 type Browser struct {
 	Name string
@@ -15,9 +41,14 @@ type Browser struct {
 
 // DetectBrowsers finds available browsers on the system
 func DetectBrowsers() []string {
+	return DetectBrowsersFor(runtime.GOOS, SystemEnv)
+}
+
+// DetectBrowsersFor finds the browsers available in env on the given OS
+func DetectBrowsersFor(goos string, env Env) []string {
 	var browsers []string
 
-	switch runtime.GOOS {
+	switch goos {
 	case "darwin":
 		// macOS browser detection
 		chromePaths := []string{
@@ -26,50 +57,35 @@ func DetectBrowsers() []string {
 			"/Applications/Chromium.app",
 		}
 		for _, path := range chromePaths {
-			if _, err := os.Stat(path); err == nil {
+			if env.Stat(path) == nil {
 				browsers = append(browsers, "Chrome")
 				break
 			}
 		}
 
-		if _, err := os.Stat("/Applications/Safari.app"); err == nil {
+		if env.Stat("/Applications/Safari.app") == nil {
 			browsers = append(browsers, "Safari")
 		}
 
 		// Also check for Firefox and Brave
-		if _, err := os.Stat("/Applications/Firefox.app"); err == nil {
+		if env.Stat("/Applications/Firefox.app") == nil {
 			browsers = append(browsers, "Firefox")
 		}
-		if _, err := os.Stat("/Applications/Brave Browser.app"); err == nil {
+		if env.Stat("/Applications/Brave Browser.app") == nil {
 			browsers = append(browsers, "Brave")
 		}
 
 	case "linux":
-		// Linux browser detection
-		if _, err := exec.LookPath("google-chrome"); err == nil {
-			browsers = append(browsers, "Chrome")
-		} else if _, err := exec.LookPath("chromium"); err == nil {
-			browsers = append(browsers, "Chromium")
-		} else if _, err := exec.LookPath("chromium-browser"); err == nil {
-			browsers = append(browsers, "Chromium")
-		}
-
-		if _, err := exec.LookPath("firefox"); err == nil {
-			browsers = append(browsers, "Firefox")
+		// Under WSL the user's real browsers live on the Windows side
+		if platform.IsWSL(env) {
+			browsers = append(browsers, detectWSLBrowsers(env)...)
 		}
+		browsers = append(browsers, detectLinuxBrowsers(env)...)
 
 	case "windows":
 		// Windows browser detection
-		chromePaths := []string{
-			os.Getenv("LOCALAPPDATA") + "\\Google\\Chrome\\Application\\chrome.exe",
-			os.Getenv("PROGRAMFILES") + "\\Google\\Chrome\\Application\\chrome.exe",
-			os.Getenv("PROGRAMFILES(X86)") + "\\Google\\Chrome\\Application\\chrome.exe",
-		}
-		for _, path := range chromePaths {
-			if _, err := os.Stat(path); err == nil {
-				browsers = append(browsers, "Chrome")
-				break
-			}
+		if windowsChromePath(env) != "" {
+			browsers = append(browsers, "Chrome")
 		}
 
 		// Edge is always available on modern Windows
@@ -79,70 +95,253 @@ func DetectBrowsers() []string {
 	return browsers
 }
 
+// windowsChromePath returns the path of chrome.exe, or "" if it is not in a standard location
+func windowsChromePath(env Env) string {
+	chromePaths := []string{
+		env.Getenv("LOCALAPPDATA") + "\\Google\\Chrome\\Application\\chrome.exe",
+		env.Getenv("PROGRAMFILES") + "\\Google\\Chrome\\Application\\chrome.exe",
+		env.Getenv("PROGRAMFILES(X86)") + "\\Google\\Chrome\\Application\\chrome.exe",
+	}
+	for _, path := range chromePaths {
+		if env.Stat(path) == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// IsHeadless reports whether there is no graphical session to open a browser in,
+// such as an SSH session, a devcontainer or a server without a display
+func IsHeadless() bool {
+	return IsHeadlessFor(runtime.GOOS, SystemEnv)
+}
+
+// IsHeadlessFor reports whether env on the given OS has no graphical session
+func IsHeadlessFor(goos string, env Env) bool {
+	switch goos {
+	case "windows":
+		return false
+	case "darwin":
+		// macOS always has a window server, but a browser opened from an
+		// SSH session appears on the remote machine's screen
+		return env.Getenv("SSH_CONNECTION") != "" || env.Getenv("SSH_TTY") != ""
+	default:
+		// WSL opens browsers on the Windows desktop, which has no DISPLAY variable
+		if platform.IsWSL(env) {
+			return env.Getenv("SSH_CONNECTION") != ""
+		}
+		return env.Getenv("DISPLAY") == "" && env.Getenv("WAYLAND_DISPLAY") == ""
+	}
+}
+
 // OpenURL opens a URL in the specified browser
 func OpenURL(browserName, url string) error {
-	var cmd *exec.Cmd
+	return OpenURLWithOptions(browserName, url, Options{})
+}
 
-	switch runtime.GOOS {
+// OpenURLWithOptions opens a URL in the specified browser, applying the
+// profile and window options. A custom command takes precedence over the browser.
+func OpenURLWithOptions(browserName, url string, opts Options) error {
+	return OpenURLFor(runtime.GOOS, SystemEnv, browserName, url, opts)
+}
+
+// OpenURLFor opens a URL through env on the given OS
+func OpenURLFor(goos string, env Env, browserName, url string, opts Options) error {
+	if goos == "linux" && browserName == EnvBrowser && opts.Command == "" {
+		return openWithEnvBrowser(env, url)
+	}
+
+	cmdline, err := launchCommand(goos, env, browserName, url, opts)
+	if err != nil {
+		return err
+	}
+	return env.Start(cmdline[0], cmdline[1:]...)
+}
+
+// browserArgs returns the command line flags that apply opts to a browser
+func browserArgs(browserName string, opts Options) []string {
+	var args []string
+
+	switch browserName {
+	case "Chrome", "Chromium", "Brave", "Edge", "Vivaldi", "Opera":
+		if opts.UserDataDir != "" {
+			args = append(args, "--user-data-dir="+opts.UserDataDir)
+		}
+		if opts.ChromeProfile != "" {
+			args = append(args, "--profile-directory="+opts.ChromeProfile)
+		}
+		if opts.Private {
+			if browserName == "Edge" {
+				args = append(args, "--inprivate")
+			} else {
+				args = append(args, "--incognito")
+			}
+		}
+		if opts.NewWindow {
+			args = append(args, "--new-window")
+		}
+	case "Firefox":
+		if opts.FirefoxProfile != "" {
+			args = append(args, "-P", opts.FirefoxProfile)
+		}
+		if opts.Private {
+			args = append(args, "-private-window")
+		} else if opts.NewWindow {
+			args = append(args, "-new-window")
+		}
+	}
+
+	return args
+}
+
+// splitCommand splits a command template into arguments. Single and double
+// quotes group words containing spaces.
+func splitCommand(command string) []string {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+
+	for _, r := range command {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+// customCommand builds the command line for a user-supplied launch template
+func customCommand(command, url string) ([]string, error) {
+	args := splitCommand(command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("browser command is empty")
+	}
+
+	replaced := false
+	for i, arg := range args {
+		if strings.Contains(arg, "{url}") {
+			args[i] = strings.ReplaceAll(arg, "{url}", url)
+			replaced = true
+		}
+	}
+	if !replaced {
+		args = append(args, url)
+	}
+
+	return args, nil
+}
+
+// launchCommand returns the command line that opens url in a browser on the given OS
+func launchCommand(goos string, env Env, browserName, url string, opts Options) ([]string, error) {
+	if opts.Command != "" {
+		return customCommand(opts.Command, url)
+	}
+
+	var cmdline []string
+	args := append(browserArgs(browserName, opts), url)
+
+	switch goos {
 	case "darwin":
+		app := ""
 		switch browserName {
 		case "Chrome":
-			cmd = exec.Command("open", "-a", "Google Chrome", url)
+			app = "Google Chrome"
 		case "Safari":
-			cmd = exec.Command("open", "-a", "Safari", url)
+			app = "Safari"
 		case "Firefox":
-			cmd = exec.Command("open", "-a", "Firefox", url)
+			app = "Firefox"
 		case "Brave":
-			cmd = exec.Command("open", "-a", "Brave Browser", url)
+			app = "Brave Browser"
+		}
+
+		switch {
+		case app == "":
+			cmdline = []string{"open", url}
+		case len(args) > 1:
+			// Flags only reach the browser through a new "open" invocation
+			cmdline = append([]string{"open", "-n", "-a", app, "--args"}, args...)
 		default:
-			cmd = exec.Command("open", url)
+			cmdline = []string{"open", "-a", app, url}
 		}
 
 	case "linux":
-		switch browserName {
-		case "Chrome":
-			cmd = exec.Command("google-chrome", url)
-		case "Chromium":
-			if _, err := exec.LookPath("chromium"); err == nil {
-				cmd = exec.Command("chromium", url)
+		switch {
+		case IsWindowsBrowser(browserName):
+			cmdline = wslCommand(env, browserName, url, opts)
+		case browserName == SystemDefault:
+			cmdline = []string{"xdg-open", url}
+		default:
+			if browserCmd := linuxBrowserCommand(env, browserName); browserCmd != nil {
+				cmdline = append(browserCmd, args...)
 			} else {
-				cmd = exec.Command("chromium-browser", url)
+				cmdline = []string{"xdg-open", url}
 			}
-		case "Firefox":
-			cmd = exec.Command("firefox", url)
-		default:
-			cmd = exec.Command("xdg-open", url)
 		}
 
 	case "windows":
 		switch browserName {
 		case "Chrome":
-			chromePaths := []string{
-				os.Getenv("LOCALAPPDATA") + "\\Google\\Chrome\\Application\\chrome.exe",
-				os.Getenv("PROGRAMFILES") + "\\Google\\Chrome\\Application\\chrome.exe",
-				os.Getenv("PROGRAMFILES(X86)") + "\\Google\\Chrome\\Application\\chrome.exe",
-			}
-			for _, path := range chromePaths {
-				if _, err := os.Stat(path); err == nil {
-					cmd = exec.Command(path, url)
-					break
-				}
+			if path := windowsChromePath(env); path != "" {
+				cmdline = append([]string{path}, args...)
+			} else {
+				// Not in a standard location; let Windows resolve chrome from its App Paths
+				cmdline = cmdStart(append([]string{"", "chrome"}, args...)...)
 			}
 		case "Edge":
-			cmd = exec.Command("cmd", "/c", "start", "microsoft-edge:"+url)
+			if len(args) > 1 {
+				cmdline = cmdStart(append([]string{"", "msedge"}, args...)...)
+			} else {
+				cmdline = cmdStart("microsoft-edge:" + url)
+			}
 		default:
-			cmd = exec.Command("cmd", "/c", "start", url)
+			// The empty title keeps start from treating a quoted URL as the window title
+			cmdline = cmdStart("", url)
 		}
 
 	default:
-		return fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
+		return nil, fmt.Errorf("unsupported operating system: %s", goos)
 	}
 
-	if cmd == nil {
-		return fmt.Errorf("could not find browser: %s", browserName)
+	if cmdline == nil {
+		return nil, fmt.Errorf("could not find browser: %s", browserName)
 	}
 
-	return cmd.Start()
+	return cmdline, nil
+}
+
+// cmdStart builds a "cmd /c start" command line. cmd splits commands at an
+// unescaped &, which console sign-in URLs are full of, so it is escaped.
+func cmdStart(args ...string) []string {
+	cmdline := []string{"cmd", "/c", "start"}
+	for _, a := range args {
+		cmdline = append(cmdline, cmdEscape(a))
+	}
+	return cmdline
+}
+
+// cmdEscape escapes the characters cmd.exe treats as command separators
+func cmdEscape(arg string) string {
+	return strings.ReplaceAll(arg, "&", "^&")
 }
 
 // GetBrowserAppPath returns the application path for a browser on macOS
@@ -164,4 +363,3 @@ func GetBrowserAppPath(browserName string) string {
 		return ""
 	}
 }
-
@@ -0,0 +1,483 @@
+package browser
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// fakeEnv is an Env backed by fixed environment variables, files and PATH
+// entries. Started processes are recorded instead of run.
+type fakeEnv struct {
+	vars     map[string]string
+	files    map[string]string
+	path     map[string]string
+	home     string
+	startErr map[string]error
+	started  [][]string
+}
+
+var errNotFound = errors.New("not found")
+
+func (f *fakeEnv) Getenv(key string) string {
+	return f.vars[key]
+}
+
+func (f *fakeEnv) Stat(path string) error {
+	if _, ok := f.files[path]; ok {
+		return nil
+	}
+	return errNotFound
+}
+
+func (f *fakeEnv) ReadFile(path string) ([]byte, error) {
+	if content, ok := f.files[path]; ok {
+		return []byte(content), nil
+	}
+	return nil, errNotFound
+}
+
+func (f *fakeEnv) LookPath(file string) (string, error) {
+	if resolved, ok := f.path[file]; ok {
+		return resolved, nil
+	}
+	return "", errNotFound
+}
+
+func (f *fakeEnv) UserHomeDir() (string, error) {
+	if f.home == "" {
+		return "", errNotFound
+	}
+	return f.home, nil
+}
+
+func (f *fakeEnv) Start(name string, args ...string) error {
+	if err := f.startErr[name]; err != nil {
+		return err
+	}
+	f.started = append(f.started, append([]string{name}, args...))
+	return nil
+}
+
+// onPath maps binaries to /usr/bin paths for fakeEnv.path
+func onPath(bins ...string) map[string]string {
+	path := make(map[string]string, len(bins))
+	for _, b := range bins {
+		path[b] = "/usr/bin/" + b
+	}
+	return path
+}
+
+func TestDetectBrowsersFor(t *testing.T) {
+	tests := []struct {
+		name string
+		goos string
+		env  *fakeEnv
+		want []string
+	}{
+		{
+			name: "darwin without browsers",
+			goos: "darwin",
+			env:  &fakeEnv{},
+			want: nil,
+		},
+		{
+			name: "darwin Chrome Canary counts as Chrome",
+			goos: "darwin",
+			env:  &fakeEnv{files: map[string]string{"/Applications/Google Chrome Canary.app": ""}},
+			want: []string{"Chrome"},
+		},
+		{
+			name: "darwin all browsers",
+			goos: "darwin",
+			env: &fakeEnv{files: map[string]string{
+				"/Applications/Google Chrome.app":  "",
+				"/Applications/Safari.app":         "",
+				"/Applications/Firefox.app":        "",
+				"/Applications/Brave Browser.app":  "",
+				"/Applications/Microsoft Edge.app": "",
+			}},
+			want: []string{"Chrome", "Safari", "Firefox", "Brave"},
+		},
+		{
+			name: "linux without browsers",
+			goos: "linux",
+			env:  &fakeEnv{},
+			want: nil,
+		},
+		{
+			name: "linux PATH binaries and xdg-open",
+			goos: "linux",
+			env:  &fakeEnv{path: onPath("google-chrome-stable", "chromium-browser", "firefox", "xdg-open")},
+			want: []string{"Chrome", "Chromium", "Firefox", SystemDefault},
+		},
+		{
+			name: "linux $BROWSER first",
+			goos: "linux",
+			env:  &fakeEnv{vars: map[string]string{"BROWSER": "w3m"}, path: onPath("xdg-open")},
+			want: []string{EnvBrowser, SystemDefault},
+		},
+		{
+			name: "linux snap and flatpak",
+			goos: "linux",
+			env: &fakeEnv{
+				files: map[string]string{
+					"/snap/bin/firefox": "",
+					"/home/u/.local/share/flatpak/app/com.brave.Browser": "",
+				},
+				path: onPath("flatpak"),
+				home: "/home/u",
+			},
+			want: []string{"Firefox", "Brave"},
+		},
+		{
+			name: "linux flatpak app without flatpak binary",
+			goos: "linux",
+			env: &fakeEnv{
+				files: map[string]string{"/var/lib/flatpak/app/com.brave.Browser": ""},
+			},
+			want: nil,
+		},
+		{
+			name: "linux desktop entry",
+			goos: "linux",
+			env: &fakeEnv{
+				files: map[string]string{
+					"/usr/share/applications/vivaldi-stable.desktop": "[Desktop Entry]\nName=Vivaldi\nExec=/opt/vivaldi/vivaldi %U\n",
+				},
+				path: map[string]string{"/opt/vivaldi/vivaldi": "/opt/vivaldi/vivaldi"},
+			},
+			want: []string{"Vivaldi"},
+		},
+		{
+			name: "linux desktop entry with missing executable",
+			goos: "linux",
+			env: &fakeEnv{
+				files: map[string]string{
+					"/usr/share/applications/opera.desktop": "[Desktop Entry]\nExec=/opt/opera/opera %U\n",
+				},
+			},
+			want: nil,
+		},
+		{
+			name: "WSL offers Windows browsers first",
+			goos: "linux",
+			env: &fakeEnv{
+				vars: map[string]string{"WSL_DISTRO_NAME": "Ubuntu"},
+				files: map[string]string{
+					"/mnt/c/Program Files/Google/Chrome/Application/chrome.exe": "",
+				},
+				path: onPath("wslview", "xdg-open"),
+			},
+			want: []string{WindowsDefault, "Windows Chrome", SystemDefault},
+		},
+		{
+			name: "windows without Chrome",
+			goos: "windows",
+			env:  &fakeEnv{},
+			want: []string{"Edge"},
+		},
+		{
+			name: "windows per-user Chrome",
+			goos: "windows",
+			env: &fakeEnv{
+				vars:  map[string]string{"LOCALAPPDATA": `C:\Users\u\AppData\Local`},
+				files: map[string]string{`C:\Users\u\AppData\Local\Google\Chrome\Application\chrome.exe`: ""},
+			},
+			want: []string{"Chrome", "Edge"},
+		},
+		{
+			name: "windows x86 Chrome",
+			goos: "windows",
+			env: &fakeEnv{
+				vars:  map[string]string{"PROGRAMFILES(X86)": `C:\Program Files (x86)`},
+				files: map[string]string{`C:\Program Files (x86)\Google\Chrome\Application\chrome.exe`: ""},
+			},
+			want: []string{"Chrome", "Edge"},
+		},
+		{
+			name: "unsupported OS",
+			goos: "plan9",
+			env:  &fakeEnv{},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectBrowsersFor(tt.goos, tt.env)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DetectBrowsersFor() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLaunchCommand(t *testing.T) {
+	// A console sign-in URL; cmd needs its & escaped
+	const (
+		url        = "https://signin.aws.amazon.com/federation?Action=login&Issuer=aws-term&SigninToken=abc"
+		escapedURL = "https://signin.aws.amazon.com/federation?Action=login^&Issuer=aws-term^&SigninToken=abc"
+	)
+
+	chromeWin := `C:\Program Files\Google\Chrome\Application\chrome.exe`
+	winChromeEnv := &fakeEnv{
+		vars:  map[string]string{"PROGRAMFILES": `C:\Program Files`},
+		files: map[string]string{chromeWin: ""},
+	}
+
+	tests := []struct {
+		name    string
+		goos    string
+		env     *fakeEnv
+		browser string
+		opts    Options
+		want    []string
+		wantErr bool
+	}{
+		// macOS
+		{name: "darwin Chrome", goos: "darwin", browser: "Chrome", want: []string{"open", "-a", "Google Chrome", url}},
+		{name: "darwin Safari", goos: "darwin", browser: "Safari", want: []string{"open", "-a", "Safari", url}},
+		{name: "darwin Firefox", goos: "darwin", browser: "Firefox", want: []string{"open", "-a", "Firefox", url}},
+		{name: "darwin Brave", goos: "darwin", browser: "Brave", want: []string{"open", "-a", "Brave Browser", url}},
+		{name: "darwin unknown browser", goos: "darwin", browser: "Lynx", want: []string{"open", url}},
+		{
+			name: "darwin Chrome profile", goos: "darwin", browser: "Chrome",
+			opts: Options{ChromeProfile: "Profile 1", NewWindow: true},
+			want: []string{"open", "-n", "-a", "Google Chrome", "--args", "--profile-directory=Profile 1", "--new-window", url},
+		},
+		{
+			name: "darwin Chrome user data dir", goos: "darwin", browser: "Chrome",
+			opts: Options{UserDataDir: "/tmp/chrome/123456789012"},
+			want: []string{"open", "-n", "-a", "Google Chrome", "--args", "--user-data-dir=/tmp/chrome/123456789012", url},
+		},
+		{
+			name: "darwin Firefox private", goos: "darwin", browser: "Firefox",
+			opts: Options{FirefoxProfile: "work", Private: true},
+			want: []string{"open", "-n", "-a", "Firefox", "--args", "-P", "work", "-private-window", url},
+		},
+		{
+			name: "darwin Safari ignores options", goos: "darwin", browser: "Safari",
+			opts: Options{Private: true},
+			want: []string{"open", "-a", "Safari", url},
+		},
+
+		// Linux
+		{
+			name: "linux Chrome", goos: "linux", browser: "Chrome",
+			env:  &fakeEnv{path: onPath("google-chrome")},
+			want: []string{"/usr/bin/google-chrome", url},
+		},
+		{
+			name: "linux Chromium fallback binary", goos: "linux", browser: "Chromium",
+			env:  &fakeEnv{path: onPath("chromium-browser")},
+			want: []string{"/usr/bin/chromium-browser", url},
+		},
+		{
+			name: "linux Firefox new window", goos: "linux", browser: "Firefox",
+			env:  &fakeEnv{path: onPath("firefox")},
+			opts: Options{NewWindow: true},
+			want: []string{"/usr/bin/firefox", "-new-window", url},
+		},
+		{
+			name: "linux Edge private", goos: "linux", browser: "Edge",
+			env:  &fakeEnv{path: onPath("microsoft-edge")},
+			opts: Options{Private: true},
+			want: []string{"/usr/bin/microsoft-edge", "--inprivate", url},
+		},
+		{
+			name: "linux flatpak Brave", goos: "linux", browser: "Brave",
+			env: &fakeEnv{
+				files: map[string]string{"/var/lib/flatpak/app/com.brave.Browser": ""},
+				path:  onPath("flatpak"),
+			},
+			opts: Options{Private: true},
+			want: []string{"flatpak", "run", "com.brave.Browser", "--incognito", url},
+		},
+		{
+			name: "linux snap Opera", goos: "linux", browser: "Opera",
+			env:  &fakeEnv{files: map[string]string{"/snap/bin/opera": ""}},
+			want: []string{"/snap/bin/opera", url},
+		},
+		{
+			name: "linux system default", goos: "linux", browser: SystemDefault,
+			want: []string{"xdg-open", url},
+		},
+		{
+			name: "linux missing browser falls back to xdg-open", goos: "linux", browser: "Firefox",
+			want: []string{"xdg-open", url},
+		},
+		{
+			name: "WSL Windows default", goos: "linux", browser: WindowsDefault,
+			env:  &fakeEnv{path: onPath("wslview")},
+			want: []string{"wslview", url},
+		},
+
+		// Windows
+		{
+			name: "windows Chrome found", goos: "windows", browser: "Chrome",
+			env:  winChromeEnv,
+			want: []string{chromeWin, url},
+		},
+		{
+			name: "windows Chrome with profile", goos: "windows", browser: "Chrome",
+			env:  winChromeEnv,
+			opts: Options{ChromeProfile: "Default", Private: true},
+			want: []string{chromeWin, "--profile-directory=Default", "--incognito", url},
+		},
+		{
+			name: "windows Chrome not in a standard location", goos: "windows", browser: "Chrome",
+			want: []string{"cmd", "/c", "start", "", "chrome", escapedURL},
+		},
+		{
+			name: "windows Edge", goos: "windows", browser: "Edge",
+			want: []string{"cmd", "/c", "start", "microsoft-edge:" + escapedURL},
+		},
+		{
+			name: "windows Edge new window", goos: "windows", browser: "Edge",
+			opts: Options{NewWindow: true},
+			want: []string{"cmd", "/c", "start", "", "msedge", "--new-window", escapedURL},
+		},
+		{
+			name: "windows default", goos: "windows", browser: "Firefox",
+			want: []string{"cmd", "/c", "start", "", escapedURL},
+		},
+
+		// Custom commands apply on every OS
+		{
+			name: "custom command with placeholder", goos: "linux", browser: CustomBrowser,
+			opts: Options{Command: `firefox -P "work profile" --new-tab {url}`},
+			want: []string{"firefox", "-P", "work profile", "--new-tab", url},
+		},
+		{
+			name: "custom command without placeholder", goos: "darwin", browser: CustomBrowser,
+			opts: Options{Command: "open -a 'Arc'"},
+			want: []string{"open", "-a", "Arc", url},
+		},
+		{
+			name: "empty custom command", goos: "windows", browser: CustomBrowser,
+			opts:    Options{Command: "  "},
+			wantErr: true,
+		},
+
+		{name: "unsupported OS", goos: "plan9", browser: "Chrome", wantErr: true},
+		{
+			name: "unknown WSL browser", goos: "linux", browser: "Windows Netscape",
+			env:     &fakeEnv{path: onPath("cmd.exe")},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := tt.env
+			if env == nil {
+				env = &fakeEnv{}
+			}
+
+			got, err := launchCommand(tt.goos, env, tt.browser, url, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("launchCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("launchCommand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenURLFor(t *testing.T) {
+	const url = "https://example.com/login"
+
+	t.Run("starts the launch command", func(t *testing.T) {
+		env := &fakeEnv{path: onPath("firefox")}
+		if err := OpenURLFor("linux", env, "Firefox", url, Options{}); err != nil {
+			t.Fatalf("OpenURLFor() error = %v", err)
+		}
+		want := [][]string{{"/usr/bin/firefox", url}}
+		if !reflect.DeepEqual(env.started, want) {
+			t.Errorf("started = %q, want %q", env.started, want)
+		}
+	})
+
+	t.Run("$BROWSER falls through failing commands", func(t *testing.T) {
+		env := &fakeEnv{
+			vars:     map[string]string{"BROWSER": "missing-browser:lynx -dump %s"},
+			startErr: map[string]error{"missing-browser": errNotFound},
+		}
+		if err := OpenURLFor("linux", env, EnvBrowser, url, Options{}); err != nil {
+			t.Fatalf("OpenURLFor() error = %v", err)
+		}
+		want := [][]string{{"lynx", "-dump", url}}
+		if !reflect.DeepEqual(env.started, want) {
+			t.Errorf("started = %q, want %q", env.started, want)
+		}
+	})
+
+	t.Run("$BROWSER with no working command", func(t *testing.T) {
+		env := &fakeEnv{
+			vars:     map[string]string{"BROWSER": "missing-browser"},
+			startErr: map[string]error{"missing-browser": errNotFound},
+		}
+		if err := OpenURLFor("linux", env, EnvBrowser, url, Options{}); err == nil {
+			t.Error("OpenURLFor() succeeded, want error")
+		}
+	})
+
+	t.Run("launch error is returned", func(t *testing.T) {
+		env := &fakeEnv{startErr: map[string]error{"open": errNotFound}}
+		if err := OpenURLFor("darwin", env, "Safari", url, Options{}); err == nil {
+			t.Error("OpenURLFor() succeeded, want error")
+		}
+	})
+}
+
+func TestIsHeadlessFor(t *testing.T) {
+	tests := []struct {
+		name string
+		goos string
+		env  *fakeEnv
+		want bool
+	}{
+		{name: "windows", goos: "windows", env: &fakeEnv{}, want: false},
+		{name: "darwin local", goos: "darwin", env: &fakeEnv{}, want: false},
+		{name: "darwin over SSH", goos: "darwin", env: &fakeEnv{vars: map[string]string{"SSH_TTY": "/dev/ttys001"}}, want: true},
+		{name: "linux X11", goos: "linux", env: &fakeEnv{vars: map[string]string{"DISPLAY": ":0"}}, want: false},
+		{name: "linux Wayland", goos: "linux", env: &fakeEnv{vars: map[string]string{"WAYLAND_DISPLAY": "wayland-0"}}, want: false},
+		{name: "linux server", goos: "linux", env: &fakeEnv{}, want: true},
+		{name: "WSL", goos: "linux", env: &fakeEnv{vars: map[string]string{"WSL_DISTRO_NAME": "Ubuntu"}}, want: false},
+		{
+			name: "WSL over SSH", goos: "linux",
+			env:  &fakeEnv{vars: map[string]string{"WSL_DISTRO_NAME": "Ubuntu", "SSH_CONNECTION": "10.0.0.1 22 10.0.0.2 22"}},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsHeadlessFor(tt.goos, tt.env); got != tt.want {
+				t.Errorf("IsHeadlessFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{command: "firefox", want: []string{"firefox"}},
+		{command: "  chrome   --new-window  ", want: []string{"chrome", "--new-window"}},
+		{command: `open -a "Google Chrome" {url}`, want: []string{"open", "-a", "Google Chrome", "{url}"}},
+		{command: `sh -c 'echo "hi"'`, want: []string{"sh", "-c", `echo "hi"`}},
+		{command: `x ""`, want: []string{"x", ""}},
+		{command: "", want: nil},
+	}
+
+	for _, tt := range tests {
+		if got := splitCommand(tt.command); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitCommand(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package browser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// SystemDefault opens URLs with xdg-open, i.e. the desktop's default browser
+	SystemDefault = "System default"
+
+	// EnvBrowser launches the commands listed in the $BROWSER environment variable
+	EnvBrowser = "$BROWSER"
+)
+
+// linuxBrowser describes the ways a browser can be installed on Linux
+type linuxBrowser struct {
+	Name         string
+	Binaries     []string
+	Flatpak      string
+	Snap         string
+	DesktopFiles []string
+}
+
+// linuxBrowsers lists the browsers detected on Linux, in the order they are offered
+var linuxBrowsers = []linuxBrowser{
+	{
+		Name:         "Chrome",
+		Binaries:     []string{"google-chrome", "google-chrome-stable"},
+		Flatpak:      "com.google.Chrome",
+		DesktopFiles: []string{"google-chrome.desktop", "com.google.Chrome.desktop"},
+	},
+	{
+		Name:         "Chromium",
+		Binaries:     []string{"chromium", "chromium-browser"},
+		Flatpak:      "org.chromium.Chromium",
+		Snap:         "chromium",
+		DesktopFiles: []string{"chromium.desktop", "chromium-browser.desktop", "chromium_chromium.desktop", "org.chromium.Chromium.desktop"},
+	},
+	{
+		Name:         "Firefox",
+		Binaries:     []string{"firefox"},
+		Flatpak:      "org.mozilla.firefox",
+		Snap:         "firefox",
+		DesktopFiles: []string{"firefox.desktop", "firefox_firefox.desktop", "org.mozilla.firefox.desktop"},
+	},
+	{
+		Name:         "Brave",
+		Binaries:     []string{"brave-browser", "brave"},
+		Flatpak:      "com.brave.Browser",
+		Snap:         "brave",
+		DesktopFiles: []string{"brave-browser.desktop", "brave_brave.desktop", "com.brave.Browser.desktop"},
+	},
+	{
+		Name:         "Edge",
+		Binaries:     []string{"microsoft-edge", "microsoft-edge-stable"},
+		Flatpak:      "com.microsoft.Edge",
+		DesktopFiles: []string{"microsoft-edge.desktop", "com.microsoft.Edge.desktop"},
+	},
+	{
+		Name:         "Vivaldi",
+		Binaries:     []string{"vivaldi", "vivaldi-stable"},
+		Flatpak:      "com.vivaldi.Vivaldi",
+		DesktopFiles: []string{"vivaldi-stable.desktop", "com.vivaldi.Vivaldi.desktop"},
+	},
+	{
+		Name:         "Opera",
+		Binaries:     []string{"opera"},
+		Flatpak:      "com.opera.Opera",
+		Snap:         "opera",
+		DesktopFiles: []string{"opera.desktop", "opera_opera.desktop", "com.opera.Opera.desktop"},
+	},
+}
+
+// detectLinuxBrowsers finds installed browsers on Linux. $BROWSER is offered
+// first when set, and xdg-open is always offered as the system default.
+func detectLinuxBrowsers(env Env) []string {
+	var browsers []string
+
+	if env.Getenv("BROWSER") != "" {
+		browsers = append(browsers, EnvBrowser)
+	}
+
+	for _, b := range linuxBrowsers {
+		if linuxBrowserCommand(env, b.Name) != nil {
+			browsers = append(browsers, b.Name)
+		}
+	}
+
+	if _, err := env.LookPath("xdg-open"); err == nil {
+		browsers = append(browsers, SystemDefault)
+	}
+
+	return browsers
+}
+
+// linuxBrowserCommand returns the command that launches a browser, trying
+// PATH, Snap, Flatpak and finally the Exec line of its .desktop entry.
+// It returns nil if the browser is not installed.
+func linuxBrowserCommand(env Env, name string) []string {
+	for _, b := range linuxBrowsers {
+		if b.Name != name {
+			continue
+		}
+
+		for _, bin := range b.Binaries {
+			if path, err := env.LookPath(bin); err == nil {
+				return []string{path}
+			}
+		}
+
+		if b.Snap != "" {
+			snapBin := filepath.Join("/snap/bin", b.Snap)
+			if env.Stat(snapBin) == nil {
+				return []string{snapBin}
+			}
+		}
+
+		if b.Flatpak != "" && flatpakInstalled(env, b.Flatpak) {
+			return []string{"flatpak", "run", b.Flatpak}
+		}
+
+		for _, file := range b.DesktopFiles {
+			if cmdline := desktopExec(env, file); cmdline != nil {
+				return cmdline
+			}
+		}
+	}
+	return nil
+}
+
+// flatpakInstalled reports whether a Flatpak app is installed system-wide or for the user
+func flatpakInstalled(env Env, appId string) bool {
+	if _, err := env.LookPath("flatpak"); err != nil {
+		return false
+	}
+
+	dirs := []string{"/var/lib/flatpak/app"}
+	if home, err := env.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".local/share/flatpak/app"))
+	}
+	for _, dir := range dirs {
+		if env.Stat(filepath.Join(dir, appId)) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// desktopDirs returns the XDG application directories, most specific first
+func desktopDirs(env Env) []string {
+	var dirs []string
+
+	dataHome := env.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		if home, err := env.UserHomeDir(); err == nil {
+			dataHome = filepath.Join(home, ".local/share")
+		}
+	}
+	if dataHome != "" {
+		dirs = append(dirs, filepath.Join(dataHome, "applications"))
+	}
+
+	dataDirs := env.Getenv("XDG_DATA_DIRS")
+	if dataDirs == "" {
+		dataDirs = "/usr/local/share:/usr/share"
+	}
+	for _, dir := range filepath.SplitList(dataDirs) {
+		dirs = append(dirs, filepath.Join(dir, "applications"))
+	}
+
+	return append(dirs, "/var/lib/flatpak/exports/share/applications", "/var/lib/snapd/desktop/applications")
+}
+
+// desktopExec returns the command from the Exec line of a .desktop entry,
+// without field codes such as %u, or nil if no usable entry exists
+func desktopExec(env Env, file string) []string {
+	for _, dir := range desktopDirs(env) {
+		data, err := env.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			continue
+		}
+
+		var args []string
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		inEntry := false
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if strings.HasPrefix(line, "[") {
+				inEntry = line == "[Desktop Entry]"
+				continue
+			}
+			if inEntry && strings.HasPrefix(line, "Exec=") {
+				for _, arg := range splitCommand(strings.TrimPrefix(line, "Exec=")) {
+					if !strings.HasPrefix(arg, "%") {
+						args = append(args, arg)
+					}
+				}
+				break
+			}
+		}
+
+		if len(args) > 0 {
+			if _, err := env.LookPath(args[0]); err == nil {
+				return args
+			}
+		}
+	}
+	return nil
+}
+
+// openWithEnvBrowser tries each command in $BROWSER in turn. A %s in a command
+// is replaced with the URL; otherwise the URL is appended.
+func openWithEnvBrowser(env Env, url string) error {
+	var lastErr error
+	for _, command := range strings.Split(env.Getenv("BROWSER"), ":") {
+		if strings.TrimSpace(command) == "" {
+			continue
+		}
+
+		cmdline, err := customCommand(strings.ReplaceAll(command, "%s", "{url}"), url)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if err := env.Start(cmdline[0], cmdline[1:]...); err != nil {
+			lastErr = err
+			continue
+		}
+		return nil
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("$BROWSER is not set")
+	}
+	return lastErr
+}
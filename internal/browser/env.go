@@ -0,0 +1,49 @@
+package browser
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Env is the view of the operating system used to find and launch browsers.
+// SystemEnv uses the real system; tests supply a fake to cover every OS.
+type Env interface {
+	Getenv(key string) string
+	Stat(path string) error
+	ReadFile(path string) ([]byte, error)
+	LookPath(file string) (string, error)
+	UserHomeDir() (string, error)
+
+	// Start launches a process without waiting for it to exit
+	Start(name string, args ...string) error
+}
+
+// SystemEnv probes and launches processes on the machine aws-term runs on
+var SystemEnv Env = systemEnv{}
+
+type systemEnv struct{}
+
+func (systemEnv) Getenv(key string) string {
+	return os.Getenv(key)
+}
+
+func (systemEnv) Stat(path string) error {
+	_, err := os.Stat(path)
+	return err
+}
+
+func (systemEnv) ReadFile(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+func (systemEnv) LookPath(file string) (string, error) {
+	return exec.LookPath(file)
+}
+
+func (systemEnv) UserHomeDir() (string, error) {
+	return os.UserHomeDir()
+}
+
+func (systemEnv) Start(name string, args ...string) error {
+	return exec.Command(name, args...).Start()
+}
@@ -0,0 +1,133 @@
+package browser
+
+import "strings"
+
+const (
+	// WindowsDefault opens URLs in the default browser on the Windows side of WSL
+	WindowsDefault = "Windows default"
+
+	// windowsPrefix marks Windows browsers offered inside WSL, e.g. "Windows Chrome"
+	windowsPrefix = "Windows "
+)
+
+// wslBrowser is a Windows browser that can be launched from WSL
+type wslBrowser struct {
+	Name  string
+	Exe   string
+	Paths []string
+}
+
+// wslBrowsers lists the Windows browsers looked for under the C: drive mount
+var wslBrowsers = []wslBrowser{
+	{
+		Name: "Edge",
+		Exe:  "msedge",
+		Paths: []string{
+			"/mnt/c/Program Files (x86)/Microsoft/Edge/Application/msedge.exe",
+			"/mnt/c/Program Files/Microsoft/Edge/Application/msedge.exe",
+		},
+	},
+	{
+		Name: "Chrome",
+		Exe:  "chrome",
+		Paths: []string{
+			"/mnt/c/Program Files/Google/Chrome/Application/chrome.exe",
+			"/mnt/c/Program Files (x86)/Google/Chrome/Application/chrome.exe",
+		},
+	},
+	{
+		Name: "Firefox",
+		Exe:  "firefox",
+		Paths: []string{
+			"/mnt/c/Program Files/Mozilla Firefox/firefox.exe",
+			"/mnt/c/Program Files (x86)/Mozilla Firefox/firefox.exe",
+		},
+	},
+}
+
+// canLaunchWindows reports whether any way of starting Windows programs is available
+func canLaunchWindows(env Env) bool {
+	for _, bin := range []string{"wslview", "powershell.exe", "cmd.exe"} {
+		if _, err := env.LookPath(bin); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// detectWSLBrowsers returns the Windows browsers that can be opened from WSL
+func detectWSLBrowsers(env Env) []string {
+	if !canLaunchWindows(env) {
+		return nil
+	}
+
+	browsers := []string{WindowsDefault}
+	for _, b := range wslBrowsers {
+		for _, path := range b.Paths {
+			if env.Stat(path) == nil {
+				browsers = append(browsers, windowsPrefix+b.Name)
+				break
+			}
+		}
+	}
+	return browsers
+}
+
+// IsWindowsBrowser reports whether a browser name refers to a Windows browser
+// offered inside WSL
+func IsWindowsBrowser(browserName string) bool {
+	return strings.HasPrefix(browserName, windowsPrefix)
+}
+
+// powershellQuote quotes a string as a PowerShell single-quoted literal
+func powershellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// wslCommand builds the command line that opens url in a Windows browser.
+// The default browser is opened with wslview when installed, then
+// powershell.exe Start-Process, then cmd.exe /c start. Specific browsers are
+// started by executable name so Windows resolves them from its App Paths.
+func wslCommand(env Env, browserName, url string, opts Options) []string {
+	_, errWslview := env.LookPath("wslview")
+	_, errPowershell := env.LookPath("powershell.exe")
+
+	if browserName == WindowsDefault {
+		switch {
+		case errWslview == nil:
+			return []string{"wslview", url}
+		case errPowershell == nil:
+			return []string{"powershell.exe", "-NoProfile", "-Command", "Start-Process " + powershellQuote(url)}
+		default:
+			// The empty title keeps start from treating a quoted URL as the window title
+			return []string{"cmd.exe", "/c", "start", `""`, cmdEscape(url)}
+		}
+	}
+
+	name := strings.TrimPrefix(browserName, windowsPrefix)
+	exe := ""
+	for _, b := range wslBrowsers {
+		if b.Name == name {
+			exe = b.Exe
+		}
+	}
+	if exe == "" {
+		return nil
+	}
+
+	args := append(browserArgs(name, opts), url)
+	if errPowershell == nil {
+		quoted := make([]string, len(args))
+		for i, a := range args {
+			quoted[i] = powershellQuote(`"` + a + `"`)
+		}
+		return []string{"powershell.exe", "-NoProfile", "-Command",
+			"Start-Process " + exe + " -ArgumentList " + strings.Join(quoted, ",")}
+	}
+
+	cmdline := []string{"cmd.exe", "/c", "start", `""`, exe}
+	for _, a := range args {
+		cmdline = append(cmdline, cmdEscape(a))
+	}
+	return cmdline
+}
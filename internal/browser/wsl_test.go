@@ -0,0 +1,112 @@
+package browser
+
+import (
+	"reflect"
+	"testing"
+)
+
+// wslEnv builds a fakeEnv from fixed environment variables, files and PATH entries
+func wslEnv(vars map[string]string, files map[string]string, path []string) *fakeEnv {
+	return &fakeEnv{vars: vars, files: files, path: onPath(path...)}
+}
+
+func TestDetectWSLBrowsers(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		path  []string
+		want  []string
+	}{
+		{
+			name: "no way to launch Windows programs",
+			files: map[string]string{
+				"/mnt/c/Program Files/Google/Chrome/Application/chrome.exe": "",
+			},
+			want: nil,
+		},
+		{
+			name: "default only",
+			path: []string{"wslview"},
+			want: []string{WindowsDefault},
+		},
+		{
+			name: "installed browsers",
+			files: map[string]string{
+				"/mnt/c/Program Files (x86)/Microsoft/Edge/Application/msedge.exe": "",
+				"/mnt/c/Program Files (x86)/Google/Chrome/Application/chrome.exe":  "",
+			},
+			path: []string{"cmd.exe"},
+			want: []string{WindowsDefault, "Windows Edge", "Windows Chrome"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := detectWSLBrowsers(wslEnv(nil, tt.files, tt.path))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("detectWSLBrowsers() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWSLCommand(t *testing.T) {
+	const url = "https://device.sso.us-east-1.amazonaws.com/?user_code=ABCD-EFGH&x=1"
+
+	tests := []struct {
+		name    string
+		path    []string
+		browser string
+		opts    Options
+		want    []string
+	}{
+		{
+			name:    "default via wslview",
+			path:    []string{"wslview", "powershell.exe", "cmd.exe"},
+			browser: WindowsDefault,
+			want:    []string{"wslview", url},
+		},
+		{
+			name:    "default via powershell",
+			path:    []string{"powershell.exe", "cmd.exe"},
+			browser: WindowsDefault,
+			want:    []string{"powershell.exe", "-NoProfile", "-Command", "Start-Process '" + url + "'"},
+		},
+		{
+			name:    "default via cmd",
+			path:    []string{"cmd.exe"},
+			browser: WindowsDefault,
+			want:    []string{"cmd.exe", "/c", "start", `""`, "https://device.sso.us-east-1.amazonaws.com/?user_code=ABCD-EFGH^&x=1"},
+		},
+		{
+			name:    "chrome profile via powershell",
+			path:    []string{"powershell.exe"},
+			browser: "Windows Chrome",
+			opts:    Options{ChromeProfile: "Profile 2"},
+			want: []string{"powershell.exe", "-NoProfile", "-Command",
+				`Start-Process chrome -ArgumentList '"--profile-directory=Profile 2"','"` + url + `"'`},
+		},
+		{
+			name:    "edge private via cmd",
+			path:    []string{"cmd.exe"},
+			browser: "Windows Edge",
+			opts:    Options{Private: true},
+			want:    []string{"cmd.exe", "/c", "start", `""`, "msedge", "--inprivate", "https://device.sso.us-east-1.amazonaws.com/?user_code=ABCD-EFGH^&x=1"},
+		},
+		{
+			name:    "unknown browser",
+			path:    []string{"cmd.exe"},
+			browser: "Windows Netscape",
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := wslCommand(wslEnv(nil, nil, tt.path), tt.browser, url, tt.opts)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("wslCommand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ysaakpr/aws-term/internal/config"
+	"github.com/ysaakpr/aws-term/internal/sso"
+)
+
+const (
+	CacheDir = "cache"
+
+	// DefaultAccountsTTL is how long the cached account list is used without refreshing
+	DefaultAccountsTTL = 24 * time.Hour
+
+	// DefaultRolesTTL is how long prefetched roles are reused before refetching
+	DefaultRolesTTL = 1 * time.Hour
+)
+
+// Directory is the cached account and role listing for one SSO start URL
+type Directory struct {
+	StartURL          string                `json:"start_url"`
+	Accounts          []sso.Account         `json:"accounts,omitempty"`
+	AccountsUpdatedAt time.Time             `json:"accounts_updated_at,omitempty"`
+	Roles             map[string][]sso.Role `json:"roles,omitempty"`
+	RolesUpdatedAt    time.Time             `json:"roles_updated_at,omitempty"`
+
+	// AccountRolesUpdatedAt stamps the roles of accounts listed one at a
+	// time, after the rest of the map was last refreshed
+	AccountRolesUpdatedAt map[string]time.Time `json:"account_roles_updated_at,omitempty"`
+}
+
+// GetCacheDir returns the directory holding cached SSO listings
+func GetCacheDir() (string, error) {
+	stateDir, err := config.GetStateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDir, CacheDir), nil
+}
+
+// cachePath returns the cache file of the given kind for a start URL. The URL
+// is hashed so it can be used as a file name.
+func cachePath(kind, startURL string) (string, error) {
+	cacheDir, err := GetCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(startURL))
+	return filepath.Join(cacheDir, kind+"-"+hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// directoryPath returns the listing cache file for a start URL
+func directoryPath(startURL string) (string, error) {
+	return cachePath("directory", startURL)
+}
+
+// LoadDirectory reads the cached listing for a start URL, returning an empty
+// directory if nothing has been cached yet
+func LoadDirectory(startURL string) (*Directory, error) {
+	empty := &Directory{StartURL: startURL}
+
+	path, err := directoryPath(startURL)
+	if err != nil {
+		return empty, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return empty, nil
+		}
+		return empty, fmt.Errorf("failed to read cache file: %w", err)
+	}
+
+	var dir Directory
+	if err := json.Unmarshal(data, &dir); err != nil {
+		return empty, fmt.Errorf("failed to parse cache file: %w", err)
+	}
+	dir.StartURL = startURL
+
+	return &dir, nil
+}
+
+// Save writes the listing to the cache directory
+func (d *Directory) Save() error {
+	cacheDir, err := GetCacheDir()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	path, err := directoryPath(d.StartURL)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize cache: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+
+	return nil
+}
+
+// SetAccounts replaces the cached account list and stamps it with the current time
+func (d *Directory) SetAccounts(accounts []sso.Account) {
+	d.Accounts = accounts
+	d.AccountsUpdatedAt = time.Now()
+}
+
+// HasAccounts reports whether any account list has been cached, however old
+func (d *Directory) HasAccounts() bool {
+	return !d.AccountsUpdatedAt.IsZero()
+}
+
+// AccountsFresh reports whether the cached account list is younger than ttl
+func (d *Directory) AccountsFresh(ttl time.Duration) bool {
+	return d.HasAccounts() && time.Since(d.AccountsUpdatedAt) < ttl
+}
+
+// SetRoles replaces the cached account→roles map and stamps it with the current time
+func (d *Directory) SetRoles(roles map[string][]sso.Role) {
+	d.Roles = roles
+	d.RolesUpdatedAt = time.Now()
+	d.AccountRolesUpdatedAt = nil
+}
+
+// SetAccountRoles caches the roles of a single account and stamps them with
+// the current time, leaving the other accounts' roles as they are
+func (d *Directory) SetAccountRoles(accountId string, roles []sso.Role) {
+	if d.Roles == nil {
+		d.Roles = make(map[string][]sso.Role)
+	}
+	if d.AccountRolesUpdatedAt == nil {
+		d.AccountRolesUpdatedAt = make(map[string]time.Time)
+	}
+	d.Roles[accountId] = roles
+	d.AccountRolesUpdatedAt[accountId] = time.Now()
+}
+
+// RolesFresh reports whether the cached roles are younger than ttl
+func (d *Directory) RolesFresh(ttl time.Duration) bool {
+	return d.Roles != nil && time.Since(d.RolesUpdatedAt) < ttl
+}
+
+// RolesFor returns the cached roles of an account if they are younger than ttl
+func (d *Directory) RolesFor(accountId string, ttl time.Duration) ([]sso.Role, bool) {
+	updatedAt, ok := d.AccountRolesUpdatedAt[accountId]
+	if !ok {
+		updatedAt = d.RolesUpdatedAt
+	}
+	if time.Since(updatedAt) >= ttl {
+		return nil, false
+	}
+	roles, ok := d.Roles[accountId]
+	return roles, ok
+}
@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ysaakpr/aws-term/internal/sso"
+)
+
+func TestRolesFor(t *testing.T) {
+	admin := []sso.Role{{RoleName: "Admin", AccountId: "111111111111"}}
+	readOnly := []sso.Role{{RoleName: "ReadOnly", AccountId: "222222222222"}}
+
+	dir := &Directory{}
+	if _, ok := dir.RolesFor("111111111111", DefaultRolesTTL); ok {
+		t.Error("RolesFor() on an empty directory reported cached roles")
+	}
+
+	// A single account's roles are fresh even though the map is not
+	dir.SetAccountRoles("111111111111", admin)
+	if roles, ok := dir.RolesFor("111111111111", DefaultRolesTTL); !ok || roles[0].RoleName != "Admin" {
+		t.Errorf("RolesFor() = %v, %v, want Admin", roles, ok)
+	}
+	if dir.RolesFresh(DefaultRolesTTL) {
+		t.Error("RolesFresh() = true after caching a single account")
+	}
+
+	// A stale prefetch does not hide roles listed since
+	dir.Roles["222222222222"] = readOnly
+	dir.RolesUpdatedAt = time.Now().Add(-2 * DefaultRolesTTL)
+	if _, ok := dir.RolesFor("222222222222", DefaultRolesTTL); ok {
+		t.Error("RolesFor() returned roles from a stale prefetch")
+	}
+	if _, ok := dir.RolesFor("111111111111", DefaultRolesTTL); !ok {
+		t.Error("RolesFor() lost the roles of a single account")
+	}
+
+	// A full prefetch replaces the single-account stamps
+	dir.SetRoles(map[string][]sso.Role{"222222222222": readOnly})
+	if _, ok := dir.RolesFor("111111111111", DefaultRolesTTL); ok {
+		t.Error("RolesFor() returned roles dropped by SetRoles")
+	}
+	if _, ok := dir.RolesFor("222222222222", DefaultRolesTTL); !ok {
+		t.Error("RolesFor() missed prefetched roles")
+	}
+}
+
+func TestDirectorySaveLoad(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	const startURL = "https://example.awsapps.com/start"
+
+	dir, err := LoadDirectory(startURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir.HasAccounts() {
+		t.Fatal("new directory has accounts")
+	}
+
+	dir.SetAccounts([]sso.Account{{AccountId: "111111111111", AccountName: "dev"}})
+	dir.SetAccountRoles("111111111111", []sso.Role{{RoleName: "Admin", AccountId: "111111111111"}})
+	if err := dir.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadDirectory(startURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.AccountsFresh(DefaultAccountsTTL) || len(loaded.Accounts) != 1 {
+		t.Errorf("loaded accounts = %v, want 1 fresh account", loaded.Accounts)
+	}
+	if _, ok := loaded.RolesFor("111111111111", DefaultRolesTTL); !ok {
+		t.Error("loaded directory lost the account's roles")
+	}
+}
+
+func TestRoleCredentials(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	const startURL = "https://example.awsapps.com/start"
+
+	creds, err := LoadRoleCredentials(startURL, "work|us-east-1|prod|||")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.Valid() {
+		t.Fatal("empty credentials are valid")
+	}
+
+	creds.Account = sso.Account{AccountId: "111111111111"}
+	creds.Credentials = sso.Credentials{AccessKeyId: "ASIACACHED", Expiration: time.Now().Add(time.Hour)}
+	if err := creds.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadRoleCredentials(startURL, "work|us-east-1|prod|||")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Valid() || loaded.Credentials.AccessKeyId != "ASIACACHED" || loaded.Account.AccountId != "111111111111" {
+		t.Errorf("loaded credentials = %+v", loaded)
+	}
+
+	// Other selections have their own entries
+	if other, _ := LoadRoleCredentials(startURL, "work|us-east-1|dev|||"); other.Valid() {
+		t.Error("credentials cached for one target were returned for another")
+	}
+
+	loaded.Credentials.Expiration = time.Now().Add(credentialsExpiryMargin / 2)
+	if loaded.Valid() {
+		t.Error("credentials expiring within the margin are valid")
+	}
+}
+
+func TestTokenDelete(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	const startURL = "https://example.awsapps.com/start"
+
+	token := &Token{StartURL: startURL, Region: "us-east-1", AccessToken: "token", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := token.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := token.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if token.Valid("us-east-1") {
+		t.Error("deleted token is still valid")
+	}
+
+	loaded, err := LoadToken(startURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.AccessToken != "" {
+		t.Errorf("LoadToken() after Delete() = %+v, want no token", loaded)
+	}
+	if err := loaded.Delete(); err != nil {
+		t.Errorf("Delete() without a cached token error = %v", err)
+	}
+}
+
+func TestTokenState(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name  string
+		token Token
+		want  TokenState
+	}{
+		{name: "missing", token: Token{}, want: TokenMissing},
+		{name: "valid", token: Token{AccessToken: "token", Region: "us-east-1", ExpiresAt: now.Add(time.Hour)}, want: TokenValid},
+		{name: "within the margin", token: Token{AccessToken: "token", Region: "us-east-1", ExpiresAt: now.Add(time.Minute)}, want: TokenExpiring},
+		{name: "expired", token: Token{AccessToken: "token", Region: "us-east-1", ExpiresAt: now.Add(-time.Minute)}, want: TokenExpired},
+		{name: "other region", token: Token{AccessToken: "token", Region: "eu-west-1", ExpiresAt: now.Add(time.Hour)}, want: TokenOtherRegion},
+		{name: "expired in another region", token: Token{AccessToken: "token", Region: "eu-west-1", ExpiresAt: now.Add(-time.Minute)}, want: TokenExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.token.State("us-east-1"); got != tt.want {
+				t.Errorf("State() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
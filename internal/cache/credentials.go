@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ysaakpr/aws-term/internal/sso"
+)
+
+// credentialsExpiryMargin keeps cached role credentials from being handed
+// out just before they expire
+const credentialsExpiryMargin = 10 * time.Minute
+
+// RoleCredentials are the cached credentials for one selection (a target,
+// or an account and role) of a start URL, so commands that other tools run
+// for every request (kubectl, docker) do not sign in and pick each time
+type RoleCredentials struct {
+	StartURL       string          `json:"start_url"`
+	Key            string          `json:"key"`
+	Account        sso.Account     `json:"account"`
+	Role           sso.Role        `json:"role"`
+	AssumedRoleArn string          `json:"assumed_role_arn,omitempty"`
+	IsProduction   bool            `json:"is_production,omitempty"`
+	Credentials    sso.Credentials `json:"credentials"`
+}
+
+// credentialsPath returns the credentials cache file for a selection key
+func credentialsPath(startURL, key string) (string, error) {
+	return cachePath("credentials", startURL+"\n"+key)
+}
+
+// LoadRoleCredentials reads the cached credentials for a selection key,
+// returning empty credentials if none have been cached
+func LoadRoleCredentials(startURL, key string) (*RoleCredentials, error) {
+	empty := &RoleCredentials{StartURL: startURL, Key: key}
+
+	path, err := credentialsPath(startURL, key)
+	if err != nil {
+		return empty, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return empty, nil
+		}
+		return empty, fmt.Errorf("failed to read credentials cache: %w", err)
+	}
+
+	var creds RoleCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return empty, fmt.Errorf("failed to parse credentials cache: %w", err)
+	}
+	if creds.StartURL != startURL || creds.Key != key {
+		return empty, nil
+	}
+
+	return &creds, nil
+}
+
+// Save writes the credentials to the cache directory, readable only by the user
+func (c *RoleCredentials) Save() error {
+	cacheDir, err := GetCacheDir()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	path, err := credentialsPath(c.StartURL, c.Key)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize credentials: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write credentials cache: %w", err)
+	}
+
+	return nil
+}
+
+// Valid reports whether the credentials can still be handed out
+func (c *RoleCredentials) Valid() bool {
+	return c.Credentials.AccessKeyId != "" && time.Until(c.Credentials.Expiration) > credentialsExpiryMargin
+}
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// tokenExpiryMargin keeps a token from being used just before it expires
+const tokenExpiryMargin = 5 * time.Minute
+
+// Token is the cached SSO access token for one start URL, so commands run by
+// other tools (kubectl, docker) can get credentials without signing in again
+type Token struct {
+	StartURL    string    `json:"start_url"`
+	Region      string    `json:"region"`
+	AccessToken string    `json:"access_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// tokenPath returns the token cache file for a start URL
+func tokenPath(startURL string) (string, error) {
+	return cachePath("token", startURL)
+}
+
+// LoadToken reads the cached token for a start URL, returning an empty token
+// if none has been cached
+func LoadToken(startURL string) (*Token, error) {
+	empty := &Token{StartURL: startURL}
+
+	path, err := tokenPath(startURL)
+	if err != nil {
+		return empty, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return empty, nil
+		}
+		return empty, fmt.Errorf("failed to read token cache: %w", err)
+	}
+
+	var token Token
+	if err := json.Unmarshal(data, &token); err != nil {
+		return empty, fmt.Errorf("failed to parse token cache: %w", err)
+	}
+	token.StartURL = startURL
+
+	return &token, nil
+}
+
+// Save writes the token to the cache directory, readable only by the user
+func (t *Token) Save() error {
+	cacheDir, err := GetCacheDir()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	path, err := tokenPath(t.StartURL)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize token: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write token cache: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes the cached token, e.g. after the portal rejected it
+func (t *Token) Delete() error {
+	path, err := tokenPath(t.StartURL)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove token cache: %w", err)
+	}
+	t.AccessToken, t.ExpiresAt = "", time.Time{}
+	return nil
+}
+
+// Valid reports whether the token can be used for the given region
+func (t *Token) Valid(region string) bool {
+	return t.AccessToken != "" && t.Region == region && time.Until(t.ExpiresAt) > tokenExpiryMargin
+}
+
+// TokenState describes a cached token, for "aws-term status"
+type TokenState string
+
+const (
+	TokenMissing     TokenState = "missing"
+	TokenValid       TokenState = "valid"
+	TokenExpiring    TokenState = "expiring"
+	TokenExpired     TokenState = "expired"
+	TokenOtherRegion TokenState = "other_region"
+)
+
+// State reports whether the token can be used for region. A token that
+// expires within the safety margin is expiring: it is no longer used.
+func (t *Token) State(region string) TokenState {
+	switch {
+	case t.AccessToken == "":
+		return TokenMissing
+	case !time.Now().Before(t.ExpiresAt):
+		return TokenExpired
+	case t.Region != region:
+		return TokenOtherRegion
+	case !t.Valid(region):
+		return TokenExpiring
+	default:
+		return TokenValid
+	}
+}
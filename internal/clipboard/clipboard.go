@@ -0,0 +1,220 @@
+// Package clipboard copies text to the system clipboard with the tools the
+// platform provides, or with OSC 52 escape sequences when running remotely.
+package clipboard
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/ysaakpr/aws-term/internal/platform"
+	"golang.org/x/term"
+)
+
+// ErrUnavailable is returned by Detect when no way of reaching a clipboard is found
+var ErrUnavailable = errors.New("no clipboard available (install wl-clipboard, xclip or xsel)")
+
+// Backend puts text on a clipboard
+type Backend interface {
+	Name() string
+	Copy(text string) error
+}
+
+// Reader is implemented by backends that can read the clipboard back, so a
+// scheduled clear can leave text copied by someone else alone
+type Reader interface {
+	Paste() (string, error)
+}
+
+// Env is the view of the operating system used to find and run clipboard
+// tools. SystemEnv uses the real system; tests supply a fake.
+type Env interface {
+	Getenv(key string) string
+	ReadFile(path string) ([]byte, error)
+	LookPath(file string) (string, error)
+
+	// Run runs a command with stdin as its input. Its output is discarded:
+	// xclip and wl-copy leave a process behind that keeps it open.
+	Run(stdin string, name string, args ...string) error
+
+	// Output runs a command and returns what it prints
+	Output(name string, args ...string) (string, error)
+}
+
+// SystemEnv runs clipboard tools on the machine aws-term runs on
+var SystemEnv Env = systemEnv{}
+
+type systemEnv struct{}
+
+func (systemEnv) Getenv(key string) string {
+	return os.Getenv(key)
+}
+
+func (systemEnv) ReadFile(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+func (systemEnv) LookPath(file string) (string, error) {
+	return exec.LookPath(file)
+}
+
+func (systemEnv) Run(stdin string, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	return cmd.Run()
+}
+
+func (systemEnv) Output(name string, args ...string) (string, error) {
+	out, err := exec.Command(name, args...).Output()
+	return string(out), err
+}
+
+// Detect returns the clipboard backend for this machine. OSC 52 is used when
+// no clipboard tool fits and stderr or stdout is a terminal.
+func Detect() (Backend, error) {
+	var terminal io.Writer
+	for _, f := range []*os.File{os.Stderr, os.Stdout} {
+		if term.IsTerminal(int(f.Fd())) {
+			terminal = f
+			break
+		}
+	}
+	return DetectFor(runtime.GOOS, SystemEnv, terminal)
+}
+
+// DetectFor picks the backend for env on the given OS. terminal receives OSC
+// 52 sequences; nil means there is no terminal to send them to.
+func DetectFor(goos string, env Env, terminal io.Writer) (Backend, error) {
+	// Over SSH the local tools would fill the remote machine's clipboard,
+	// so ask the user's terminal instead
+	remote := env.Getenv("SSH_CONNECTION") != "" || env.Getenv("SSH_TTY") != ""
+
+	var candidates []*commandBackend
+	switch goos {
+	case "darwin":
+		if !remote {
+			candidates = append(candidates, &commandBackend{name: "pbcopy", copyCmd: []string{"pbcopy"}, pasteCmd: []string{"pbpaste"}})
+		}
+	case "windows":
+		candidates = append(candidates, windowsBackend())
+	default:
+		if platform.IsWSL(env) {
+			candidates = append(candidates, windowsBackend())
+			break
+		}
+		if remote {
+			break
+		}
+		if env.Getenv("WAYLAND_DISPLAY") != "" {
+			candidates = append(candidates, &commandBackend{name: "wl-copy", copyCmd: []string{"wl-copy"}, pasteCmd: []string{"wl-paste", "--no-newline"}})
+		}
+		if env.Getenv("DISPLAY") != "" {
+			candidates = append(candidates,
+				&commandBackend{name: "xclip", copyCmd: []string{"xclip", "-selection", "clipboard"}, pasteCmd: []string{"xclip", "-selection", "clipboard", "-o"}},
+				&commandBackend{name: "xsel", copyCmd: []string{"xsel", "--clipboard", "--input"}, pasteCmd: []string{"xsel", "--clipboard", "--output"}},
+			)
+		}
+	}
+
+	for _, c := range candidates {
+		if _, err := env.LookPath(c.copyCmd[0]); err == nil {
+			c.env = env
+			return c, nil
+		}
+	}
+
+	if terminal != nil {
+		return &OSC52{Out: terminal, Multiplexed: env.Getenv("TMUX") != ""}, nil
+	}
+	return nil, ErrUnavailable
+}
+
+// windowsBackend copies with clip.exe, which also works from WSL
+func windowsBackend() *commandBackend {
+	return &commandBackend{
+		name:     "clip.exe",
+		copyCmd:  []string{"clip.exe"},
+		pasteCmd: []string{"powershell.exe", "-NoProfile", "-Command", "Get-Clipboard -Raw"},
+	}
+}
+
+// commandBackend copies by piping text into a clipboard tool
+type commandBackend struct {
+	name     string
+	copyCmd  []string
+	pasteCmd []string
+	env      Env
+}
+
+func (c *commandBackend) Name() string {
+	return c.name
+}
+
+func (c *commandBackend) Copy(text string) error {
+	if err := c.env.Run(text, c.copyCmd[0], c.copyCmd[1:]...); err != nil {
+		return fmt.Errorf("failed to copy with %s: %w", c.name, err)
+	}
+	return nil
+}
+
+func (c *commandBackend) Paste() (string, error) {
+	out, err := c.env.Output(c.pasteCmd[0], c.pasteCmd[1:]...)
+	if err != nil {
+		return "", fmt.Errorf("failed to read clipboard with %s: %w", c.pasteCmd[0], err)
+	}
+	// clip.exe round trips through PowerShell, which adds a line break
+	if c.name == "clip.exe" {
+		out = strings.TrimSuffix(out, "\r\n")
+	}
+	return out, nil
+}
+
+// OSC52 asks the terminal emulator to set the clipboard, which reaches the
+// user's machine through SSH. Terminals without OSC 52 support ignore it.
+type OSC52 struct {
+	Out io.Writer
+
+	// Multiplexed wraps the sequence for tmux, which otherwise swallows it
+	Multiplexed bool
+}
+
+func (o *OSC52) Name() string {
+	return "OSC 52"
+}
+
+func (o *OSC52) Copy(text string) error {
+	seq := "\033]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+	if o.Multiplexed {
+		seq = "\033Ptmux;" + strings.ReplaceAll(seq, "\033", "\033\033") + "\033\\"
+	}
+	_, err := io.WriteString(o.Out, seq)
+	return err
+}
+
+// Fingerprint identifies copied text without keeping it, for ClearIf
+func Fingerprint(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// ClearIf empties the clipboard if it still holds the text with the given
+// fingerprint. Backends that cannot read the clipboard are cleared regardless.
+func ClearIf(b Backend, fingerprint string) error {
+	if r, ok := b.(Reader); ok {
+		current, err := r.Paste()
+		if err != nil {
+			return err
+		}
+		if Fingerprint(current) != fingerprint {
+			return nil
+		}
+	}
+	return b.Copy("")
+}
@@ -0,0 +1,161 @@
+package clipboard
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakeSystem is an Env with fixed variables and installed tools, whose
+// tools copy to and paste from an in-memory clipboard
+type fakeSystem struct {
+	vars      map[string]string
+	tools     []string
+	clipboard string
+	ran       [][]string
+}
+
+func (f *fakeSystem) Getenv(key string) string {
+	return f.vars[key]
+}
+
+func (f *fakeSystem) ReadFile(path string) ([]byte, error) {
+	return nil, os.ErrNotExist
+}
+
+func (f *fakeSystem) LookPath(file string) (string, error) {
+	if slices.Contains(f.tools, file) {
+		return "/usr/bin/" + file, nil
+	}
+	return "", exec.ErrNotFound
+}
+
+func (f *fakeSystem) Run(stdin string, name string, args ...string) error {
+	f.ran = append(f.ran, append([]string{name}, args...))
+	f.clipboard = stdin
+	return nil
+}
+
+func (f *fakeSystem) Output(name string, args ...string) (string, error) {
+	f.ran = append(f.ran, append([]string{name}, args...))
+	return f.clipboard, nil
+}
+
+func TestDetectFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		goos     string
+		vars     map[string]string
+		path     []string
+		terminal bool
+		want     string
+		wantErr  bool
+	}{
+		{name: "macOS", goos: "darwin", path: []string{"pbcopy"}, want: "pbcopy"},
+		{name: "macOS over SSH", goos: "darwin", vars: map[string]string{"SSH_TTY": "/dev/ttys001"}, path: []string{"pbcopy"}, terminal: true, want: "OSC 52"},
+		{name: "windows", goos: "windows", path: []string{"clip.exe"}, want: "clip.exe"},
+		{name: "wayland", goos: "linux", vars: map[string]string{"WAYLAND_DISPLAY": "wayland-0", "DISPLAY": ":0"}, path: []string{"wl-copy", "xclip"}, want: "wl-copy"},
+		{name: "xwayland without wl-copy", goos: "linux", vars: map[string]string{"WAYLAND_DISPLAY": "wayland-0", "DISPLAY": ":0"}, path: []string{"xclip"}, want: "xclip"},
+		{name: "x11 with xsel", goos: "linux", vars: map[string]string{"DISPLAY": ":0"}, path: []string{"xsel"}, want: "xsel"},
+		{name: "wsl", goos: "linux", vars: map[string]string{"WSL_DISTRO_NAME": "Ubuntu", "DISPLAY": ":0"}, path: []string{"clip.exe", "xclip"}, want: "clip.exe"},
+		{name: "linux over SSH", goos: "linux", vars: map[string]string{"DISPLAY": "localhost:10.0", "SSH_CONNECTION": "10.0.0.1 1 10.0.0.2 22"}, path: []string{"xclip"}, terminal: true, want: "OSC 52"},
+		{name: "headless terminal", goos: "linux", terminal: true, want: "OSC 52"},
+		{name: "headless without terminal", goos: "linux", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := &fakeSystem{vars: tt.vars, tools: tt.path}
+			var terminal io.Writer
+			if tt.terminal {
+				terminal = &bytes.Buffer{}
+			}
+
+			b, err := DetectFor(tt.goos, env, terminal)
+
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnavailable) {
+					t.Fatalf("DetectFor() error = %v, want %v", err, ErrUnavailable)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DetectFor() error = %v", err)
+			}
+			if b.Name() != tt.want {
+				t.Errorf("DetectFor() = %s, want %s", b.Name(), tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandBackend(t *testing.T) {
+	env := &fakeSystem{vars: map[string]string{"DISPLAY": ":0"}, tools: []string{"xclip"}}
+	b, err := DetectFor("linux", env, nil)
+	if err != nil {
+		t.Fatalf("DetectFor() error = %v", err)
+	}
+
+	if err := b.Copy("ABCD-EFGH"); err != nil {
+		t.Fatalf("Copy() error = %v", err)
+	}
+	if env.clipboard != "ABCD-EFGH" {
+		t.Errorf("clipboard = %q, want ABCD-EFGH", env.clipboard)
+	}
+	if got := strings.Join(env.ran[0], " "); got != "xclip -selection clipboard" {
+		t.Errorf("ran %q", got)
+	}
+}
+
+func TestOSC52(t *testing.T) {
+	tests := []struct {
+		name        string
+		multiplexed bool
+		want        string
+	}{
+		{"plain", false, "\033]52;c;aGk=\a"},
+		{"tmux", true, "\033Ptmux;\033\033]52;c;aGk=\a\033\\"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			b := &OSC52{Out: &out, Multiplexed: tt.multiplexed}
+			if err := b.Copy("hi"); err != nil {
+				t.Fatalf("Copy() error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("Copy() wrote %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestClearIf(t *testing.T) {
+	env := &fakeSystem{vars: map[string]string{"DISPLAY": ":0"}, tools: []string{"xclip"}}
+	b, _ := DetectFor("linux", env, nil)
+	secret := "export AWS_SECRET_ACCESS_KEY=secret"
+
+	b.Copy(secret)
+	if err := ClearIf(b, Fingerprint(secret)); err != nil {
+		t.Fatalf("ClearIf() error = %v", err)
+	}
+	if env.clipboard != "" {
+		t.Errorf("clipboard = %q after ClearIf, want it empty", env.clipboard)
+	}
+
+	// Text copied after the secret is left alone
+	b.Copy(secret)
+	b.Copy("something else")
+	if err := ClearIf(b, Fingerprint(secret)); err != nil {
+		t.Fatalf("ClearIf() error = %v", err)
+	}
+	if env.clipboard != "something else" {
+		t.Errorf("clipboard = %q, want the later copy kept", env.clipboard)
+	}
+}
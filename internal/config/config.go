@@ -6,24 +6,160 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
 )
 
 const (
 	ConfigDir  = ".aws-terminal"
 	ConfigFile = "config.json"
+	StateDir   = "state"
 )
 
 // Profile represents an AWS SSO profile configuration
 type Profile struct {
-	Name    string `json:"name"`
-	SSOUrl  string `json:"sso_url"`
-	Region  string `json:"region,omitempty"`
-	Default bool   `json:"default,omitempty"`
+	Name    string                  `json:"name"`
+	SSOUrl  string                  `json:"sso_url"`
+	Region  string                  `json:"region,omitempty"`
+	Default bool                    `json:"default,omitempty"`
+	Aliases map[string]AccountAlias `json:"aliases,omitempty"`
+
+	Production *ProductionRules `json:"production,omitempty"`
+	Browser    *BrowserSettings `json:"browser,omitempty"`
+	Console    *ConsoleSettings `json:"console,omitempty"`
+
+	// Targets are named account/role selections, used with --target
+	Targets map[string]Target `json:"targets,omitempty"`
+
+	// STSEndpoint overrides the STS endpoint used for role chains, e.g. for a local stub
+	STSEndpoint string `json:"sts_endpoint,omitempty"`
+
+	ECR *ECRSettings `json:"ecr,omitempty"`
+}
+
+// ECRSettings choose the target whose credentials the Docker credential
+// helper uses for ECR registries
+type ECRSettings struct {
+	// Target is used for registries without an entry in Registries
+	Target string `json:"target,omitempty"`
+
+	// Registries maps a registry hostname or account ID to a target
+	Registries map[string]string `json:"registries,omitempty"`
+
+	// Endpoint overrides the ECR API endpoint, e.g. for a local stub
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// Target names an SSO account and role, optionally followed by a chain of
+// roles assumed with STS using the SSO role's credentials
+type Target struct {
+	Account string    `json:"account"`
+	Role    string    `json:"role"`
+	Chain   []RoleHop `json:"chain,omitempty"`
+
+	// Production, or an environment of "prod", applies the production
+	// guards even if the SSO account is not tagged, e.g. when the chain
+	// leads into a production account
+	Production  bool   `json:"production,omitempty"`
+	Environment string `json:"environment,omitempty"`
+}
+
+// IsProduction reports whether the target is tagged as production
+func (t *Target) IsProduction() bool {
+	return t.Production || IsProductionEnvironment(t.Environment)
+}
+
+// RoleHop is one STS AssumeRole call in a target's role chain. SessionName
+// may use the placeholders {profile}, {target}, {account_id}, {role} and {user}.
+type RoleHop struct {
+	RoleArn         string `json:"role_arn"`
+	ExternalId      string `json:"external_id,omitempty"`
+	SessionName     string `json:"session_name,omitempty"`
+	MFASerial       string `json:"mfa_serial,omitempty"`
+	DurationSeconds int32  `json:"duration_seconds,omitempty"`
+}
+
+// ConsoleSettings control how "aws-term console" signs in to the web console
+type ConsoleSettings struct {
+	// FederationURL overrides the sign-in federation endpoint, e.g. for
+	// another partition or a local stub
+	FederationURL string `json:"federation_url,omitempty"`
+	Service       string `json:"service,omitempty"`
+	Region        string `json:"region,omitempty"`
+
+	// Isolation opens each account in its own browser context so console
+	// sessions do not replace each other: "firefox-container" or "chrome"
+	Isolation string `json:"isolation,omitempty"`
+
+	// ChromeDataDir is where per-account Chrome user data directories are
+	// created; the state directory if empty
+	ChromeDataDir string `json:"chrome_data_dir,omitempty"`
+}
+
+// BrowserSettings control which browser opens the SSO login for a profile, so
+// different IdP tenants can be signed into from different browser profiles
+type BrowserSettings struct {
+	Name           string `json:"name,omitempty"`
+	Command        string `json:"command,omitempty"`
+	ChromeProfile  string `json:"chrome_profile,omitempty"`
+	FirefoxProfile string `json:"firefox_profile,omitempty"`
+	Private        bool   `json:"private,omitempty"`
+	NewWindow      bool   `json:"new_window,omitempty"`
+}
+
+// ProductionRules tag accounts as production. An account matching any rule,
+// or whose alias has the "prod" environment, gets the production safety guards.
+type ProductionRules struct {
+	AccountIds   []string `json:"account_ids,omitempty"`
+	NamePattern  string   `json:"name_pattern,omitempty"`
+	Aliases      []string `json:"aliases,omitempty"`
+	AllowedRoles []string `json:"allowed_roles,omitempty"`
+
+	// namePattern is NamePattern compiled on first use, so checking every
+	// account of a picker does not compile it again
+	namePatternOnce sync.Once
+	namePattern     *regexp.Regexp
+	namePatternErr  error
+}
+
+// nameRegexp returns the compiled NamePattern, or nil if none is set. An
+// invalid pattern is reported the first time and on every call after.
+func (r *ProductionRules) nameRegexp() (*regexp.Regexp, error) {
+	r.namePatternOnce.Do(func() {
+		if r.NamePattern == "" {
+			return
+		}
+		r.namePattern, r.namePatternErr = regexp.Compile(r.NamePattern)
+		if r.namePatternErr != nil {
+			r.namePatternErr = fmt.Errorf("invalid production name_pattern: %w", r.namePatternErr)
+		}
+	})
+	return r.namePattern, r.namePatternErr
+}
+
+// AccountAlias gives an account a friendly name, keyed by account ID in Profile.Aliases
+type AccountAlias struct {
+	Name        string `json:"name"`
+	Color       string `json:"color,omitempty"`
+	Environment string `json:"environment,omitempty"`
 }
 
 // Config represents the application configuration
 type Config struct {
-	Profiles []Profile `json:"profiles"`
+	Profiles []Profile      `json:"profiles"`
+	Theme    *ThemeSettings `json:"theme,omitempty"`
+}
+
+// ThemeSettings choose the colors and symbols of the interface
+type ThemeSettings struct {
+	// Name is a built-in theme: "dark" (the default), "light",
+	// "high-contrast" or "ascii"
+	Name string `json:"name,omitempty"`
+
+	// ASCII uses plain ASCII symbols with any theme, for terminals or
+	// fonts without Unicode box drawing and symbols
+	ASCII bool `json:"ascii,omitempty"`
 }
 
 // GetConfigPath returns the full path to the config file
@@ -44,6 +180,16 @@ func GetConfigDir() (string, error) {
 	return filepath.Join(homeDir, ConfigDir), nil
 }
 
+// GetStateDir returns the directory for runtime state such as selection history.
+// It is kept apart from config.json so it can be cleared without losing profiles.
+func GetStateDir() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, StateDir), nil
+}
+
 // Load reads the configuration from the config file
 func Load() (*Config, error) {
 	configPath, err := GetConfigPath()
@@ -143,6 +289,107 @@ func (c *Config) SetDefault(name string) {
 	}
 }
 
+// AliasFor returns the alias configured for an account in this profile
+func (p *Profile) AliasFor(accountId string) (AccountAlias, bool) {
+	alias, ok := p.Aliases[accountId]
+	return alias, ok && alias.Name != ""
+}
+
+// AccountDisplayName returns the alias of an account, or accountName if it has none
+func (p *Profile) AccountDisplayName(accountId, accountName string) string {
+	if alias, ok := p.AliasFor(accountId); ok {
+		return alias.Name
+	}
+	return accountName
+}
+
+// TargetByName returns the target with the given name, ignoring case
+func (p *Profile) TargetByName(name string) (*Target, error) {
+	for targetName, target := range p.Targets {
+		if strings.EqualFold(targetName, name) {
+			return &target, nil
+		}
+	}
+	return nil, fmt.Errorf("target '%s' not found in profile '%s'", name, p.Name)
+}
+
+// ECRTarget returns the target configured for an ECR registry, by hostname,
+// then account ID, then the profile's default ECR target
+func (p *Profile) ECRTarget(host, accountId string) string {
+	if p.ECR == nil {
+		return ""
+	}
+	if target, ok := p.ECR.Registries[host]; ok {
+		return target
+	}
+	if target, ok := p.ECR.Registries[accountId]; ok {
+		return target
+	}
+	return p.ECR.Target
+}
+
+// IsProductionEnvironment reports whether an environment tag means production
+func IsProductionEnvironment(env string) bool {
+	switch strings.ToLower(env) {
+	case "prod", "production":
+		return true
+	default:
+		return false
+	}
+}
+
+// IsProduction reports whether an account is tagged as production, by ID,
+// account name pattern or alias
+func (p *Profile) IsProduction(accountId, accountName string) (bool, error) {
+	alias, hasAlias := p.AliasFor(accountId)
+	if hasAlias && IsProductionEnvironment(alias.Environment) {
+		return true, nil
+	}
+
+	rules := p.Production
+	if rules == nil {
+		return false, nil
+	}
+
+	for _, id := range rules.AccountIds {
+		if id == accountId {
+			return true, nil
+		}
+	}
+
+	if hasAlias {
+		for _, a := range rules.Aliases {
+			if strings.EqualFold(a, alias.Name) {
+				return true, nil
+			}
+		}
+	}
+
+	re, err := rules.nameRegexp()
+	if err != nil {
+		return false, err
+	}
+	if re != nil && re.MatchString(accountName) {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// ProductionRoleAllowed reports whether a role may be used in a production
+// account. Every role is allowed unless allowed_roles is set.
+func (p *Profile) ProductionRoleAllowed(roleName string) bool {
+	if p.Production == nil || len(p.Production.AllowedRoles) == 0 {
+		return true
+	}
+	for _, r := range p.Production.AllowedRoles {
+		if strings.EqualFold(r, roleName) {
+			return true
+		}
+	}
+	return false
+}
+
 // ProfileExists checks if a profile with the given URL already exists
 func (c *Config) ProfileExists(ssoUrl string) bool {
 	for _, p := range c.Profiles {
@@ -152,4 +399,3 @@ func (c *Config) ProfileExists(ssoUrl string) bool {
 	}
 	return false
 }
-
@@ -0,0 +1,89 @@
+package config
+
+import "testing"
+
+func TestProfileIsProduction(t *testing.T) {
+	profile := &Profile{
+		Aliases: map[string]AccountAlias{
+			"111111111111": {Name: "payments", Environment: "prod"},
+			"222222222222": {Name: "billing-live"},
+		},
+		Production: &ProductionRules{
+			AccountIds:  []string{"333333333333"},
+			NamePattern: "-prd-",
+			Aliases:     []string{"Billing-Live"},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		accountId   string
+		accountName string
+		want        bool
+	}{
+		{name: "alias environment", accountId: "111111111111", accountName: "payments", want: true},
+		{name: "alias rule", accountId: "222222222222", accountName: "billing", want: true},
+		{name: "account ID", accountId: "333333333333", accountName: "core", want: true},
+		{name: "name pattern", accountId: "444444444444", accountName: "data-prd-eu", want: true},
+		{name: "untagged", accountId: "555555555555", accountName: "sandbox", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := profile.IsProduction(tt.accountId, tt.accountName)
+			if err != nil {
+				t.Fatalf("IsProduction() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("IsProduction() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProfileIsProductionInvalidPattern(t *testing.T) {
+	profile := &Profile{Production: &ProductionRules{NamePattern: "("}}
+	// The error is reported on every check, not only when first compiled
+	for i := 0; i < 2; i++ {
+		if _, err := profile.IsProduction("111111111111", "dev"); err == nil {
+			t.Error("IsProduction() accepted an invalid name_pattern")
+		}
+	}
+}
+
+func TestProductionNamePatternCompiledOnce(t *testing.T) {
+	rules := &ProductionRules{NamePattern: "-prod$"}
+	profile := &Profile{Production: rules}
+
+	if isProd, err := profile.IsProduction("111111111111", "payments-prod"); err != nil || !isProd {
+		t.Fatalf("IsProduction() = %v, %v, want true", isProd, err)
+	}
+	compiled := rules.namePattern
+	if isProd, err := profile.IsProduction("222222222222", "payments-dev"); err != nil || isProd {
+		t.Fatalf("IsProduction() = %v, %v, want false", isProd, err)
+	}
+	if compiled == nil || rules.namePattern != compiled {
+		t.Error("name_pattern was compiled again")
+	}
+}
+
+func TestTargetIsProduction(t *testing.T) {
+	tests := []struct {
+		name   string
+		target Target
+		want   bool
+	}{
+		{name: "untagged", target: Target{Account: "shared", Role: "Admin"}, want: false},
+		{name: "production", target: Target{Production: true}, want: true},
+		{name: "environment", target: Target{Environment: "Production"}, want: true},
+		{name: "other environment", target: Target{Environment: "staging"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.target.IsProduction(); got != tt.want {
+				t.Errorf("IsProduction() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
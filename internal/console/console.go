@@ -0,0 +1,133 @@
+package console
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ysaakpr/aws-term/internal/sso"
+)
+
+const (
+	// DefaultFederationURL is the AWS sign-in federation endpoint for the commercial partition
+	DefaultFederationURL = "https://signin.aws.amazon.com/federation"
+
+	// DefaultDestination is the console page opened when no service is given
+	DefaultDestination = "https://console.aws.amazon.com/"
+
+	// Issuer identifies aws-term on the console's session-expired page
+	Issuer = "aws-term"
+)
+
+// Client exchanges temporary credentials for console sign-in URLs
+type Client struct {
+	// FederationURL is the federation endpoint; DefaultFederationURL if empty
+	FederationURL string
+
+	HTTPClient *http.Client
+}
+
+// NewClient creates a console client for the given federation endpoint
+func NewClient(federationURL string) *Client {
+	if federationURL == "" {
+		federationURL = DefaultFederationURL
+	}
+	return &Client{
+		FederationURL: federationURL,
+		HTTPClient:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// SigninToken exchanges credentials for a sign-in token at the federation endpoint
+func (c *Client) SigninToken(ctx context.Context, creds *sso.Credentials) (string, error) {
+	session, err := json.Marshal(map[string]string{
+		"sessionId":    creds.AccessKeyId,
+		"sessionKey":   creds.SecretAccessKey,
+		"sessionToken": creds.SessionToken,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	query := url.Values{}
+	query.Set("Action", "getSigninToken")
+	query.Set("Session", string(session))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.FederationURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create sign-in token request: %w", err)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get sign-in token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to read sign-in token: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("federation endpoint returned %s", resp.Status)
+	}
+
+	var result struct {
+		SigninToken string `json:"SigninToken"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("failed to parse sign-in token: %w", err)
+	}
+	if result.SigninToken == "" {
+		return "", fmt.Errorf("federation endpoint returned no sign-in token")
+	}
+
+	return result.SigninToken, nil
+}
+
+// LoginURL returns a URL that signs in to the console with the credentials
+// and then opens destination
+func (c *Client) LoginURL(ctx context.Context, creds *sso.Credentials, destination string) (string, error) {
+	token, err := c.SigninToken(ctx, creds)
+	if err != nil {
+		return "", err
+	}
+
+	if destination == "" {
+		destination = DefaultDestination
+	}
+
+	query := url.Values{}
+	query.Set("Action", "login")
+	query.Set("Issuer", Issuer)
+	query.Set("Destination", destination)
+	query.Set("SigninToken", token)
+
+	return c.FederationURL + "?" + query.Encode(), nil
+}
+
+// Destination builds the console URL for a service and region. A service
+// given as a full URL is returned unchanged; an empty service is the console home.
+func Destination(service, region string) string {
+	if strings.HasPrefix(service, "https://") || strings.HasPrefix(service, "http://") {
+		return service
+	}
+
+	service = strings.Trim(service, "/")
+	if service == "" {
+		if region == "" {
+			return DefaultDestination
+		}
+		return fmt.Sprintf("https://%s.console.aws.amazon.com/console/home?region=%s", region, url.QueryEscape(region))
+	}
+
+	if region == "" {
+		return fmt.Sprintf("https://console.aws.amazon.com/%s/home", service)
+	}
+	return fmt.Sprintf("https://%s.console.aws.amazon.com/%s/home?region=%s", region, service, url.QueryEscape(region))
+}
@@ -0,0 +1,111 @@
+package console
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ysaakpr/aws-term/internal/sso"
+)
+
+func TestLoginURL(t *testing.T) {
+	creds := &sso.Credentials{AccessKeyId: "ASIACONSOLE", SecretAccessKey: "secret", SessionToken: "token"}
+
+	var session map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("Action") != "getSigninToken" {
+			http.Error(w, "unexpected action", http.StatusBadRequest)
+			return
+		}
+		json.Unmarshal([]byte(r.URL.Query().Get("Session")), &session)
+		json.NewEncoder(w).Encode(map[string]string{"SigninToken": "signin-token"})
+	}))
+	defer server.Close()
+
+	destination := Destination("s3", "eu-west-1")
+	loginURL, err := NewClient(server.URL).LoginURL(context.Background(), creds, destination)
+	if err != nil {
+		t.Fatalf("LoginURL() error = %v", err)
+	}
+
+	want := map[string]string{"sessionId": "ASIACONSOLE", "sessionKey": "secret", "sessionToken": "token"}
+	for k, v := range want {
+		if session[k] != v {
+			t.Errorf("session %s = %q, want %q", k, session[k], v)
+		}
+	}
+
+	u, err := url.Parse(loginURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(loginURL, server.URL+"?") {
+		t.Errorf("LoginURL() = %s, want the federation endpoint", loginURL)
+	}
+	query := u.Query()
+	if query.Get("Action") != "login" || query.Get("Issuer") != Issuer || query.Get("SigninToken") != "signin-token" || query.Get("Destination") != destination {
+		t.Errorf("LoginURL() query = %v", query)
+	}
+}
+
+func TestSigninTokenErrors(t *testing.T) {
+	creds := &sso.Credentials{AccessKeyId: "ASIACONSOLE"}
+
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{name: "rejected", status: http.StatusBadRequest, body: "{}"},
+		{name: "not JSON", status: http.StatusOK, body: "<html>"},
+		{name: "no token", status: http.StatusOK, body: "{}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			if _, err := NewClient(server.URL).SigninToken(context.Background(), creds); err == nil {
+				t.Error("SigninToken() succeeded")
+			}
+		})
+	}
+}
+
+func TestDestination(t *testing.T) {
+	tests := []struct {
+		service, region, want string
+	}{
+		{"", "", DefaultDestination},
+		{"", "eu-west-1", "https://eu-west-1.console.aws.amazon.com/console/home?region=eu-west-1"},
+		{"/s3/", "", "https://console.aws.amazon.com/s3/home"},
+		{"ec2", "us-east-2", "https://us-east-2.console.aws.amazon.com/ec2/home?region=us-east-2"},
+		{"https://example.com/page", "eu-west-1", "https://example.com/page"},
+	}
+
+	for _, tt := range tests {
+		if got := Destination(tt.service, tt.region); got != tt.want {
+			t.Errorf("Destination(%q, %q) = %s, want %s", tt.service, tt.region, got, tt.want)
+		}
+	}
+}
+
+func TestContainerColor(t *testing.T) {
+	if got := ContainerColor("111111111111", "green", true); got != "red" {
+		t.Errorf("production container = %s, want red", got)
+	}
+	if got := ContainerColor("111111111111", "Cyan", false); got != "turquoise" {
+		t.Errorf("cyan alias container = %s, want turquoise", got)
+	}
+	if a, b := ContainerColor("111111111111", "", false), ContainerColor("111111111111", "", false); a != b {
+		t.Errorf("derived colors differ: %s, %s", a, b)
+	}
+}
@@ -0,0 +1,82 @@
+package console
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// IsolationNone opens the console in the login browser as is
+	IsolationNone = "none"
+
+	// IsolationFirefoxContainer opens each account in its own Firefox
+	// Multi-Account Container through an ext+container: URL
+	IsolationFirefoxContainer = "firefox-container"
+
+	// IsolationChrome opens each account in Chrome with its own user data directory
+	IsolationChrome = "chrome"
+
+	// containerIcon is the Firefox container icon used for every account
+	containerIcon = "briefcase"
+)
+
+// containerColors are the colors Firefox containers support, in the order
+// accounts without a configured color are assigned them
+var containerColors = []string{"blue", "turquoise", "green", "yellow", "orange", "red", "pink", "purple"}
+
+// ValidateIsolation checks an isolation mode from the config or command line
+func ValidateIsolation(mode string) error {
+	switch mode {
+	case "", IsolationNone, IsolationFirefoxContainer, IsolationChrome:
+		return nil
+	default:
+		return fmt.Errorf("unknown console isolation '%s' (use %s, %s or %s)", mode, IsolationFirefoxContainer, IsolationChrome, IsolationNone)
+	}
+}
+
+// ContainerName returns the Firefox container name for an account
+func ContainerName(displayName string) string {
+	return "AWS " + displayName
+}
+
+// ContainerColor maps an alias color to a Firefox container color. Production
+// accounts are always red; accounts without a color get one derived from their ID.
+func ContainerColor(accountId, aliasColor string, isProduction bool) string {
+	if isProduction {
+		return "red"
+	}
+
+	switch strings.ToLower(aliasColor) {
+	case "red", "green", "yellow", "blue":
+		return strings.ToLower(aliasColor)
+	case "magenta":
+		return "purple"
+	case "cyan":
+		return "turquoise"
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(accountId))
+	return containerColors[h.Sum32()%uint32(len(containerColors))]
+}
+
+// ContainerURL wraps target in an ext+container: URL that the "Open external
+// links in a container" Firefox extension opens in the named container,
+// creating it with the given color if needed
+func ContainerURL(name, color, target string) string {
+	// The extension reads the parameters as a query string
+	query := url.Values{}
+	query.Set("name", name)
+	query.Set("color", color)
+	query.Set("icon", containerIcon)
+	query.Set("url", target)
+	return "ext+container:" + query.Encode()
+}
+
+// ChromeUserDataDir returns the Chrome user data directory for an account under baseDir
+func ChromeUserDataDir(baseDir, accountId string) string {
+	return filepath.Join(baseDir, "chrome", accountId)
+}
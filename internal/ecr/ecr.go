@@ -0,0 +1,90 @@
+package ecr
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/ysaakpr/aws-term/internal/sso"
+)
+
+// registryPattern matches ECR registry hostnames, e.g.
+// 123456789012.dkr.ecr.eu-west-1.amazonaws.com
+var registryPattern = regexp.MustCompile(`^(\d{12})\.dkr\.ecr(?:-fips)?\.([a-z0-9-]+)\.amazonaws\.com(?:\.cn)?$`)
+
+// Registry is a private ECR registry
+type Registry struct {
+	Host      string
+	AccountId string
+	Region    string
+}
+
+// ParseRegistry parses a Docker server URL such as
+// https://123456789012.dkr.ecr.eu-west-1.amazonaws.com/v2/. It reports false
+// if the URL is not an ECR registry.
+func ParseRegistry(serverURL string) (*Registry, bool) {
+	host := strings.TrimSpace(serverURL)
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+	if i := strings.IndexAny(host, "/:"); i >= 0 {
+		host = host[:i]
+	}
+	host = strings.ToLower(host)
+
+	m := registryPattern.FindStringSubmatch(host)
+	if m == nil {
+		return nil, false
+	}
+	return &Registry{Host: host, AccountId: m[1], Region: m[2]}, true
+}
+
+// Authorization is a Docker login for ECR registries
+type Authorization struct {
+	Username  string
+	Password  string
+	ExpiresAt time.Time
+}
+
+// GetAuthorization gets an ECR authorization token in region with the given
+// credentials and splits it into a username and password. An empty endpoint
+// uses the regional ECR endpoint.
+func GetAuthorization(ctx context.Context, creds *sso.Credentials, region, endpoint string) (*Authorization, error) {
+	opts := ecr.Options{
+		Region:      region,
+		Credentials: creds.Provider(),
+	}
+	if endpoint != "" {
+		opts.BaseEndpoint = aws.String(endpoint)
+	}
+
+	output, err := ecr.New(opts).GetAuthorizationToken(ctx, &ecr.GetAuthorizationTokenInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ECR authorization token: %w", err)
+	}
+	if len(output.AuthorizationData) == 0 {
+		return nil, fmt.Errorf("ECR returned no authorization data")
+	}
+
+	data := output.AuthorizationData[0]
+	decoded, err := base64.StdEncoding.DecodeString(aws.ToString(data.AuthorizationToken))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode ECR authorization token: %w", err)
+	}
+
+	username, password, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return nil, fmt.Errorf("ECR authorization token is not in user:password form")
+	}
+
+	return &Authorization{
+		Username:  username,
+		Password:  password,
+		ExpiresAt: aws.ToTime(data.ExpiresAt),
+	}, nil
+}
@@ -0,0 +1,118 @@
+package ecr
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ysaakpr/aws-term/internal/sso"
+)
+
+func TestParseRegistry(t *testing.T) {
+	tests := []struct {
+		name      string
+		serverURL string
+		want      *Registry
+	}{
+		{
+			name:      "host",
+			serverURL: "123456789012.dkr.ecr.eu-west-1.amazonaws.com",
+			want:      &Registry{Host: "123456789012.dkr.ecr.eu-west-1.amazonaws.com", AccountId: "123456789012", Region: "eu-west-1"},
+		},
+		{
+			name:      "URL with path",
+			serverURL: "https://123456789012.dkr.ecr.us-east-1.amazonaws.com/v2/",
+			want:      &Registry{Host: "123456789012.dkr.ecr.us-east-1.amazonaws.com", AccountId: "123456789012", Region: "us-east-1"},
+		},
+		{
+			name:      "FIPS",
+			serverURL: "123456789012.dkr.ecr-fips.us-gov-west-1.amazonaws.com",
+			want:      &Registry{Host: "123456789012.dkr.ecr-fips.us-gov-west-1.amazonaws.com", AccountId: "123456789012", Region: "us-gov-west-1"},
+		},
+		{
+			name:      "China with port",
+			serverURL: "123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn:443",
+			want:      &Registry{Host: "123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn", AccountId: "123456789012", Region: "cn-north-1"},
+		},
+		{name: "Docker Hub", serverURL: "https://index.docker.io/v1/"},
+		{name: "public ECR", serverURL: "public.ecr.aws"},
+		{name: "short account ID", serverURL: "12345.dkr.ecr.eu-west-1.amazonaws.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseRegistry(tt.serverURL)
+			if ok != (tt.want != nil) {
+				t.Fatalf("ParseRegistry() ok = %v, want %v", ok, tt.want != nil)
+			}
+			if ok && *got != *tt.want {
+				t.Errorf("ParseRegistry() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// newECRServer fakes GetAuthorizationToken, answering with token, and
+// records the Authorization header of the request
+func newECRServer(t *testing.T, token string, expiresAt time.Time) (*httptest.Server, *string) {
+	t.Helper()
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Amz-Target"); !strings.HasSuffix(got, ".GetAuthorizationToken") {
+			http.Error(w, "unexpected target "+got, http.StatusBadRequest)
+			return
+		}
+		auth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		json.NewEncoder(w).Encode(map[string]any{
+			"authorizationData": []map[string]any{{
+				"authorizationToken": token,
+				"expiresAt":          expiresAt.Unix(),
+				"proxyEndpoint":      "https://123456789012.dkr.ecr.eu-west-1.amazonaws.com",
+			}},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server, &auth
+}
+
+func TestGetAuthorization(t *testing.T) {
+	creds := &sso.Credentials{AccessKeyId: "ASIAECRTEST", SecretAccessKey: "secret", SessionToken: "token"}
+	expiresAt := time.Now().Add(12 * time.Hour).Truncate(time.Second)
+	server, auth := newECRServer(t, base64.StdEncoding.EncodeToString([]byte("AWS:pass:with:colons")), expiresAt)
+
+	got, err := GetAuthorization(context.Background(), creds, "eu-west-1", server.URL)
+	if err != nil {
+		t.Fatalf("GetAuthorization() error = %v", err)
+	}
+	if got.Username != "AWS" || got.Password != "pass:with:colons" {
+		t.Errorf("GetAuthorization() = %s / %s, want AWS / pass:with:colons", got.Username, got.Password)
+	}
+	if !got.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("ExpiresAt = %s, want %s", got.ExpiresAt, expiresAt)
+	}
+	if !strings.Contains(*auth, "Credential="+creds.AccessKeyId+"/") || !strings.Contains(*auth, "/eu-west-1/ecr/") {
+		t.Errorf("request signed with %q, want %s in eu-west-1", *auth, creds.AccessKeyId)
+	}
+}
+
+func TestGetAuthorizationMalformedToken(t *testing.T) {
+	creds := &sso.Credentials{AccessKeyId: "ASIAECRTEST", SecretAccessKey: "secret"}
+
+	for name, token := range map[string]string{
+		"not base64":    "%%%",
+		"missing colon": base64.StdEncoding.EncodeToString([]byte("AWS")),
+	} {
+		t.Run(name, func(t *testing.T) {
+			server, _ := newECRServer(t, token, time.Now().Add(time.Hour))
+			if _, err := GetAuthorization(context.Background(), creds, "eu-west-1", server.URL); err == nil {
+				t.Error("GetAuthorization() accepted a malformed token")
+			}
+		})
+	}
+}
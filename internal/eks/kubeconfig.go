@@ -0,0 +1,188 @@
+package eks
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ExecUser is a kubeconfig user whose token comes from running aws-term
+type ExecUser struct {
+	Name    string
+	Command string
+	Args    []string
+}
+
+// kubeUser is the kubeconfig representation of an ExecUser
+type kubeUser struct {
+	Name string `yaml:"name"`
+	User struct {
+		Exec kubeExec `yaml:"exec"`
+	} `yaml:"user"`
+}
+
+type kubeExec struct {
+	APIVersion         string   `yaml:"apiVersion"`
+	Command            string   `yaml:"command"`
+	Args               []string `yaml:"args"`
+	InteractiveMode    string   `yaml:"interactiveMode"`
+	ProvideClusterInfo bool     `yaml:"provideClusterInfo"`
+}
+
+// emptyKubeconfig is used when the kubeconfig file does not exist yet
+const emptyKubeconfig = `apiVersion: v1
+kind: Config
+clusters: []
+contexts: []
+users: []
+`
+
+// DefaultKubeconfigPath returns the first file in $KUBECONFIG, or ~/.kube/config
+func DefaultKubeconfigPath() (string, error) {
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		for _, path := range filepath.SplitList(env) {
+			if path != "" {
+				return path, nil
+			}
+		}
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".kube", "config"), nil
+}
+
+// WriteUser adds the user to the kubeconfig at path, replacing a user with the
+// same name. If contextName is set, that context is switched to the user.
+// Other entries, including comments, are kept as they are.
+func WriteUser(path string, user ExecUser, contextName string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read kubeconfig: %w", err)
+		}
+		data = []byte(emptyKubeconfig)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		data = []byte(emptyKubeconfig)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse kubeconfig: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("kubeconfig %s is not a YAML mapping", path)
+	}
+	root := doc.Content[0]
+
+	entry := kubeUser{Name: user.Name}
+	entry.User.Exec = kubeExec{
+		APIVersion:      DefaultExecAPIVersion,
+		Command:         user.Command,
+		Args:            user.Args,
+		InteractiveMode: "IfAvailable",
+	}
+	var entryNode yaml.Node
+	if err := entryNode.Encode(entry); err != nil {
+		return fmt.Errorf("failed to encode user: %w", err)
+	}
+
+	users := sequenceValue(root, "users")
+	if i := findNamed(users, user.Name); i >= 0 {
+		users.Content[i] = &entryNode
+	} else {
+		users.Content = append(users.Content, &entryNode)
+	}
+
+	if contextName != "" {
+		contexts := sequenceValue(root, "contexts")
+		i := findNamed(contexts, contextName)
+		if i < 0 {
+			return fmt.Errorf("context '%s' not found in %s", contextName, path)
+		}
+		ctx := mappingValue(contexts.Content[i], "context")
+		if ctx == nil || ctx.Kind != yaml.MappingNode {
+			return fmt.Errorf("context '%s' has no context section", contextName)
+		}
+		setScalar(ctx, "user", user.Name)
+	}
+
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return fmt.Errorf("failed to encode kubeconfig: %w", err)
+	}
+	enc.Close()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create kubeconfig directory: %w", err)
+	}
+	if err := os.WriteFile(path, out.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write kubeconfig: %w", err)
+	}
+
+	return nil
+}
+
+// mappingValue returns the value node for key in a mapping, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// sequenceValue returns the sequence stored under key, creating it if it is
+// missing or null
+func sequenceValue(mapping *yaml.Node, key string) *yaml.Node {
+	value := mappingValue(mapping, key)
+	if value != nil && value.Kind == yaml.SequenceNode {
+		return value
+	}
+
+	seq := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	if value != nil {
+		*value = *seq
+		return value
+	}
+	mapping.Content = append(mapping.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		seq,
+	)
+	return seq
+}
+
+// setScalar sets key in a mapping to a string value
+func setScalar(mapping *yaml.Node, key, value string) {
+	if node := mappingValue(mapping, key); node != nil {
+		*node = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+		return
+	}
+	mapping.Content = append(mapping.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value},
+	)
+}
+
+// findNamed returns the index of the entry with the given name in a
+// kubeconfig list, or -1
+func findNamed(seq *yaml.Node, name string) int {
+	for i, item := range seq.Content {
+		if item.Kind != yaml.MappingNode {
+			continue
+		}
+		if n := mappingValue(item, "name"); n != nil && n.Value == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,132 @@
+package eks
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// testKubeconfig has a cluster, a context and an unrelated user, with a comment
+const testKubeconfig = `apiVersion: v1
+kind: Config
+# managed by hand
+clusters:
+  - name: prod-eks
+    cluster:
+      server: https://prod.example.com
+contexts:
+  - name: prod
+    context:
+      cluster: prod-eks
+      user: admin
+users:
+  - name: admin
+    user:
+      token: static-token
+current-context: prod
+`
+
+// kubeconfig is the part of a kubeconfig the tests look at
+type kubeconfig struct {
+	Clusters []struct {
+		Name string `yaml:"name"`
+	} `yaml:"clusters"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+	Users          []kubeUser `yaml:"users"`
+	CurrentContext string     `yaml:"current-context"`
+}
+
+func TestWriteUser(t *testing.T) {
+	user := ExecUser{Name: "aws-term-work-prod-eks", Command: "/usr/local/bin/aws-term", Args: []string{"eks-token", "--cluster", "prod-eks", "--target", "eks-admin", "work"}}
+
+	tests := []struct {
+		name        string
+		existing    string // "" means no file
+		contextName string
+		wantUsers   []string
+		wantContext string // user of the prod context
+		wantErr     bool
+	}{
+		{name: "no file", wantUsers: []string{user.Name}},
+		{name: "empty file", existing: "\n", wantUsers: []string{user.Name}},
+		{name: "keeps other entries", existing: testKubeconfig, wantUsers: []string{"admin", user.Name}, wantContext: "admin"},
+		{name: "switches context", existing: testKubeconfig, contextName: "prod", wantUsers: []string{"admin", user.Name}, wantContext: user.Name},
+		{
+			name:      "replaces user",
+			existing:  strings.Replace(testKubeconfig, "name: admin", "name: "+user.Name, 1),
+			wantUsers: []string{user.Name},
+		},
+		{name: "unknown context", existing: testKubeconfig, contextName: "staging", wantErr: true},
+		{name: "not a mapping", existing: "- a\n- b\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".kube", "config")
+			if tt.existing != "" {
+				os.MkdirAll(filepath.Dir(path), 0700)
+				if err := os.WriteFile(path, []byte(tt.existing), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := WriteUser(path, user, tt.contextName)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("WriteUser() succeeded")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("WriteUser() error = %v", err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got kubeconfig
+			if err := yaml.Unmarshal(data, &got); err != nil {
+				t.Fatalf("kubeconfig does not parse: %v", err)
+			}
+
+			var names []string
+			for _, u := range got.Users {
+				names = append(names, u.Name)
+				if u.Name != user.Name {
+					continue
+				}
+				exec := u.User.Exec
+				if exec.Command != user.Command || strings.Join(exec.Args, " ") != strings.Join(user.Args, " ") || exec.APIVersion != DefaultExecAPIVersion {
+					t.Errorf("exec = %+v, want %s %v", exec, user.Command, user.Args)
+				}
+			}
+			if strings.Join(names, ",") != strings.Join(tt.wantUsers, ",") {
+				t.Errorf("users = %v, want %v", names, tt.wantUsers)
+			}
+
+			if tt.existing != testKubeconfig {
+				return
+			}
+			// Unrelated entries are kept as they were
+			if len(got.Clusters) != 1 || got.Clusters[0].Name != "prod-eks" || got.CurrentContext != "prod" {
+				t.Errorf("clusters = %+v, current-context = %q, want them unchanged", got.Clusters, got.CurrentContext)
+			}
+			if len(got.Contexts) != 1 || got.Contexts[0].Context.Cluster != "prod-eks" || got.Contexts[0].Context.User != tt.wantContext {
+				t.Errorf("contexts = %+v, want prod using %s", got.Contexts, tt.wantContext)
+			}
+			if !strings.Contains(string(data), "# managed by hand") || !strings.Contains(string(data), "token: static-token") {
+				t.Errorf("kubeconfig lost unrelated content:\n%s", data)
+			}
+		})
+	}
+}
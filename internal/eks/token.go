@@ -0,0 +1,117 @@
+package eks
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/ysaakpr/aws-term/internal/sso"
+)
+
+const (
+	// TokenPrefix marks a bearer token as a presigned STS request
+	TokenPrefix = "k8s-aws-v1."
+
+	// DefaultExecAPIVersion is the client authentication API used when
+	// kubectl does not say which one it expects
+	DefaultExecAPIVersion = "client.authentication.k8s.io/v1beta1"
+
+	// clusterIDHeader binds the presigned request to one cluster
+	clusterIDHeader = "x-k8s-aws-id"
+
+	// presignExpiry is how long EKS accepts the presigned request
+	presignExpiry = 60
+
+	// tokenLifetime is reported to kubectl, shorter than the presigned
+	// request's 15 minute validity on the server side
+	tokenLifetime = 14 * time.Minute
+)
+
+// Token is a bearer token for an EKS cluster
+type Token struct {
+	Value      string
+	Expiration time.Time
+}
+
+// GetToken presigns an STS GetCallerIdentity request for the cluster with the
+// given credentials and encodes it as an EKS bearer token. An empty endpoint
+// uses the regional STS endpoint.
+func GetToken(ctx context.Context, creds *sso.Credentials, clusterName, region, endpoint string) (*Token, error) {
+	stsOpts := sts.Options{
+		Region:      region,
+		Credentials: creds.Provider(),
+	}
+	if endpoint != "" {
+		stsOpts.BaseEndpoint = aws.String(endpoint)
+	}
+
+	presigner := sts.NewPresignClient(sts.New(stsOpts))
+	request, err := presigner.PresignGetCallerIdentity(ctx, &sts.GetCallerIdentityInput{}, func(po *sts.PresignOptions) {
+		po.ClientOptions = append(po.ClientOptions, func(o *sts.Options) {
+			// X-Amz-* headers are moved into the presigned query string
+			o.APIOptions = append(o.APIOptions,
+				smithyhttp.SetHeaderValue(clusterIDHeader, clusterName),
+				smithyhttp.SetHeaderValue("X-Amz-Expires", fmt.Sprint(presignExpiry)),
+			)
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign GetCallerIdentity: %w", err)
+	}
+
+	// The token is only accepted while the credentials that signed it are
+	// valid, so kubectl must not keep it any longer
+	expiration := time.Now().Add(tokenLifetime)
+	if !creds.Expiration.IsZero() && creds.Expiration.Before(expiration) {
+		expiration = creds.Expiration
+	}
+
+	return &Token{
+		Value:      TokenPrefix + base64.RawURLEncoding.EncodeToString([]byte(request.URL)),
+		Expiration: expiration,
+	}, nil
+}
+
+// ExecCredential is the response of a kubectl exec credential plugin
+type ExecCredential struct {
+	Kind       string               `json:"kind"`
+	APIVersion string               `json:"apiVersion"`
+	Spec       map[string]any       `json:"spec"`
+	Status     ExecCredentialStatus `json:"status"`
+}
+
+// ExecCredentialStatus carries the token and its expiry
+type ExecCredentialStatus struct {
+	ExpirationTimestamp string `json:"expirationTimestamp"`
+	Token               string `json:"token"`
+}
+
+// NewExecCredential wraps a token for kubectl. execInfo is the
+// KUBERNETES_EXEC_INFO environment variable, used to answer in the API
+// version kubectl asked for.
+func NewExecCredential(token *Token, execInfo string) *ExecCredential {
+	apiVersion := DefaultExecAPIVersion
+	if execInfo != "" {
+		var info struct {
+			APIVersion string `json:"apiVersion"`
+		}
+		if json.Unmarshal([]byte(execInfo), &info) == nil && info.APIVersion != "" {
+			apiVersion = info.APIVersion
+		}
+	}
+
+	return &ExecCredential{
+		Kind:       "ExecCredential",
+		APIVersion: apiVersion,
+		Spec:       map[string]any{},
+		Status: ExecCredentialStatus{
+			ExpirationTimestamp: token.Expiration.UTC().Format(time.RFC3339),
+			Token:               token.Value,
+		},
+	}
+}
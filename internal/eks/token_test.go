@@ -0,0 +1,137 @@
+package eks
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ysaakpr/aws-term/internal/rolechain/ststest"
+	"github.com/ysaakpr/aws-term/internal/sso"
+)
+
+var testCreds = &sso.Credentials{
+	AccessKeyId:     "ASIAEKSTEST",
+	SecretAccessKey: "secret",
+	SessionToken:    "token",
+	Expiration:      time.Now().Add(time.Hour),
+}
+
+// decodeToken returns the presigned URL inside a bearer token
+func decodeToken(t *testing.T, token string) *url.URL {
+	t.Helper()
+	if !strings.HasPrefix(token, TokenPrefix) {
+		t.Fatalf("token %q lacks the %s prefix", token, TokenPrefix)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(token, TokenPrefix))
+	if err != nil {
+		t.Fatalf("token is not base64url: %v", err)
+	}
+	u, err := url.Parse(string(raw))
+	if err != nil {
+		t.Fatalf("token does not hold a URL: %v", err)
+	}
+	return u
+}
+
+func TestGetToken(t *testing.T) {
+	server := ststest.NewServer()
+	defer server.Close()
+
+	start := time.Now()
+	token, err := GetToken(context.Background(), testCreds, "prod-eks", "eu-west-1", server.URL)
+	if err != nil {
+		t.Fatalf("GetToken() error = %v", err)
+	}
+
+	if lifetime := token.Expiration.Sub(start); lifetime < tokenLifetime || lifetime > tokenLifetime+time.Minute {
+		t.Errorf("token lasts %s, want %s", lifetime, tokenLifetime)
+	}
+
+	presigned := decodeToken(t, token.Value)
+	query := presigned.Query()
+	if got := query.Get("Action"); got != "GetCallerIdentity" {
+		t.Errorf("Action = %q, want GetCallerIdentity", got)
+	}
+	if got := query.Get("X-Amz-Expires"); got != "60" {
+		t.Errorf("X-Amz-Expires = %q, want 60", got)
+	}
+	if got := query.Get("X-Amz-SignedHeaders"); !strings.Contains(got, clusterIDHeader) {
+		t.Errorf("X-Amz-SignedHeaders = %q, want it to include %s", got, clusterIDHeader)
+	}
+	if got := query.Get("X-Amz-Credential"); !strings.HasPrefix(got, testCreds.AccessKeyId+"/") || !strings.Contains(got, "/eu-west-1/sts/") {
+		t.Errorf("X-Amz-Credential = %q, want %s in eu-west-1", got, testCreds.AccessKeyId)
+	}
+	if got := query.Get("X-Amz-Security-Token"); got != testCreds.SessionToken {
+		t.Errorf("X-Amz-Security-Token = %q, want the session token", got)
+	}
+
+	// EKS replays the request with the cluster header, as kubectl's token holder would
+	req, err := http.NewRequest(http.MethodGet, presigned.String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(clusterIDHeader, "prod-eks")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("presigned request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("presigned request = %s, want 200", resp.Status)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 1 || requests[0].Action != ststest.ActionGetCallerIdentity || requests[0].AccessKeyId != testCreds.AccessKeyId {
+		t.Errorf("STS received %+v, want one GetCallerIdentity signed with %s", requests, testCreds.AccessKeyId)
+	}
+}
+
+func TestGetTokenExpiresWithCredentials(t *testing.T) {
+	server := ststest.NewServer()
+	defer server.Close()
+
+	// Credentials reused from the cache may expire before the token would
+	creds := *testCreds
+	creds.Expiration = time.Now().Add(5 * time.Minute).Truncate(time.Second)
+
+	token, err := GetToken(context.Background(), &creds, "prod-eks", "eu-west-1", server.URL)
+	if err != nil {
+		t.Fatalf("GetToken() error = %v", err)
+	}
+	if !token.Expiration.Equal(creds.Expiration) {
+		t.Errorf("token expires %s, want the credentials' expiry %s", token.Expiration, creds.Expiration)
+	}
+}
+
+func TestNewExecCredential(t *testing.T) {
+	token := &Token{Value: TokenPrefix + "abc", Expiration: time.Date(2026, 10, 18, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))}
+
+	tests := []struct {
+		name     string
+		execInfo string
+		want     string
+	}{
+		{name: "no exec info", want: DefaultExecAPIVersion},
+		{name: "v1", execInfo: `{"apiVersion":"client.authentication.k8s.io/v1","kind":"ExecCredential"}`, want: "client.authentication.k8s.io/v1"},
+		{name: "invalid exec info", execInfo: "{", want: DefaultExecAPIVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cred := NewExecCredential(token, tt.execInfo)
+			if cred.APIVersion != tt.want {
+				t.Errorf("APIVersion = %s, want %s", cred.APIVersion, tt.want)
+			}
+			if cred.Kind != "ExecCredential" || cred.Status.Token != token.Value {
+				t.Errorf("ExecCredential = %+v", cred)
+			}
+			if cred.Status.ExpirationTimestamp != "2026-10-18T10:00:00Z" {
+				t.Errorf("ExpirationTimestamp = %s, want UTC RFC 3339", cred.Status.ExpirationTimestamp)
+			}
+		})
+	}
+}
@@ -0,0 +1,424 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ysaakpr/aws-term/internal/config"
+	"github.com/ysaakpr/aws-term/internal/sso"
+)
+
+const (
+	HistoryFile   = "history.json"
+	FavoritesFile = "favorites.json"
+	BrowserFile   = "browser.json"
+
+	// CorruptSuffix is appended to a state file that could not be parsed
+	CorruptSuffix = ".corrupt"
+
+	// MaxEntries caps the number of selections kept on disk
+	MaxEntries = 500
+
+	// MaxRecent is the number of entries shown in the "recent" section of a picker
+	MaxRecent = 5
+)
+
+// clock returns the current time; tests replace it to score fixed timestamps
+var clock = time.Now
+
+// Entry records a single account/role selection
+type Entry struct {
+	Profile     string    `json:"profile"`
+	AccountId   string    `json:"account_id"`
+	AccountName string    `json:"account_name,omitempty"`
+	RoleName    string    `json:"role_name"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// History holds past selections, oldest first
+type History struct {
+	Entries []Entry `json:"entries"`
+}
+
+// Favorite is a starred account, optionally narrowed to a single role
+type Favorite struct {
+	Profile   string `json:"profile"`
+	AccountId string `json:"account_id"`
+	RoleName  string `json:"role_name,omitempty"`
+}
+
+// Favorites holds the starred accounts and roles for all profiles
+type Favorites struct {
+	Items []Favorite `json:"favorites"`
+}
+
+func statePath(name string) (string, error) {
+	stateDir, err := config.GetStateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDir, name), nil
+}
+
+func readJSON(name string, v interface{}) error {
+	path, err := statePath(name)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		// Move the file aside so saving a fresh one does not lose it
+		if renameErr := os.Rename(path, path+CorruptSuffix); renameErr != nil {
+			return fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		return fmt.Errorf("failed to parse %s (moved to %s%s): %w", name, name, CorruptSuffix, err)
+	}
+	return nil
+}
+
+func writeJSON(name string, v interface{}) error {
+	stateDir, err := config.GetStateDir()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(stateDir, 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize %s: %w", name, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(stateDir, name), data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// Load reads the selection history, returning an empty history if none exists
+func Load() (*History, error) {
+	h := &History{}
+	if err := readJSON(HistoryFile, h); err != nil {
+		return &History{}, err
+	}
+	return h, nil
+}
+
+// Save writes the selection history to the state directory
+func (h *History) Save() error {
+	return writeJSON(HistoryFile, h)
+}
+
+// Record appends a selection, dropping the oldest entries beyond MaxEntries
+func (h *History) Record(entry Entry) {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = clock()
+	}
+	h.Entries = append(h.Entries, entry)
+	if len(h.Entries) > MaxEntries {
+		h.Entries = h.Entries[len(h.Entries)-MaxEntries:]
+	}
+}
+
+// Clear removes all entries for a profile, or every entry if profile is empty
+func (h *History) Clear(profile string) {
+	if profile == "" {
+		h.Entries = nil
+		return
+	}
+
+	kept := h.Entries[:0]
+	for _, e := range h.Entries {
+		if e.Profile != profile {
+			kept = append(kept, e)
+		}
+	}
+	h.Entries = kept
+}
+
+// weight returns the frecency weight of a selection made at t.
+// Recent selections count for more, so a burst of old usage fades over time.
+func weight(t, now time.Time) float64 {
+	age := now.Sub(t)
+	switch {
+	case age < 4*24*time.Hour:
+		return 100
+	case age < 14*24*time.Hour:
+		return 70
+	case age < 31*24*time.Hour:
+		return 50
+	case age < 90*24*time.Hour:
+		return 30
+	default:
+		return 10
+	}
+}
+
+// AccountScores returns the frecency score of every account used with a profile
+func (h *History) AccountScores(profile string) map[string]float64 {
+	now := clock()
+	scores := make(map[string]float64)
+	for _, e := range h.Entries {
+		if e.Profile == profile {
+			scores[e.AccountId] += weight(e.Timestamp, now)
+		}
+	}
+	return scores
+}
+
+// RoleScores returns the frecency score of every role used with an account
+func (h *History) RoleScores(profile, accountId string) map[string]float64 {
+	now := clock()
+	scores := make(map[string]float64)
+	for _, e := range h.Entries {
+		if e.Profile == profile && e.AccountId == accountId {
+			scores[e.RoleName] += weight(e.Timestamp, now)
+		}
+	}
+	return scores
+}
+
+// PairKey identifies an account/role pair in PairScores
+func PairKey(accountId, roleName string) string {
+	return accountId + "/" + roleName
+}
+
+// PairScores returns the frecency score of every account/role pair used with a profile
+func (h *History) PairScores(profile string) map[string]float64 {
+	now := clock()
+	scores := make(map[string]float64)
+	for _, e := range h.Entries {
+		if e.Profile == profile {
+			scores[PairKey(e.AccountId, e.RoleName)] += weight(e.Timestamp, now)
+		}
+	}
+	return scores
+}
+
+// RankByScore returns the keys with a positive score, highest score first
+func RankByScore(scores map[string]float64) []string {
+	keys := make([]string, 0, len(scores))
+	for k, s := range scores {
+		if s > 0 {
+			keys = append(keys, k)
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		if scores[keys[i]] != scores[keys[j]] {
+			return scores[keys[i]] > scores[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// BrowserChoices remembers the browser last picked for each profile
+type BrowserChoices struct {
+	Profiles map[string]string `json:"profiles"`
+}
+
+// LoadBrowserChoices reads the remembered browsers
+func LoadBrowserChoices() (*BrowserChoices, error) {
+	b := &BrowserChoices{}
+	err := readJSON(BrowserFile, b)
+	if b.Profiles == nil {
+		b.Profiles = make(map[string]string)
+	}
+	return b, err
+}
+
+// Save writes the remembered browsers to the state directory
+func (b *BrowserChoices) Save() error {
+	return writeJSON(BrowserFile, b)
+}
+
+// LoadFavorites reads the starred accounts and roles
+func LoadFavorites() (*Favorites, error) {
+	f := &Favorites{}
+	if err := readJSON(FavoritesFile, f); err != nil {
+		return &Favorites{}, err
+	}
+	return f, nil
+}
+
+// Save writes the favorites to the state directory
+func (f *Favorites) Save() error {
+	return writeJSON(FavoritesFile, f)
+}
+
+// Add stars an account or role, returning false if it was already starred
+func (f *Favorites) Add(fav Favorite) bool {
+	for _, existing := range f.Items {
+		if existing == fav {
+			return false
+		}
+	}
+	f.Items = append(f.Items, fav)
+	return true
+}
+
+// Remove unstars an account or role, returning false if it was not starred
+func (f *Favorites) Remove(fav Favorite) bool {
+	for i, existing := range f.Items {
+		if existing == fav {
+			f.Items = append(f.Items[:i], f.Items[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Clear removes all favorites for a profile, or every favorite if profile is empty
+func (f *Favorites) Clear(profile string) {
+	if profile == "" {
+		f.Items = nil
+		return
+	}
+
+	kept := f.Items[:0]
+	for _, fav := range f.Items {
+		if fav.Profile != profile {
+			kept = append(kept, fav)
+		}
+	}
+	f.Items = kept
+}
+
+// IsAccountFavorite reports whether any favorite points at the account
+func (f *Favorites) IsAccountFavorite(profile, accountId string) bool {
+	for _, fav := range f.Items {
+		if fav.Profile == profile && fav.AccountId == accountId {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRoleFavorite reports whether the specific role is starred
+func (f *Favorites) IsRoleFavorite(profile, accountId, roleName string) bool {
+	for _, fav := range f.Items {
+		if fav.Profile == profile && fav.AccountId == accountId && fav.RoleName == roleName {
+			return true
+		}
+	}
+	return false
+}
+
+// orderKeys splits keys into favorites, the top recent keys and the rest.
+// The rest keeps frecency order, followed by never-used keys in their original order.
+func orderKeys(keys []string, isFavorite func(string) bool, scores map[string]float64) (favorites, recent, rest []string) {
+	present := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		present[k] = true
+	}
+
+	placed := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if isFavorite(k) {
+			favorites = append(favorites, k)
+			placed[k] = true
+		}
+	}
+
+	for _, k := range RankByScore(scores) {
+		if !present[k] || placed[k] {
+			continue
+		}
+		if len(recent) < MaxRecent {
+			recent = append(recent, k)
+		} else {
+			rest = append(rest, k)
+		}
+		placed[k] = true
+	}
+
+	for _, k := range keys {
+		if !placed[k] {
+			rest = append(rest, k)
+		}
+	}
+	return favorites, recent, rest
+}
+
+// Sections splits the items of a picker into starred items, the most
+// recently used ones and the rest. Favorites and Recent are empty until
+// something has been starred or used.
+type Sections[T any] struct {
+	Favorites []T
+	Recent    []T
+	Rest      []T
+}
+
+// newSections looks up the ordered keys returned by orderKeys
+func newSections[T any](byKey map[string]T, favorites, recent, rest []string) Sections[T] {
+	lookup := func(keys []string) []T {
+		result := make([]T, len(keys))
+		for i, k := range keys {
+			result[i] = byKey[k]
+		}
+		return result
+	}
+	return Sections[T]{Favorites: lookup(favorites), Recent: lookup(recent), Rest: lookup(rest)}
+}
+
+// AccountSections orders accounts for the picker: starred accounts are
+// pinned first, followed by the recent ones and then the remaining accounts.
+func AccountSections(profile string, accounts []sso.Account, h *History, f *Favorites) Sections[sso.Account] {
+	byId := make(map[string]sso.Account, len(accounts))
+	ids := make([]string, len(accounts))
+	for i, acc := range accounts {
+		byId[acc.AccountId] = acc
+		ids[i] = acc.AccountId
+	}
+
+	favorites, recent, rest := orderKeys(ids, func(id string) bool {
+		return f.IsAccountFavorite(profile, id)
+	}, h.AccountScores(profile))
+	return newSections(byId, favorites, recent, rest)
+}
+
+// RoleSections orders the roles of an account the same way as AccountSections
+func RoleSections(profile, accountId string, roles []sso.Role, h *History, f *Favorites) Sections[sso.Role] {
+	byName := make(map[string]sso.Role, len(roles))
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		byName[role.RoleName] = role
+		names[i] = role.RoleName
+	}
+
+	favorites, recent, rest := orderKeys(names, func(name string) bool {
+		return f.IsRoleFavorite(profile, accountId, name)
+	}, h.RoleScores(profile, accountId))
+	return newSections(byName, favorites, recent, rest)
+}
+
+// AccountRoleSections orders account/role pairs for the flat picker the same way as AccountSections
+func AccountRoleSections(profile string, pairs []sso.AccountRole, h *History, f *Favorites) Sections[sso.AccountRole] {
+	byKey := make(map[string]sso.AccountRole, len(pairs))
+	keys := make([]string, len(pairs))
+	for i, p := range pairs {
+		keys[i] = PairKey(p.Account.AccountId, p.Role.RoleName)
+		byKey[keys[i]] = p
+	}
+
+	favorites, recent, rest := orderKeys(keys, func(key string) bool {
+		p := byKey[key]
+		return f.IsRoleFavorite(profile, p.Account.AccountId, p.Role.RoleName) ||
+			f.IsRoleFavorite(profile, p.Account.AccountId, "")
+	}, h.PairScores(profile))
+	return newSections(byKey, favorites, recent, rest)
+}
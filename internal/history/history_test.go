@@ -0,0 +1,308 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ysaakpr/aws-term/internal/config"
+	"github.com/ysaakpr/aws-term/internal/sso"
+)
+
+func TestLoadMovesCorruptFileAside(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	stateDir, err := config.GetStateDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(stateDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(stateDir, HistoryFile)
+	corrupt := []byte(`{"entries": [`)
+	if err := os.WriteFile(path, corrupt, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := Load()
+	if err == nil {
+		t.Fatal("Load() succeeded on a corrupt file")
+	}
+	if len(h.Entries) != 0 {
+		t.Errorf("Load() = %d entries, want none", len(h.Entries))
+	}
+
+	// The corrupt file is kept, and saving does not overwrite it
+	h.Record(Entry{Profile: "work", AccountId: "111111111111", RoleName: "Admin"})
+	if err := h.Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path + CorruptSuffix)
+	if err != nil {
+		t.Fatalf("corrupt file was not moved aside: %v", err)
+	}
+	if string(data) != string(corrupt) {
+		t.Errorf("moved file = %q, want %q", data, corrupt)
+	}
+
+	h, err = Load()
+	if err != nil {
+		t.Fatalf("Load() after save: %v", err)
+	}
+	if len(h.Entries) != 1 {
+		t.Errorf("Load() = %d entries, want 1", len(h.Entries))
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	h, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(h.Entries) != 0 {
+		t.Errorf("Load() = %d entries, want none", len(h.Entries))
+	}
+}
+
+// testNow is the fixed time the ordering tests score against
+var testNow = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// fixClock makes the package score against testNow
+func fixClock(t *testing.T) {
+	t.Helper()
+	saved := clock
+	clock = func() time.Time { return testNow }
+	t.Cleanup(func() { clock = saved })
+}
+
+// daysAgo returns the time d days before testNow
+func daysAgo(d int) time.Time {
+	return testNow.Add(-time.Duration(d) * 24 * time.Hour)
+}
+
+// accountIds returns the IDs of accounts, for comparing sections
+func accountIds(accounts []sso.Account) []string {
+	ids := make([]string, len(accounts))
+	for i, acc := range accounts {
+		ids[i] = acc.AccountId
+	}
+	return ids
+}
+
+func TestWeight(t *testing.T) {
+	tests := []struct {
+		days int
+		want float64
+	}{
+		{days: 0, want: 100},
+		{days: 3, want: 100},
+		{days: 4, want: 70},
+		{days: 13, want: 70},
+		{days: 14, want: 50},
+		{days: 30, want: 50},
+		{days: 31, want: 30},
+		{days: 89, want: 30},
+		{days: 90, want: 10},
+		{days: 400, want: 10},
+	}
+
+	for _, tt := range tests {
+		if got := weight(daysAgo(tt.days), testNow); got != tt.want {
+			t.Errorf("weight(%d days ago) = %v, want %v", tt.days, got, tt.want)
+		}
+	}
+}
+
+func TestAccountSections(t *testing.T) {
+	fixClock(t)
+
+	accounts := []sso.Account{
+		{AccountId: "555555555555"},
+		{AccountId: "444444444444"},
+		{AccountId: "333333333333"},
+		{AccountId: "222222222222"},
+		{AccountId: "111111111111"},
+	}
+	h := &History{Entries: []Entry{
+		// Two old selections score less than one from yesterday
+		{Profile: "work", AccountId: "222222222222", Timestamp: daysAgo(100)},
+		{Profile: "work", AccountId: "222222222222", Timestamp: daysAgo(95)},
+		{Profile: "work", AccountId: "333333333333", Timestamp: daysAgo(20)},
+		{Profile: "work", AccountId: "111111111111", Timestamp: daysAgo(1)},
+		// Favorites are listed once, however often they are used
+		{Profile: "work", AccountId: "444444444444", Timestamp: daysAgo(1)},
+		// Other profiles and unknown accounts do not count
+		{Profile: "home", AccountId: "555555555555", Timestamp: daysAgo(1)},
+		{Profile: "work", AccountId: "999999999999", Timestamp: daysAgo(1)},
+	}}
+	f := &Favorites{Items: []Favorite{
+		{Profile: "work", AccountId: "444444444444"},
+		{Profile: "home", AccountId: "333333333333"},
+	}}
+
+	got := AccountSections("work", accounts, h, f)
+	want := [][]string{
+		{"444444444444"},
+		{"111111111111", "333333333333", "222222222222"},
+		{"555555555555"},
+	}
+	if sections := [][]string{accountIds(got.Favorites), accountIds(got.Recent), accountIds(got.Rest)}; !reflect.DeepEqual(sections, want) {
+		t.Errorf("AccountSections() = %v, want %v", sections, want)
+	}
+
+	// Without history or favorites the accounts keep their order
+	got = AccountSections("work", accounts, &History{}, &Favorites{})
+	if len(got.Favorites) != 0 || len(got.Recent) != 0 || !reflect.DeepEqual(got.Rest, accounts) {
+		t.Errorf("AccountSections() with no history = %+v, want every account in Rest", got)
+	}
+}
+
+func TestAccountSectionsCapsRecent(t *testing.T) {
+	fixClock(t)
+
+	var accounts []sso.Account
+	h := &History{}
+	for i := 1; i <= MaxRecent+3; i++ {
+		id := fmt.Sprintf("%012d", i)
+		accounts = append(accounts, sso.Account{AccountId: id})
+		// Every account but the last is used, older ones less often
+		if i <= MaxRecent+2 {
+			h.Record(Entry{Profile: "work", AccountId: id, Timestamp: daysAgo(i * 5)})
+		}
+	}
+
+	got := AccountSections("work", accounts, h, &Favorites{})
+	if len(got.Recent) != MaxRecent {
+		t.Fatalf("Recent = %v, want %d accounts", accountIds(got.Recent), MaxRecent)
+	}
+	want := []string{"000000000001", "000000000002", "000000000003", "000000000004", "000000000005"}
+	if ids := accountIds(got.Recent); !reflect.DeepEqual(ids, want) {
+		t.Errorf("Recent = %v, want %v", ids, want)
+	}
+	// Used accounts beyond MaxRecent come first in Rest, in frecency order
+	want = []string{"000000000006", "000000000007", "000000000008"}
+	if ids := accountIds(got.Rest); !reflect.DeepEqual(ids, want) {
+		t.Errorf("Rest = %v, want %v", ids, want)
+	}
+}
+
+func TestAccountSectionsTies(t *testing.T) {
+	fixClock(t)
+
+	accounts := []sso.Account{{AccountId: "222222222222"}, {AccountId: "111111111111"}}
+	h := &History{Entries: []Entry{
+		{Profile: "work", AccountId: "222222222222", Timestamp: daysAgo(1)},
+		{Profile: "work", AccountId: "111111111111", Timestamp: daysAgo(2)},
+	}}
+
+	// Equal scores are ordered by account ID
+	got := AccountSections("work", accounts, h, &Favorites{})
+	want := []string{"111111111111", "222222222222"}
+	if ids := accountIds(got.Recent); !reflect.DeepEqual(ids, want) {
+		t.Errorf("Recent = %v, want %v", ids, want)
+	}
+}
+
+func TestRoleSections(t *testing.T) {
+	fixClock(t)
+
+	roles := []sso.Role{{RoleName: "Admin"}, {RoleName: "Billing"}, {RoleName: "ReadOnly"}, {RoleName: "Support"}}
+	h := &History{Entries: []Entry{
+		{Profile: "work", AccountId: "111111111111", RoleName: "ReadOnly", Timestamp: daysAgo(1)},
+		{Profile: "work", AccountId: "111111111111", RoleName: "Admin", Timestamp: daysAgo(40)},
+		// Roles used with another account do not count
+		{Profile: "work", AccountId: "222222222222", RoleName: "Support", Timestamp: daysAgo(1)},
+	}}
+	f := &Favorites{Items: []Favorite{
+		{Profile: "work", AccountId: "111111111111", RoleName: "Billing"},
+		{Profile: "work", AccountId: "222222222222", RoleName: "Support"},
+		// An account favorite does not pin its roles in the role picker
+		{Profile: "work", AccountId: "111111111111"},
+	}}
+
+	got := RoleSections("work", "111111111111", roles, h, f)
+	want := Sections[sso.Role]{
+		Favorites: []sso.Role{{RoleName: "Billing"}},
+		Recent:    []sso.Role{{RoleName: "ReadOnly"}, {RoleName: "Admin"}},
+		Rest:      []sso.Role{{RoleName: "Support"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RoleSections() = %+v, want %+v", got, want)
+	}
+}
+
+func TestAccountRoleSections(t *testing.T) {
+	fixClock(t)
+
+	pair := func(id, role string) sso.AccountRole {
+		return sso.AccountRole{Account: sso.Account{AccountId: id}, Role: sso.Role{RoleName: role, AccountId: id}}
+	}
+	pairs := []sso.AccountRole{
+		pair("111111111111", "Admin"),
+		pair("111111111111", "ReadOnly"),
+		pair("222222222222", "Admin"),
+		pair("222222222222", "ReadOnly"),
+		pair("333333333333", "Admin"),
+	}
+	h := &History{Entries: []Entry{
+		{Profile: "work", AccountId: "222222222222", RoleName: "ReadOnly", Timestamp: daysAgo(10)},
+		{Profile: "work", AccountId: "333333333333", RoleName: "Admin", Timestamp: daysAgo(1)},
+	}}
+	f := &Favorites{Items: []Favorite{
+		// An account favorite pins every role of the account
+		{Profile: "work", AccountId: "111111111111"},
+		{Profile: "work", AccountId: "222222222222", RoleName: "Admin"},
+	}}
+
+	got := AccountRoleSections("work", pairs, h, f)
+	want := Sections[sso.AccountRole]{
+		Favorites: []sso.AccountRole{pairs[0], pairs[1], pairs[2]},
+		Recent:    []sso.AccountRole{pairs[4], pairs[3]},
+		Rest:      []sso.AccountRole{},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AccountRoleSections() = %+v, want %+v", got, want)
+	}
+}
+
+func TestHistoryClear(t *testing.T) {
+	entries := []Entry{
+		{Profile: "work", AccountId: "111111111111"},
+		{Profile: "home", AccountId: "222222222222"},
+		{Profile: "work", AccountId: "333333333333"},
+	}
+
+	h := &History{Entries: append([]Entry(nil), entries...)}
+	h.Clear("work")
+	if want := []Entry{entries[1]}; !reflect.DeepEqual(h.Entries, want) {
+		t.Errorf("Clear(work) left %+v, want %+v", h.Entries, want)
+	}
+
+	h.Clear("")
+	if len(h.Entries) != 0 {
+		t.Errorf("Clear() left %d entries, want none", len(h.Entries))
+	}
+}
+
+func TestFavoritesClear(t *testing.T) {
+	items := []Favorite{
+		{Profile: "work", AccountId: "111111111111"},
+		{Profile: "home", AccountId: "222222222222", RoleName: "Admin"},
+		{Profile: "work", AccountId: "333333333333", RoleName: "ReadOnly"},
+	}
+
+	f := &Favorites{Items: append([]Favorite(nil), items...)}
+	f.Clear("work")
+	if want := []Favorite{items[1]}; !reflect.DeepEqual(f.Items, want) {
+		t.Errorf("Clear(work) left %+v, want %+v", f.Items, want)
+	}
+
+	f.Clear("")
+	if len(f.Items) != 0 {
+		t.Errorf("Clear() left %d favorites, want none", len(f.Items))
+	}
+}
@@ -0,0 +1,62 @@
+package history
+
+import "time"
+
+// SessionsFile records the credentials aws-term has handed out, so "aws-term
+// status" can describe the credentials in a shell's environment
+const SessionsFile = "sessions.json"
+
+// Session describes credentials handed out by aws-term. They are found by
+// their access key ID; the secret is not stored.
+type Session struct {
+	AccessKeyId    string    `json:"access_key_id"`
+	Profile        string    `json:"profile"`
+	Region         string    `json:"region"`
+	AccountId      string    `json:"account_id"`
+	AccountName    string    `json:"account_name"`
+	RoleName       string    `json:"role_name"`
+	Target         string    `json:"target,omitempty"`
+	AssumedRoleArn string    `json:"assumed_role_arn,omitempty"`
+	Expiration     time.Time `json:"expiration"`
+}
+
+// Sessions holds the sessions that have not expired yet
+type Sessions struct {
+	Items []Session `json:"sessions"`
+}
+
+// LoadSessions reads the recorded sessions
+func LoadSessions() (*Sessions, error) {
+	s := &Sessions{}
+	if err := readJSON(SessionsFile, s); err != nil {
+		return &Sessions{}, err
+	}
+	return s, nil
+}
+
+// Save writes the sessions to the state directory
+func (s *Sessions) Save() error {
+	return writeJSON(SessionsFile, s)
+}
+
+// Record adds a session and forgets the ones that have expired
+func (s *Sessions) Record(session Session) {
+	now := clock()
+	kept := s.Items[:0]
+	for _, existing := range s.Items {
+		if existing.Expiration.After(now) && existing.AccessKeyId != session.AccessKeyId {
+			kept = append(kept, existing)
+		}
+	}
+	s.Items = append(kept, session)
+}
+
+// Lookup returns the session with the given access key ID
+func (s *Sessions) Lookup(accessKeyId string) (*Session, bool) {
+	for i := range s.Items {
+		if s.Items[i].AccessKeyId == accessKeyId {
+			return &s.Items[i], true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,38 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSessionsRecord(t *testing.T) {
+	fixClock(t)
+
+	live := Session{AccessKeyId: "ASIALIVE", Profile: "work", Expiration: testNow.Add(time.Hour)}
+	expired := Session{AccessKeyId: "ASIAEXPIRED", Profile: "work", Expiration: testNow.Add(-time.Minute)}
+	endsNow := Session{AccessKeyId: "ASIANOW", Profile: "work", Expiration: testNow}
+	s := &Sessions{Items: []Session{expired, live, endsNow}}
+
+	// Expired sessions are forgotten
+	renewed := Session{AccessKeyId: "ASIARENEWED", Profile: "work", RoleName: "Admin", Expiration: testNow.Add(time.Hour)}
+	s.Record(renewed)
+	if want := []Session{live, renewed}; !reflect.DeepEqual(s.Items, want) {
+		t.Errorf("Record() kept %+v, want %+v", s.Items, want)
+	}
+
+	// Recording the same access key again replaces the earlier session
+	renewed.RoleName = "ReadOnly"
+	s.Record(renewed)
+	if want := []Session{live, renewed}; !reflect.DeepEqual(s.Items, want) {
+		t.Errorf("Record() kept %+v, want %+v", s.Items, want)
+	}
+
+	found, ok := s.Lookup("ASIARENEWED")
+	if !ok || found.RoleName != "ReadOnly" {
+		t.Errorf("Lookup() = %+v, %v, want the latest session", found, ok)
+	}
+	if _, ok := s.Lookup("ASIAEXPIRED"); ok {
+		t.Error("Lookup() found a forgotten session")
+	}
+}
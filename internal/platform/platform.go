@@ -0,0 +1,26 @@
+// Package platform recognizes the systems aws-term has to treat specially,
+// for packages that detect tools and browsers through an injectable Env.
+package platform
+
+import "strings"
+
+// Env is the view of the operating system the checks use. The Env types of
+// the browser and clipboard packages satisfy it.
+type Env interface {
+	Getenv(key string) string
+	ReadFile(path string) ([]byte, error)
+}
+
+// IsWSL reports whether env is a Windows Subsystem for Linux distribution
+func IsWSL(env Env) bool {
+	if env.Getenv("WSL_DISTRO_NAME") != "" {
+		return true
+	}
+
+	version, err := env.ReadFile("/proc/version")
+	if err != nil {
+		return false
+	}
+	v := strings.ToLower(string(version))
+	return strings.Contains(v, "microsoft") || strings.Contains(v, "wsl")
+}
@@ -0,0 +1,45 @@
+package platform
+
+import (
+	"os"
+	"testing"
+)
+
+// staticEnv has fixed variables and an optional /proc/version
+type staticEnv struct {
+	vars        map[string]string
+	procVersion string
+}
+
+func (e staticEnv) Getenv(key string) string {
+	return e.vars[key]
+}
+
+func (e staticEnv) ReadFile(path string) ([]byte, error) {
+	if path != "/proc/version" || e.procVersion == "" {
+		return nil, os.ErrNotExist
+	}
+	return []byte(e.procVersion), nil
+}
+
+func TestIsWSL(t *testing.T) {
+	tests := []struct {
+		name string
+		env  staticEnv
+		want bool
+	}{
+		{name: "distro variable", env: staticEnv{vars: map[string]string{"WSL_DISTRO_NAME": "Ubuntu"}}, want: true},
+		{name: "WSL 2 kernel", env: staticEnv{procVersion: "Linux version 5.15.90.1-microsoft-standard-WSL2"}, want: true},
+		{name: "WSL 1 kernel", env: staticEnv{procVersion: "Linux version 4.4.0-19041-Microsoft"}, want: true},
+		{name: "linux", env: staticEnv{procVersion: "Linux version 6.8.0-45-generic"}},
+		{name: "no /proc", env: staticEnv{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsWSL(tt.env); got != tt.want {
+				t.Errorf("IsWSL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package rolechain
+
+import (
+	"context"
+	"fmt"
+	"os/user"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/ysaakpr/aws-term/internal/config"
+	"github.com/ysaakpr/aws-term/internal/sso"
+)
+
+// DefaultSessionName is the session name template used when a hop has none
+const DefaultSessionName = "aws-term-{user}"
+
+// invalidSessionChars matches characters STS does not accept in a role session name
+var invalidSessionChars = regexp.MustCompile(`[^\w+=,.@-]`)
+
+// Options configure how a role chain is assumed
+type Options struct {
+	Region string
+
+	// Endpoint overrides the STS endpoint, e.g. for a local stub
+	Endpoint string
+
+	// Vars fill the placeholders in session name templates
+	Vars map[string]string
+
+	// MFACode returns the current code of an MFA device, by serial number
+	MFACode func(serial string) (string, error)
+}
+
+// Result is the outcome of a role chain
+type Result struct {
+	Credentials *sso.Credentials
+
+	// AssumedRoleArn is the ARN of the session from the last hop
+	AssumedRoleArn string
+}
+
+// Assume assumes each hop in turn, starting from the SSO role's credentials
+// and using every hop's credentials for the next
+func Assume(ctx context.Context, creds *sso.Credentials, hops []config.RoleHop, opts Options) (*Result, error) {
+	result := &Result{Credentials: creds}
+
+	for i, hop := range hops {
+		if hop.RoleArn == "" {
+			return nil, fmt.Errorf("role chain hop %d has no role_arn", i+1)
+		}
+
+		input := &sts.AssumeRoleInput{
+			RoleArn:         aws.String(hop.RoleArn),
+			RoleSessionName: aws.String(SessionName(hop.SessionName, opts.Vars)),
+		}
+		if hop.ExternalId != "" {
+			input.ExternalId = aws.String(hop.ExternalId)
+		}
+		if hop.DurationSeconds > 0 {
+			input.DurationSeconds = aws.Int32(hop.DurationSeconds)
+		}
+		if hop.MFASerial != "" {
+			if opts.MFACode == nil {
+				return nil, fmt.Errorf("role %s requires an MFA code", hop.RoleArn)
+			}
+			code, err := opts.MFACode(hop.MFASerial)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read MFA code: %w", err)
+			}
+			input.SerialNumber = aws.String(hop.MFASerial)
+			input.TokenCode = aws.String(strings.TrimSpace(code))
+		}
+
+		output, err := newClient(result.Credentials, opts).AssumeRole(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to assume role %s: %w", hop.RoleArn, err)
+		}
+
+		result.Credentials = &sso.Credentials{
+			AccessKeyId:     aws.ToString(output.Credentials.AccessKeyId),
+			SecretAccessKey: aws.ToString(output.Credentials.SecretAccessKey),
+			SessionToken:    aws.ToString(output.Credentials.SessionToken),
+			Expiration:      aws.ToTime(output.Credentials.Expiration),
+		}
+		if output.AssumedRoleUser != nil {
+			result.AssumedRoleArn = aws.ToString(output.AssumedRoleUser.Arn)
+		}
+	}
+
+	return result, nil
+}
+
+// Identity is who STS says a set of credentials belongs to
+type Identity struct {
+	AccountId string
+	Arn       string
+	UserId    string
+}
+
+// CallerIdentity asks STS who creds belong to. Only opts.Region and
+// opts.Endpoint are used.
+func CallerIdentity(ctx context.Context, creds *sso.Credentials, opts Options) (*Identity, error) {
+	output, err := newClient(creds, opts).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %w", err)
+	}
+	return &Identity{
+		AccountId: aws.ToString(output.Account),
+		Arn:       aws.ToString(output.Arn),
+		UserId:    aws.ToString(output.UserId),
+	}, nil
+}
+
+// newClient creates an STS client that signs with creds
+func newClient(creds *sso.Credentials, opts Options) *sts.Client {
+	stsOpts := sts.Options{
+		Region:      opts.Region,
+		Credentials: creds.Provider(),
+	}
+	if opts.Endpoint != "" {
+		stsOpts.BaseEndpoint = aws.String(opts.Endpoint)
+	}
+	return sts.New(stsOpts)
+}
+
+// SessionName expands a session name template and makes it valid for STS:
+// unsupported characters become '-' and the name is cut to 64 characters
+func SessionName(template string, vars map[string]string) string {
+	if template == "" {
+		template = DefaultSessionName
+	}
+
+	name := template
+	if strings.Contains(name, "{user}") {
+		username := "unknown"
+		if u, err := user.Current(); err == nil && u.Username != "" {
+			username = u.Username
+		}
+		name = strings.ReplaceAll(name, "{user}", username)
+	}
+	for key, value := range vars {
+		name = strings.ReplaceAll(name, "{"+key+"}", value)
+	}
+
+	name = invalidSessionChars.ReplaceAllString(name, "-")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	if len(name) < 2 {
+		name = "aws-term"
+	}
+	return name
+}
@@ -0,0 +1,185 @@
+package rolechain_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ysaakpr/aws-term/internal/config"
+	"github.com/ysaakpr/aws-term/internal/rolechain"
+	"github.com/ysaakpr/aws-term/internal/rolechain/ststest"
+	"github.com/ysaakpr/aws-term/internal/sso"
+)
+
+var ssoCreds = &sso.Credentials{
+	AccessKeyId:     "ASIASSOROLE",
+	SecretAccessKey: "secret",
+	SessionToken:    "token",
+	Expiration:      time.Now().Add(time.Hour),
+}
+
+func newTestServer(t *testing.T) (*ststest.Server, rolechain.Options) {
+	t.Helper()
+	server := ststest.NewServer()
+	t.Cleanup(server.Close)
+	return server, rolechain.Options{
+		Region:   "us-east-1",
+		Endpoint: server.URL,
+		Vars:     map[string]string{"profile": "work", "target": "data admin", "account_id": "111111111111", "role": "Admin"},
+	}
+}
+
+func TestAssumeChain(t *testing.T) {
+	server, opts := newTestServer(t)
+	hops := []config.RoleHop{
+		{RoleArn: "arn:aws:iam::222222222222:role/Shared", SessionName: "{profile}/{target}", ExternalId: "aws-term"},
+		{RoleArn: "arn:aws:iam::333333333333:role/DataAdmin", SessionName: "{role}@{account_id}", DurationSeconds: 900},
+	}
+
+	start := time.Now()
+	result, err := rolechain.Assume(context.Background(), ssoCreds, hops, opts)
+	if err != nil {
+		t.Fatalf("Assume() error = %v", err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("STS received %d requests, want 2", len(requests))
+	}
+
+	// Each hop is signed with the credentials of the one before
+	first, second := requests[0], requests[1]
+	if first.AccessKeyId != ssoCreds.AccessKeyId {
+		t.Errorf("hop 1 signed with %s, want the SSO role's key", first.AccessKeyId)
+	}
+	if second.AccessKeyId != ststest.AccessKeyId(1) {
+		t.Errorf("hop 2 signed with %s, want hop 1's key %s", second.AccessKeyId, ststest.AccessKeyId(1))
+	}
+
+	if got := first.Params.Get("ExternalId"); got != "aws-term" {
+		t.Errorf("hop 1 ExternalId = %q, want aws-term", got)
+	}
+	if got := second.Params.Get("ExternalId"); got != "" {
+		t.Errorf("hop 2 ExternalId = %q, want none", got)
+	}
+	if got := first.Params.Get("RoleSessionName"); got != "work-data-admin" {
+		t.Errorf("hop 1 RoleSessionName = %q, want work-data-admin", got)
+	}
+	if got := second.Params.Get("RoleSessionName"); got != "Admin@111111111111" {
+		t.Errorf("hop 2 RoleSessionName = %q, want Admin@111111111111", got)
+	}
+	if got := first.Params.Get("DurationSeconds"); got != "" {
+		t.Errorf("hop 1 DurationSeconds = %q, want none", got)
+	}
+	if got := second.Params.Get("DurationSeconds"); got != "900" {
+		t.Errorf("hop 2 DurationSeconds = %q, want 900", got)
+	}
+
+	// The result holds the last hop's credentials and session
+	if result.Credentials.AccessKeyId != ststest.AccessKeyId(2) {
+		t.Errorf("AccessKeyId = %s, want %s", result.Credentials.AccessKeyId, ststest.AccessKeyId(2))
+	}
+	if result.Credentials.SessionToken != "token-"+ststest.AccessKeyId(2) {
+		t.Errorf("SessionToken = %s, want the last hop's token", result.Credentials.SessionToken)
+	}
+	wantArn := ststest.AssumedRoleArn(hops[1].RoleArn, "Admin@111111111111")
+	if result.AssumedRoleArn != wantArn {
+		t.Errorf("AssumedRoleArn = %s, want %s", result.AssumedRoleArn, wantArn)
+	}
+	if lifetime := result.Credentials.Expiration.Sub(start); lifetime < 14*time.Minute || lifetime > 16*time.Minute {
+		t.Errorf("credentials last %s, want 15m", lifetime)
+	}
+}
+
+func TestAssumeMFA(t *testing.T) {
+	server, opts := newTestServer(t)
+	hops := []config.RoleHop{{RoleArn: "arn:aws:iam::222222222222:role/Admin", MFASerial: "arn:aws:iam::111111111111:mfa/alice"}}
+
+	var asked string
+	opts.MFACode = func(serial string) (string, error) {
+		asked = serial
+		return " 123456\n", nil
+	}
+	if _, err := rolechain.Assume(context.Background(), ssoCreds, hops, opts); err != nil {
+		t.Fatalf("Assume() error = %v", err)
+	}
+
+	if asked != hops[0].MFASerial {
+		t.Errorf("MFA code asked for %q, want %q", asked, hops[0].MFASerial)
+	}
+	params := server.Requests()[0].Params
+	if params.Get("SerialNumber") != hops[0].MFASerial || params.Get("TokenCode") != "123456" {
+		t.Errorf("SerialNumber, TokenCode = %q, %q", params.Get("SerialNumber"), params.Get("TokenCode"))
+	}
+
+	opts.MFACode = nil
+	if _, err := rolechain.Assume(context.Background(), ssoCreds, hops, opts); err == nil {
+		t.Error("Assume() without an MFA prompt succeeded")
+	}
+}
+
+func TestAssumeDenied(t *testing.T) {
+	server, opts := newTestServer(t)
+	hops := []config.RoleHop{
+		{RoleArn: "arn:aws:iam::222222222222:role/Shared"},
+		{RoleArn: "arn:aws:iam::333333333333:role/DataAdmin"},
+	}
+	server.DeniedRoles[hops[1].RoleArn] = true
+
+	_, err := rolechain.Assume(context.Background(), ssoCreds, hops, opts)
+	if err == nil {
+		t.Fatal("Assume() succeeded for a denied role")
+	}
+	if !strings.Contains(err.Error(), hops[1].RoleArn) || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("Assume() error = %v, want AccessDenied for %s", err, hops[1].RoleArn)
+	}
+
+	if _, err := rolechain.Assume(context.Background(), ssoCreds, []config.RoleHop{{}}, opts); err == nil {
+		t.Error("Assume() accepted a hop without role_arn")
+	}
+}
+
+func TestCallerIdentity(t *testing.T) {
+	server, opts := newTestServer(t)
+
+	identity, err := rolechain.CallerIdentity(context.Background(), ssoCreds, opts)
+	if err != nil {
+		t.Fatalf("CallerIdentity() error = %v", err)
+	}
+	if identity.Arn != server.CallerArn || identity.AccountId != "111111111111" {
+		t.Errorf("CallerIdentity() = %+v, want %s in 111111111111", identity, server.CallerArn)
+	}
+	if got := server.Requests()[0].AccessKeyId; got != ssoCreds.AccessKeyId {
+		t.Errorf("request signed with %s, want %s", got, ssoCreds.AccessKeyId)
+	}
+}
+
+func TestSessionName(t *testing.T) {
+	vars := map[string]string{"profile": "work", "target": "data admin", "role": "Admin"}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{name: "placeholders", template: "{profile}-{role}", want: "work-Admin"},
+		{name: "invalid characters", template: "{target}/{role}!", want: "data-admin-Admin-"},
+		{name: "allowed punctuation", template: "a+b=c,d.e@f_g-h", want: "a+b=c,d.e@f_g-h"},
+		{name: "unknown placeholder", template: "{nope}", want: "-nope-"},
+		{name: "too short", template: "x", want: "aws-term"},
+		{name: "too long", template: strings.Repeat("a", 70), want: strings.Repeat("a", 64)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rolechain.SessionName(tt.template, vars); got != tt.want {
+				t.Errorf("SessionName(%q) = %q, want %q", tt.template, got, tt.want)
+			}
+		})
+	}
+
+	if got := rolechain.SessionName("", vars); !strings.HasPrefix(got, "aws-term-") {
+		t.Errorf("SessionName(\"\") = %q, want the aws-term-{user} default", got)
+	}
+}
@@ -0,0 +1,220 @@
+// Package ststest provides a fake STS API for exercising role chains and
+// caller identity lookups offline.
+package ststest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Action names, as recorded in Request.Action
+const (
+	ActionAssumeRole        = "AssumeRole"
+	ActionGetCallerIdentity = "GetCallerIdentity"
+)
+
+// DefaultDuration is the lifetime of credentials issued without DurationSeconds
+const DefaultDuration = time.Hour
+
+// credentialPattern finds the access key ID in a SigV4 Authorization header
+// or X-Amz-Credential query parameter
+var credentialPattern = regexp.MustCompile(`^(?:.*Credential=)?([^/]+)/`)
+
+// Request is a call received by the fake
+type Request struct {
+	Action string
+
+	// AccessKeyId is the key the request was signed with
+	AccessKeyId string
+
+	// Params are the query protocol parameters, e.g. RoleArn
+	Params url.Values
+
+	// Header holds the request headers; for presigned requests the signed
+	// headers arrive here too
+	Header http.Header
+}
+
+// Server is a fake STS API, passed to clients as their base endpoint
+type Server struct {
+	*httptest.Server
+
+	// CallerArn is returned by GetCallerIdentity for credentials the fake did not issue
+	CallerArn string
+
+	// DeniedRoles are refused with AccessDenied
+	DeniedRoles map[string]bool
+
+	mu       sync.Mutex
+	requests []Request
+	issued   map[string]string // access key ID -> assumed role ARN
+}
+
+// NewServer starts a fake. Close it when done.
+func NewServer() *Server {
+	s := &Server{
+		CallerArn:   "arn:aws:sts::111111111111:assumed-role/AWSReservedSSO_Admin_0123456789abcdef/alice",
+		DeniedRoles: map[string]bool{},
+		issued:      map[string]string{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Requests returns the calls received so far, in order
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// AccessKeyId returns the access key ID the fake issues for the nth
+// AssumeRole call, counting from 1
+func AccessKeyId(n int) string {
+	return fmt.Sprintf("ASIASTUB%08d", n)
+}
+
+// AssumedRoleArn returns the session ARN for a role ARN and session name
+func AssumedRoleArn(roleArn, sessionName string) string {
+	arn := strings.Replace(roleArn, ":iam::", ":sts::", 1)
+	arn = strings.Replace(arn, ":role/", ":assumed-role/", 1)
+	return arn + "/" + sessionName
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidParameterValue", err.Error())
+		return
+	}
+
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		auth = r.Form.Get("X-Amz-Credential")
+	}
+	accessKeyId := ""
+	if m := credentialPattern.FindStringSubmatch(auth); m != nil {
+		accessKeyId = m[1]
+	}
+	if accessKeyId == "" {
+		writeError(w, http.StatusForbidden, "MissingAuthenticationToken", "request is not signed")
+		return
+	}
+
+	req := Request{Action: r.Form.Get("Action"), AccessKeyId: accessKeyId, Params: r.Form, Header: r.Header.Clone()}
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	n := len(s.requests)
+	s.mu.Unlock()
+
+	switch req.Action {
+	case ActionAssumeRole:
+		s.assumeRole(w, req, n)
+	case ActionGetCallerIdentity:
+		s.getCallerIdentity(w, req)
+	default:
+		writeError(w, http.StatusBadRequest, "InvalidAction", "unsupported action "+req.Action)
+	}
+}
+
+func (s *Server) assumeRole(w http.ResponseWriter, req Request, n int) {
+	roleArn, sessionName := req.Params.Get("RoleArn"), req.Params.Get("RoleSessionName")
+	if roleArn == "" || sessionName == "" {
+		writeError(w, http.StatusBadRequest, "ValidationError", "RoleArn and RoleSessionName are required")
+		return
+	}
+	if s.DeniedRoles[roleArn] {
+		writeError(w, http.StatusForbidden, "AccessDenied", "not authorized to perform sts:AssumeRole on "+roleArn)
+		return
+	}
+
+	duration := DefaultDuration
+	if v := req.Params.Get("DurationSeconds"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "ValidationError", "invalid DurationSeconds")
+			return
+		}
+		duration = time.Duration(seconds) * time.Second
+	}
+
+	accessKeyId := AccessKeyId(n)
+	assumedArn := AssumedRoleArn(roleArn, sessionName)
+	s.mu.Lock()
+	s.issued[accessKeyId] = assumedArn
+	s.mu.Unlock()
+
+	writeXML(w, assumeRoleResponse{
+		Credentials: xmlCredentials{
+			AccessKeyId:     accessKeyId,
+			SecretAccessKey: "secret-" + accessKeyId,
+			SessionToken:    "token-" + accessKeyId,
+			Expiration:      time.Now().Add(duration).UTC().Format(time.RFC3339),
+		},
+		AssumedRoleArn: assumedArn,
+		AssumedRoleId:  "AROASTUB:" + sessionName,
+	})
+}
+
+func (s *Server) getCallerIdentity(w http.ResponseWriter, req Request) {
+	s.mu.Lock()
+	arn, ok := s.issued[req.AccessKeyId]
+	s.mu.Unlock()
+	if !ok {
+		arn = s.CallerArn
+	}
+
+	account := ""
+	if parts := strings.Split(arn, ":"); len(parts) > 4 {
+		account = parts[4]
+	}
+
+	writeXML(w, callerIdentityResponse{Arn: arn, UserId: "AROASTUB:" + req.AccessKeyId, Account: account})
+}
+
+func writeXML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "text/xml")
+	xml.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(errorResponse{Type: "Sender", Code: code, Message: message, RequestId: "stub"})
+}
+
+type xmlCredentials struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      string
+}
+
+type assumeRoleResponse struct {
+	XMLName        xml.Name       `xml:"AssumeRoleResponse"`
+	Credentials    xmlCredentials `xml:"AssumeRoleResult>Credentials"`
+	AssumedRoleArn string         `xml:"AssumeRoleResult>AssumedRoleUser>Arn"`
+	AssumedRoleId  string         `xml:"AssumeRoleResult>AssumedRoleUser>AssumedRoleId"`
+}
+
+type callerIdentityResponse struct {
+	XMLName xml.Name `xml:"GetCallerIdentityResponse"`
+	Arn     string   `xml:"GetCallerIdentityResult>Arn"`
+	UserId  string   `xml:"GetCallerIdentityResult>UserId"`
+	Account string   `xml:"GetCallerIdentityResult>Account"`
+}
+
+type errorResponse struct {
+	XMLName   xml.Name `xml:"ErrorResponse"`
+	Type      string   `xml:"Error>Type"`
+	Code      string   `xml:"Error>Code"`
+	Message   string   `xml:"Error>Message"`
+	RequestId string
+}
@@ -0,0 +1,125 @@
+package sso
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sso"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+)
+
+// OIDCAPI is the part of the IAM Identity Center OIDC API used to sign in.
+// *ssooidc.Client implements it.
+type OIDCAPI interface {
+	RegisterClient(ctx context.Context, params *ssooidc.RegisterClientInput, optFns ...func(*ssooidc.Options)) (*ssooidc.RegisterClientOutput, error)
+	StartDeviceAuthorization(ctx context.Context, params *ssooidc.StartDeviceAuthorizationInput, optFns ...func(*ssooidc.Options)) (*ssooidc.StartDeviceAuthorizationOutput, error)
+	CreateToken(ctx context.Context, params *ssooidc.CreateTokenInput, optFns ...func(*ssooidc.Options)) (*ssooidc.CreateTokenOutput, error)
+}
+
+// PortalAPI is the part of the AWS access portal API used to list accounts and
+// roles and get role credentials. *sso.Client implements it.
+type PortalAPI interface {
+	ListAccounts(ctx context.Context, params *sso.ListAccountsInput, optFns ...func(*sso.Options)) (*sso.ListAccountsOutput, error)
+	ListAccountRoles(ctx context.Context, params *sso.ListAccountRolesInput, optFns ...func(*sso.Options)) (*sso.ListAccountRolesOutput, error)
+	GetRoleCredentials(ctx context.Context, params *sso.GetRoleCredentialsInput, optFns ...func(*sso.Options)) (*sso.GetRoleCredentialsOutput, error)
+}
+
+// Clock tells the time and waits, so tests can run the login poll loop
+// without sleeping
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock is the Clock backed by package time
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time                         { return time.Now() }
+func (SystemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Services passed to an EndpointResolver
+const (
+	ServiceOIDC   = "oidc"
+	ServicePortal = "portal"
+)
+
+// EndpointResolver returns the base URL for a service in a region. An empty
+// URL uses the regional AWS endpoint.
+type EndpointResolver func(service, region string) string
+
+// Option configures an SSOClient
+type Option func(*clientOptions)
+
+type clientOptions struct {
+	endpointResolver EndpointResolver
+	httpClient       aws.HTTPClient
+	retryer          aws.Retryer
+	clock            Clock
+	oidc             OIDCAPI
+	portal           PortalAPI
+}
+
+// WithEndpoint sends OIDC and portal requests to endpoint instead of the
+// regional AWS endpoints, e.g. to a local fake
+func WithEndpoint(endpoint string) Option {
+	return WithEndpointResolver(func(string, string) string {
+		return endpoint
+	})
+}
+
+// WithEndpointResolver picks the OIDC and portal endpoints with resolver
+func WithEndpointResolver(resolver EndpointResolver) Option {
+	return func(o *clientOptions) {
+		o.endpointResolver = resolver
+	}
+}
+
+// WithHTTPClient sends OIDC and portal requests through client
+func WithHTTPClient(client aws.HTTPClient) Option {
+	return func(o *clientOptions) {
+		o.httpClient = client
+	}
+}
+
+// WithRetryer retries failed OIDC and portal requests with retryer instead of
+// the SDK's standard retryer
+func WithRetryer(retryer aws.Retryer) Option {
+	return func(o *clientOptions) {
+		o.retryer = retryer
+	}
+}
+
+// WithClock replaces the system clock used for polling, backoff and token expiry
+func WithClock(clock Clock) Option {
+	return func(o *clientOptions) {
+		o.clock = clock
+	}
+}
+
+// WithOIDCAPI uses api for sign-in instead of an SDK client. Endpoint, HTTP
+// client and retryer options do not apply to it.
+func WithOIDCAPI(api OIDCAPI) Option {
+	return func(o *clientOptions) {
+		o.oidc = api
+	}
+}
+
+// WithPortalAPI uses api for the portal instead of an SDK client. Endpoint,
+// HTTP client and retryer options do not apply to it.
+func WithPortalAPI(api PortalAPI) Option {
+	return func(o *clientOptions) {
+		o.portal = api
+	}
+}
+
+// baseEndpoint resolves the endpoint override for a service, or nil
+func (o *clientOptions) baseEndpoint(service, region string) *string {
+	if o.endpointResolver == nil {
+		return nil
+	}
+	if endpoint := o.endpointResolver(service, region); endpoint != "" {
+		return aws.String(endpoint)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package sso
+
+import (
+	"errors"
+	"fmt"
+
+	ssotypes "github.com/aws/aws-sdk-go-v2/service/sso/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
+)
+
+// Errors Authenticate wraps its failures in, to be checked with errors.Is
+var (
+	// ErrAccessDenied means the user rejected the sign-in request
+	ErrAccessDenied = errors.New("sign-in was denied")
+
+	// ErrDeviceCodeExpired means the device code expired before the user
+	// signed in, on every attempt
+	ErrDeviceCodeExpired = errors.New("sign-in code expired")
+
+	// ErrInvalidClient means IAM Identity Center rejected the registered client
+	ErrInvalidClient = errors.New("client registration was rejected")
+)
+
+// IsUnauthorized reports whether the portal rejected the access token, e.g.
+// because the session was revoked or the user signed out elsewhere
+func IsUnauthorized(err error) bool {
+	var unauthorized *ssotypes.UnauthorizedException
+	return errors.As(err, &unauthorized)
+}
+
+// maxDeviceCodeRestarts is how often Authenticate starts a new device
+// authorization after the code expired
+const maxDeviceCodeRestarts = 2
+
+// pollOutcome classifies a CreateToken error
+type pollOutcome int
+
+const (
+	pollFailed pollOutcome = iota
+	pollPending
+	pollSlowDown
+	pollExpired
+)
+
+// classifyPollError maps a CreateToken error to what the poll loop does next,
+// and wraps terminal errors in the matching sentinel
+func classifyPollError(err error) (pollOutcome, error) {
+	var (
+		pending  *types.AuthorizationPendingException
+		slowDown *types.SlowDownException
+		expired  *types.ExpiredTokenException
+		denied   *types.AccessDeniedException
+	)
+
+	switch {
+	case errors.As(err, &pending):
+		return pollPending, nil
+	case errors.As(err, &slowDown):
+		return pollSlowDown, nil
+	case errors.As(err, &expired):
+		return pollExpired, nil
+	case errors.As(err, &denied):
+		return pollFailed, fmt.Errorf("%w: %w", ErrAccessDenied, err)
+	}
+	return pollFailed, wrapClientError(err)
+}
+
+// wrapClientError wraps an OIDC error in ErrInvalidClient if the client was rejected
+func wrapClientError(err error) error {
+	var (
+		invalidClient *types.InvalidClientException
+		unauthorized  *types.UnauthorizedClientException
+	)
+	if errors.As(err, &invalidClient) || errors.As(err, &unauthorized) {
+		return fmt.Errorf("%w: %w", ErrInvalidClient, err)
+	}
+	return err
+}
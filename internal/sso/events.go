@@ -0,0 +1,53 @@
+package sso
+
+import "time"
+
+// LoginEventType identifies a step of the device authorization flow
+type LoginEventType int
+
+const (
+	// EventRegistering is sent before the client registers with the OIDC API
+	EventRegistering LoginEventType = iota
+
+	// EventStartingAuthorization is sent before the device authorization starts
+	EventStartingAuthorization
+
+	// EventVerification carries the URL and code the user signs in with. The
+	// handler shows them and opens a browser if it wants to.
+	EventVerification
+
+	// EventWaiting is sent once, before polling for the token starts
+	EventWaiting
+
+	// EventPending is sent for each poll the user has not approved yet
+	EventPending
+
+	// EventSlowDown is sent when the API asks for a longer poll interval
+	EventSlowDown
+
+	// EventAuthorized is sent when the access token has been issued
+	EventAuthorized
+
+	// EventExpired is sent when the device code expired before the user
+	// signed in and a new device authorization is about to start
+	EventExpired
+)
+
+// LoginEvent reports progress of Authenticate. Fields that do not apply to an
+// event type are zero.
+type LoginEvent struct {
+	Type LoginEventType
+
+	// VerificationURL and UserCode are what the user signs in with
+	VerificationURL string
+	UserCode        string
+
+	// ExpiresAt is when the device code expires
+	ExpiresAt time.Time
+
+	// Interval is the current poll interval
+	Interval time.Duration
+}
+
+// LoginHandler receives the events of a sign-in
+type LoginHandler func(LoginEvent)
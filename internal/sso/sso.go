@@ -2,24 +2,31 @@ package sso
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"net/url"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sso"
 	"github.com/aws/aws-sdk-go-v2/service/sso/types"
 	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
-	"github.com/ysaakpr/aws-term/internal/browser"
-	"github.com/ysaakpr/aws-term/internal/ui"
 )
 
 const (
 	ClientName = "aws-term"
 	ClientType = "public"
 	GrantType  = "urn:ietf:params:oauth:grant-type:device_code"
+
+	// DefaultPrefetchConcurrency is the number of ListAccountRoles calls in flight
+	DefaultPrefetchConcurrency = 8
+
+	// maxThrottleRetries is how often a throttled ListAccountRoles call is retried
+	maxThrottleRetries = 5
 )
 
 // Credentials represents AWS credentials
@@ -30,145 +37,230 @@ type Credentials struct {
 	Expiration      time.Time
 }
 
+// Provider returns an SDK credentials provider that always returns these credentials
+func (c *Credentials) Provider() aws.CredentialsProvider {
+	return aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{
+			AccessKeyID:     c.AccessKeyId,
+			SecretAccessKey: c.SecretAccessKey,
+			SessionToken:    c.SessionToken,
+			CanExpire:       !c.Expiration.IsZero(),
+			Expires:         c.Expiration,
+		}, nil
+	})
+}
+
 // Account represents an AWS account
 type Account struct {
-	AccountId   string
-	AccountName string
-	EmailAddress string
+	AccountId    string `json:"account_id"`
+	AccountName  string `json:"account_name"`
+	EmailAddress string `json:"email_address,omitempty"`
 }
 
 // Role represents an AWS role
 type Role struct {
-	RoleName  string
-	AccountId string
+	RoleName  string `json:"role_name"`
+	AccountId string `json:"account_id"`
+}
+
+// AccountRole is a single account and role pair, used by the flat picker
+type AccountRole struct {
+	Account Account
+	Role    Role
 }
 
 // SSOClient handles AWS SSO operations using the SDK
 type SSOClient struct {
-	StartURL    string
-	Region      string
-	oidcClient  *ssooidc.Client
-	ssoClient   *sso.Client
+	StartURL string
+	Region   string
+
+	// OnEvent receives the progress of Authenticate, including the
+	// verification URL and code to show the user. Nil discards events.
+	OnEvent LoginHandler
+
+	oidcClient OIDCAPI
+	ssoClient  PortalAPI
+	clock      Clock
+
+	// tokenMu guards the token, which background listings read while the
+	// caller may sign in again
+	tokenMu     sync.RWMutex
 	accessToken string
+	tokenExpiry time.Time
 }
 
 // NewSSOClient creates a new SSO client
-func NewSSOClient(startURL, region string) *SSOClient {
+func NewSSOClient(startURL, region string, opts ...Option) *SSOClient {
+	var options clientOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	// Create OIDC client for device authorization
-	oidcClient := ssooidc.New(ssooidc.Options{
-		Region: region,
-	})
+	oidcClient := options.oidc
+	if oidcClient == nil {
+		oidcClient = ssooidc.New(ssooidc.Options{
+			Region:       region,
+			BaseEndpoint: options.baseEndpoint(ServiceOIDC, region),
+			HTTPClient:   options.httpClient,
+			Retryer:      options.retryer,
+		})
+	}
 
 	// Create SSO client for account/role listing and credentials
-	ssoClient := sso.New(sso.Options{
-		Region: region,
-	})
+	ssoClient := options.portal
+	if ssoClient == nil {
+		ssoClient = sso.New(sso.Options{
+			Region:       region,
+			BaseEndpoint: options.baseEndpoint(ServicePortal, region),
+			HTTPClient:   options.httpClient,
+			Retryer:      options.retryer,
+		})
+	}
+
+	clock := options.clock
+	if clock == nil {
+		clock = SystemClock{}
+	}
 
 	return &SSOClient{
 		StartURL:   startURL,
 		Region:     region,
 		oidcClient: oidcClient,
 		ssoClient:  ssoClient,
+		clock:      clock,
+	}
+}
+
+// emit sends an event to OnEvent, if set
+func (c *SSOClient) emit(event LoginEvent) {
+	if c.OnEvent != nil {
+		c.OnEvent(event)
 	}
 }
 
-// Authenticate performs the SSO device authorization flow
-func (c *SSOClient) Authenticate(ctx context.Context, browserName string) error {
+// Authenticate performs the SSO device authorization flow, reporting its
+// progress to OnEvent. When the device code expires before the user signs in,
+// a new one is requested up to maxDeviceCodeRestarts times. Failures wrap
+// ErrAccessDenied, ErrDeviceCodeExpired or ErrInvalidClient where they apply;
+// cancelling ctx stops polling and returns ctx.Err().
+func (c *SSOClient) Authenticate(ctx context.Context) error {
 	// Step 1: Register the client
-	ui.PrintInfo("Registering client with AWS SSO...")
-	
+	c.emit(LoginEvent{Type: EventRegistering})
+
 	registerOutput, err := c.oidcClient.RegisterClient(ctx, &ssooidc.RegisterClientInput{
 		ClientName: aws.String(ClientName),
 		ClientType: aws.String(ClientType),
 		Scopes:     []string{"sso:account:access"},
 	})
 	if err != nil {
-		return fmt.Errorf("failed to register client: %w", err)
+		return fmt.Errorf("failed to register client: %w", wrapClientError(err))
 	}
 
 	clientId := aws.ToString(registerOutput.ClientId)
 	clientSecret := aws.ToString(registerOutput.ClientSecret)
 
+	for attempt := 0; ; attempt++ {
+		err := c.authorizeDevice(ctx, clientId, clientSecret)
+		if !errors.Is(err, ErrDeviceCodeExpired) || attempt >= maxDeviceCodeRestarts {
+			return err
+		}
+		c.emit(LoginEvent{Type: EventExpired})
+	}
+}
+
+// authorizeDevice runs one device authorization with a registered client:
+// it starts the authorization, lets the user sign in and polls for the token
+func (c *SSOClient) authorizeDevice(ctx context.Context, clientId, clientSecret string) error {
 	// Step 2: Start device authorization
-	ui.PrintInfo("Starting device authorization...")
-	
+	c.emit(LoginEvent{Type: EventStartingAuthorization})
+
 	deviceAuthOutput, err := c.oidcClient.StartDeviceAuthorization(ctx, &ssooidc.StartDeviceAuthorizationInput{
 		ClientId:     aws.String(clientId),
 		ClientSecret: aws.String(clientSecret),
 		StartUrl:     aws.String(c.StartURL),
 	})
 	if err != nil {
-		return fmt.Errorf("failed to start device authorization: %w", err)
+		return fmt.Errorf("failed to start device authorization: %w", wrapClientError(err))
 	}
 
-	verificationUri := aws.ToString(deviceAuthOutput.VerificationUriComplete)
-	userCode := aws.ToString(deviceAuthOutput.UserCode)
 	deviceCode := aws.ToString(deviceAuthOutput.DeviceCode)
-	expiresIn := deviceAuthOutput.ExpiresIn
-	interval := deviceAuthOutput.Interval
-
-	// Step 3: Open browser for user to authorize
-	fmt.Println()
-	fmt.Printf("%s%s════════════════════════════════════════════%s\n", ui.ColorBold, ui.ColorCyan, ui.ColorReset)
-	fmt.Printf("%s  Opening browser for AWS SSO login...%s\n", ui.ColorYellow, ui.ColorReset)
-	fmt.Printf("%s════════════════════════════════════════════%s\n", ui.ColorCyan, ui.ColorReset)
-	fmt.Println()
-	fmt.Printf("  If browser doesn't open, visit:\n")
-	fmt.Printf("  %s%s%s\n", ui.ColorBlue, verificationUri, ui.ColorReset)
-	fmt.Println()
-	fmt.Printf("  Verification code: %s%s%s\n", ui.ColorBold, userCode, ui.ColorReset)
-	fmt.Println()
-
-	if err := browser.OpenURL(browserName, verificationUri); err != nil {
-		ui.PrintError(fmt.Sprintf("Failed to open browser: %v", err))
-		fmt.Println("Please open the URL manually in your browser.")
-	}
+	deadline := c.clock.Now().Add(time.Duration(deviceAuthOutput.ExpiresIn) * time.Second)
 
-	// Step 4: Poll for the token
-	ui.PrintInfo("Waiting for authorization... (press Ctrl+C to cancel)")
-	
-	pollInterval := time.Duration(interval) * time.Second
+	pollInterval := time.Duration(deviceAuthOutput.Interval) * time.Second
 	if pollInterval < 1*time.Second {
 		pollInterval = 5 * time.Second
 	}
-	
-	deadline := time.Now().Add(time.Duration(expiresIn) * time.Second)
 
-	for time.Now().Before(deadline) {
+	// Step 3: Let the user sign in
+	c.emit(LoginEvent{
+		Type:            EventVerification,
+		VerificationURL: aws.ToString(deviceAuthOutput.VerificationUriComplete),
+		UserCode:        aws.ToString(deviceAuthOutput.UserCode),
+		ExpiresAt:       deadline,
+		Interval:        pollInterval,
+	})
+
+	// Step 4: Poll for the token
+	c.emit(LoginEvent{Type: EventWaiting, ExpiresAt: deadline, Interval: pollInterval})
+
+	for c.clock.Now().Before(deadline) {
 		tokenOutput, err := c.oidcClient.CreateToken(ctx, &ssooidc.CreateTokenInput{
 			ClientId:     aws.String(clientId),
 			ClientSecret: aws.String(clientSecret),
 			GrantType:    aws.String(GrantType),
 			DeviceCode:   aws.String(deviceCode),
 		})
-		
+
 		if err != nil {
-			// Check if it's an authorization pending error
-			if strings.Contains(err.Error(), "AuthorizationPendingException") ||
-			   strings.Contains(err.Error(), "authorization_pending") {
-				fmt.Print(".")
-				time.Sleep(pollInterval)
-				continue
-			}
-			
-			// Check if it's a slow down error
-			if strings.Contains(err.Error(), "SlowDownException") ||
-			   strings.Contains(err.Error(), "slow_down") {
+			outcome, err := classifyPollError(err)
+			switch outcome {
+			case pollPending:
+				c.emit(LoginEvent{Type: EventPending, ExpiresAt: deadline, Interval: pollInterval})
+			case pollSlowDown:
 				pollInterval = pollInterval * 2
-				time.Sleep(pollInterval)
-				continue
+				c.emit(LoginEvent{Type: EventSlowDown, ExpiresAt: deadline, Interval: pollInterval})
+			case pollExpired:
+				return ErrDeviceCodeExpired
+			default:
+				return fmt.Errorf("failed to get token: %w", err)
+			}
+			select {
+			case <-c.clock.After(pollInterval):
+			case <-ctx.Done():
+				return ctx.Err()
 			}
-			
-			return fmt.Errorf("failed to get token: %w", err)
+			continue
 		}
 
-		c.accessToken = aws.ToString(tokenOutput.AccessToken)
-		fmt.Println()
-		ui.PrintSuccess("Authorization successful!")
+		expiresAt := c.clock.Now().Add(time.Duration(tokenOutput.ExpiresIn) * time.Second)
+		c.SetAccessToken(aws.ToString(tokenOutput.AccessToken), expiresAt)
+		c.emit(LoginEvent{Type: EventAuthorized})
 		return nil
 	}
 
-	return fmt.Errorf("authorization timed out")
+	return ErrDeviceCodeExpired
+}
+
+// AccessToken returns the access token from the last sign-in and when it expires
+func (c *SSOClient) AccessToken() (string, time.Time) {
+	c.tokenMu.RLock()
+	defer c.tokenMu.RUnlock()
+	return c.accessToken, c.tokenExpiry
+}
+
+// SetAccessToken uses a previously obtained access token instead of signing in
+func (c *SSOClient) SetAccessToken(token string, expiresAt time.Time) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	c.accessToken = token
+	c.tokenExpiry = expiresAt
+}
+
+// token returns the access token for a portal request
+func (c *SSOClient) token() *string {
+	token, _ := c.AccessToken()
+	return aws.String(token)
 }
 
 // ListAccounts lists all AWS accounts available to the user
@@ -178,7 +270,7 @@ func (c *SSOClient) ListAccounts(ctx context.Context) ([]Account, error) {
 
 	for {
 		output, err := c.ssoClient.ListAccounts(ctx, &sso.ListAccountsInput{
-			AccessToken: aws.String(c.accessToken),
+			AccessToken: c.token(),
 			NextToken:   nextToken,
 		})
 		if err != nil {
@@ -204,15 +296,20 @@ func (c *SSOClient) ListAccounts(ctx context.Context) ([]Account, error) {
 
 // ListRoles lists all roles for a specific account
 func (c *SSOClient) ListRoles(ctx context.Context, accountId string) ([]Role, error) {
+	return c.listRoles(ctx, accountId)
+}
+
+// listRoles lists the roles of an account, applying optFns to each request
+func (c *SSOClient) listRoles(ctx context.Context, accountId string, optFns ...func(*sso.Options)) ([]Role, error) {
 	var roles []Role
 	var nextToken *string
 
 	for {
 		output, err := c.ssoClient.ListAccountRoles(ctx, &sso.ListAccountRolesInput{
-			AccessToken: aws.String(c.accessToken),
+			AccessToken: c.token(),
 			AccountId:   aws.String(accountId),
 			NextToken:   nextToken,
-		})
+		}, optFns...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to list roles: %w", err)
 		}
@@ -233,10 +330,96 @@ func (c *SSOClient) ListRoles(ctx context.Context, accountId string) ([]Role, er
 	return roles, nil
 }
 
+// PrefetchRoles lists the roles of every account in parallel, running at most
+// concurrency requests at a time. Throttled requests are retried with
+// exponential backoff; the first non-throttling error aborts the fetch.
+func (c *SSOClient) PrefetchRoles(ctx context.Context, accounts []Account, concurrency int) (map[string][]Role, error) {
+	if concurrency < 1 {
+		concurrency = DefaultPrefetchConcurrency
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		result   = make(map[string][]Role, len(accounts))
+		sem      = make(chan struct{}, concurrency)
+	)
+
+	for _, acc := range accounts {
+		wg.Add(1)
+		go func(accountId string) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-sem }()
+
+			roles, err := c.listRolesWithBackoff(ctx, accountId)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				return
+			}
+			result[accountId] = roles
+		}(acc.AccountId)
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// listRolesWithBackoff calls ListRoles, backing off while the API is
+// throttling. The SDK's own retries are turned off for these calls, so a
+// throttled request is not retried by both.
+func (c *SSOClient) listRolesWithBackoff(ctx context.Context, accountId string) ([]Role, error) {
+	backoff := 500 * time.Millisecond
+	noRetries := func(o *sso.Options) { o.Retryer = aws.NopRetryer{} }
+
+	for attempt := 0; ; attempt++ {
+		roles, err := c.listRoles(ctx, accountId, noRetries)
+		if err == nil {
+			return roles, nil
+		}
+
+		var throttled *types.TooManyRequestsException
+		if !errors.As(err, &throttled) || attempt >= maxThrottleRetries {
+			return nil, fmt.Errorf("account %s: %w", accountId, err)
+		}
+
+		// Jitter keeps parallel workers from retrying in lockstep
+		wait := time.Duration(rand.Int63n(int64(backoff))) + backoff/2
+		select {
+		case <-c.clock.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
 // GetRoleCredentials gets credentials for a specific role
 func (c *SSOClient) GetRoleCredentials(ctx context.Context, accountId, roleName string) (*Credentials, error) {
 	output, err := c.ssoClient.GetRoleCredentials(ctx, &sso.GetRoleCredentialsInput{
-		AccessToken: aws.String(c.accessToken),
+		AccessToken: c.token(),
 		AccountId:   aws.String(accountId),
 		RoleName:    aws.String(roleName),
 	})
@@ -253,53 +436,16 @@ func (c *SSOClient) GetRoleCredentials(ctx context.Context, accountId, roleName
 	}, nil
 }
 
-// SelectAccount prompts the user to select an account
-func SelectAccount(accounts []Account) (*Account, error) {
-	if len(accounts) == 0 {
-		return nil, fmt.Errorf("no accounts available")
-	}
-
-	if len(accounts) == 1 {
-		fmt.Printf("%sUsing account: %s (%s)%s\n", ui.ColorCyan, accounts[0].AccountName, accounts[0].AccountId, ui.ColorReset)
-		return &accounts[0], nil
-	}
-
-	// Convert to a format suitable for selection
-	items := make([]string, len(accounts))
-	for i, acc := range accounts {
-		items[i] = fmt.Sprintf("%s (%s)", acc.AccountName, acc.AccountId)
-	}
-
-	idx, err := ui.SelectFromList("Select an AWS account:", items)
-	if err != nil {
-		return nil, err
-	}
-
-	return &accounts[idx], nil
-}
-
-// SelectRole prompts the user to select a role
-func SelectRole(roles []Role) (*Role, error) {
-	if len(roles) == 0 {
-		return nil, fmt.Errorf("no roles available")
-	}
-
-	if len(roles) == 1 {
-		fmt.Printf("%sUsing role: %s%s\n", ui.ColorCyan, roles[0].RoleName, ui.ColorReset)
-		return &roles[0], nil
-	}
-
-	items := make([]string, len(roles))
-	for i, role := range roles {
-		items[i] = role.RoleName
-	}
-
-	idx, err := ui.SelectFromList("Select a role:", items)
-	if err != nil {
-		return nil, err
+// FlattenAccountRoles builds the account/role pairs for the flat picker,
+// keeping accounts in the given order and skipping accounts without roles
+func FlattenAccountRoles(accounts []Account, roles map[string][]Role) []AccountRole {
+	var pairs []AccountRole
+	for _, acc := range accounts {
+		for _, role := range roles[acc.AccountId] {
+			pairs = append(pairs, AccountRole{Account: acc, Role: role})
+		}
 	}
-
-	return &roles[idx], nil
+	return pairs
 }
 
 // ValidateSSOUrl validates if the provided URL is a valid AWS SSO start URL
@@ -320,6 +466,25 @@ func ValidateSSOUrl(ssoUrl string) error {
 	return nil
 }
 
+// RoleFromARN returns the role name in an assumed-role ARN, as returned by
+// STS GetCallerIdentity, or "" for other ARNs. Roles created for permission
+// sets are named AWSReservedSSO_<permission set>_<id>, so the permission set
+// is returned for them.
+func RoleFromARN(arn string) string {
+	_, resource, ok := strings.Cut(arn, ":assumed-role/")
+	if !ok {
+		return ""
+	}
+	role, _, _ := strings.Cut(resource, "/")
+	if name, ok := strings.CutPrefix(role, "AWSReservedSSO_"); ok {
+		if i := strings.LastIndex(name, "_"); i > 0 {
+			name = name[:i]
+		}
+		return name
+	}
+	return role
+}
+
 // ExtractRegionFromURL tries to extract the region from an AWS SSO URL
 func ExtractRegionFromURL(ssoUrl string) string {
 	// Default region
@@ -369,6 +534,63 @@ func ExportCredentialsScript(creds *Credentials) string {
 	return sb.String()
 }
 
+// Shell formats for ExportCredentials
+const (
+	ShellPOSIX      = "posix"
+	ShellFish       = "fish"
+	ShellPowerShell = "powershell"
+	ShellCmd        = "cmd"
+)
+
+// ShellFormatFor returns the export format for a shell path such as $SHELL
+func ShellFormatFor(shell string) string {
+	name := strings.ToLower(shell)
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSuffix(name, ".exe")
+	switch name {
+	case "fish":
+		return ShellFish
+	case "pwsh", "powershell":
+		return ShellPowerShell
+	case "cmd":
+		return ShellCmd
+	default:
+		return ShellPOSIX
+	}
+}
+
+// ExportCredentials returns the commands that set the credentials in the
+// environment of a shell using format
+func ExportCredentials(creds *Credentials, format string) (string, error) {
+	vars := [][2]string{
+		{"AWS_ACCESS_KEY_ID", creds.AccessKeyId},
+		{"AWS_SECRET_ACCESS_KEY", creds.SecretAccessKey},
+		{"AWS_SESSION_TOKEN", creds.SessionToken},
+	}
+
+	var line string
+	switch format {
+	case ShellPOSIX, "":
+		line = "export %s=\"%s\"\n"
+	case ShellFish:
+		line = "set -gx %s \"%s\"\n"
+	case ShellPowerShell:
+		line = "$env:%s = \"%s\"\n"
+	case ShellCmd:
+		line = "set %s=%s\r\n"
+	default:
+		return "", fmt.Errorf("unknown shell format '%s' (use posix, fish, powershell or cmd)", format)
+	}
+
+	var sb strings.Builder
+	for _, v := range vars {
+		sb.WriteString(fmt.Sprintf(line, v[0], v[1]))
+	}
+	return sb.String(), nil
+}
+
 // WriteCredentialsToFile writes credentials to a temporary file for sourcing
 func WriteCredentialsToFile(creds *Credentials) (string, error) {
 	homeDir, err := os.UserHomeDir()
@@ -0,0 +1,444 @@
+package sso_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	awssso "github.com/aws/aws-sdk-go-v2/service/sso"
+	"github.com/ysaakpr/aws-term/internal/sso"
+	"github.com/ysaakpr/aws-term/internal/sso/ssotest"
+)
+
+var testAccounts = []ssotest.Account{
+	{AccountId: "111111111111", AccountName: "dev", EmailAddress: "dev@example.com", Roles: []string{"Admin", "ReadOnly", "Developer"}},
+	{AccountId: "222222222222", AccountName: "staging", Roles: []string{"ReadOnly"}},
+	{AccountId: "333333333333", AccountName: "prod", Roles: []string{"ReadOnly", "Admin"}},
+}
+
+// fakeClock advances instantly by the time waited for, recording each wait
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	waits []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.waits = append(c.waits, d)
+
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func newTestClient(t *testing.T, opts ...sso.Option) (*ssotest.Server, *sso.SSOClient) {
+	t.Helper()
+	server := ssotest.NewServer(testAccounts...)
+	t.Cleanup(server.Close)
+	opts = append([]sso.Option{sso.WithEndpoint(server.URL)}, opts...)
+	client := sso.NewSSOClient("https://example.awsapps.com/start", "us-east-1", opts...)
+	return server, client
+}
+
+func TestLoginFlow(t *testing.T) {
+	server, client := newTestClient(t)
+	ctx := context.Background()
+
+	if err := client.Authenticate(ctx); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if token, _ := client.AccessToken(); token != server.AccessToken {
+		t.Fatalf("AccessToken() = %q, want %q", token, server.AccessToken)
+	}
+
+	accounts, err := client.ListAccounts(ctx)
+	if err != nil {
+		t.Fatalf("ListAccounts() error = %v", err)
+	}
+	if len(accounts) != len(testAccounts) {
+		t.Fatalf("ListAccounts() returned %d accounts, want %d", len(accounts), len(testAccounts))
+	}
+	for i, acc := range accounts {
+		if acc.AccountId != testAccounts[i].AccountId || acc.AccountName != testAccounts[i].AccountName {
+			t.Errorf("account %d = %+v, want %s/%s", i, acc, testAccounts[i].AccountId, testAccounts[i].AccountName)
+		}
+	}
+	if got := server.Calls(ssotest.OpListAccounts); got != 2 {
+		t.Errorf("ListAccounts pages = %d, want 2", got)
+	}
+
+	roles, err := client.ListRoles(ctx, "111111111111")
+	if err != nil {
+		t.Fatalf("ListRoles() error = %v", err)
+	}
+	var names []string
+	for _, role := range roles {
+		names = append(names, role.RoleName)
+	}
+	if got := strings.Join(names, ","); got != "Admin,ReadOnly,Developer" {
+		t.Errorf("ListRoles() = %s, want Admin,ReadOnly,Developer", got)
+	}
+
+	creds, err := client.GetRoleCredentials(ctx, "111111111111", "Developer")
+	if err != nil {
+		t.Fatalf("GetRoleCredentials() error = %v", err)
+	}
+	accessKeyId, secretAccessKey, sessionToken := ssotest.Credentials("111111111111", "Developer")
+	if creds.AccessKeyId != accessKeyId || creds.SecretAccessKey != secretAccessKey || creds.SessionToken != sessionToken {
+		t.Errorf("GetRoleCredentials() = %+v", creds)
+	}
+	if creds.Expiration.IsZero() {
+		t.Error("GetRoleCredentials() has no expiration")
+	}
+}
+
+func TestAuthenticatePolling(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	server, client := newTestClient(t, sso.WithClock(clock))
+	server.ScriptPolls(ssotest.Pending, ssotest.SlowDown)
+
+	var events []sso.LoginEventType
+	var verification sso.LoginEvent
+	client.OnEvent = func(event sso.LoginEvent) {
+		events = append(events, event.Type)
+		if event.Type == sso.EventVerification {
+			verification = event
+		}
+	}
+
+	if err := client.Authenticate(context.Background()); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if got := server.Calls(ssotest.OpCreateToken); got != 3 {
+		t.Errorf("CreateToken calls = %d, want 3", got)
+	}
+
+	// The slow_down answer doubles the one second interval
+	if fmt.Sprint(clock.waits) != "[1s 2s]" {
+		t.Errorf("poll waits = %v, want [1s 2s]", clock.waits)
+	}
+
+	want := []sso.LoginEventType{
+		sso.EventRegistering,
+		sso.EventStartingAuthorization,
+		sso.EventVerification,
+		sso.EventWaiting,
+		sso.EventPending,
+		sso.EventSlowDown,
+		sso.EventAuthorized,
+	}
+	if fmt.Sprint(events) != fmt.Sprint(want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+	if verification.UserCode != "ABCD-EFGH" || !strings.HasPrefix(verification.VerificationURL, server.URL) {
+		t.Errorf("verification event = %+v", verification)
+	}
+	if want := clock.now.Add(-3 * time.Second).Add(10 * time.Minute); !verification.ExpiresAt.Equal(want) {
+		t.Errorf("ExpiresAt = %v, want %v", verification.ExpiresAt, want)
+	}
+}
+
+func TestAuthenticateFailures(t *testing.T) {
+	tests := []struct {
+		name   string
+		states []ssotest.TokenState
+		want   error
+	}{
+		{"expired on every attempt", []ssotest.TokenState{ssotest.Expired, ssotest.Expired, ssotest.Expired}, sso.ErrDeviceCodeExpired},
+		{"denied", []ssotest.TokenState{ssotest.Denied}, sso.ErrAccessDenied},
+		{"invalid client", []ssotest.TokenState{ssotest.InvalidClient}, sso.ErrInvalidClient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := newTestClient(t)
+			server.ScriptPolls(tt.states...)
+
+			err := client.Authenticate(context.Background())
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Authenticate() error = %v, want %v", err, tt.want)
+			}
+			if token, _ := client.AccessToken(); token != "" {
+				t.Errorf("AccessToken() = %q after failed sign-in", token)
+			}
+		})
+	}
+}
+
+func TestAuthenticateRestartsExpiredCode(t *testing.T) {
+	server, client := newTestClient(t)
+	server.ScriptPolls(ssotest.Expired)
+
+	expired := 0
+	client.OnEvent = func(event sso.LoginEvent) {
+		if event.Type == sso.EventExpired {
+			expired++
+		}
+	}
+
+	if err := client.Authenticate(context.Background()); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if got := server.Calls(ssotest.OpStartDeviceAuthorization); got != 2 {
+		t.Errorf("StartDeviceAuthorization calls = %d, want 2", got)
+	}
+	if got := server.Calls(ssotest.OpRegisterClient); got != 1 {
+		t.Errorf("RegisterClient calls = %d, want 1", got)
+	}
+	if expired != 1 {
+		t.Errorf("EventExpired sent %d times, want 1", expired)
+	}
+}
+
+func TestAuthenticateTimesOut(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	server, client := newTestClient(t, sso.WithClock(clock))
+	server.ExpiresIn = 2
+	for i := 0; i < 10; i++ {
+		server.ScriptPolls(ssotest.Pending)
+	}
+
+	if err := client.Authenticate(context.Background()); !errors.Is(err, sso.ErrDeviceCodeExpired) {
+		t.Fatalf("Authenticate() error = %v, want %v", err, sso.ErrDeviceCodeExpired)
+	}
+	if got := server.Calls(ssotest.OpStartDeviceAuthorization); got != 3 {
+		t.Errorf("StartDeviceAuthorization calls = %d, want 3", got)
+	}
+}
+
+func TestPortalRejectsUnknownToken(t *testing.T) {
+	_, client := newTestClient(t)
+	client.SetAccessToken("stale-token", time.Now().Add(time.Hour))
+
+	_, err := client.ListAccounts(context.Background())
+	if err == nil {
+		t.Fatal("ListAccounts() with a stale token succeeded")
+	}
+	if !sso.IsUnauthorized(err) {
+		t.Errorf("IsUnauthorized(%v) = false", err)
+	}
+	if sso.IsUnauthorized(sso.ErrAccessDenied) {
+		t.Error("IsUnauthorized(ErrAccessDenied) = true")
+	}
+}
+
+func TestPrefetchRoles(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := context.Background()
+
+	if err := client.Authenticate(ctx); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	accounts, err := client.ListAccounts(ctx)
+	if err != nil {
+		t.Fatalf("ListAccounts() error = %v", err)
+	}
+
+	roles, err := client.PrefetchRoles(ctx, accounts, 2)
+	if err != nil {
+		t.Fatalf("PrefetchRoles() error = %v", err)
+	}
+	for _, acc := range testAccounts {
+		if got := len(roles[acc.AccountId]); got != len(acc.Roles) {
+			t.Errorf("roles for %s = %d, want %d", acc.AccountName, got, len(acc.Roles))
+		}
+	}
+}
+
+func TestPrefetchRolesThrottled(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	server, client := newTestClient(t, sso.WithClock(clock))
+	client.SetAccessToken(server.AccessToken, time.Now().Add(time.Hour))
+	staging := []sso.Account{{AccountId: testAccounts[1].AccountId}}
+
+	server.ThrottleRoles(3)
+	roles, err := client.PrefetchRoles(context.Background(), staging, 1)
+	if err != nil {
+		t.Fatalf("PrefetchRoles() error = %v", err)
+	}
+	if len(roles[staging[0].AccountId]) != 1 {
+		t.Errorf("roles = %v, want staging's ReadOnly", roles)
+	}
+
+	// Each throttled call is retried once, by PrefetchRoles and not the SDK
+	if got := server.Calls(ssotest.OpListAccountRoles); got != 4 {
+		t.Errorf("ListAccountRoles calls = %d, want 3 throttled and 1 answered", got)
+	}
+	if len(clock.waits) != 3 {
+		t.Fatalf("waited %d times, want 3", len(clock.waits))
+	}
+	backoff := 500 * time.Millisecond
+	for i, wait := range clock.waits {
+		if wait < backoff/2 || wait >= backoff*3/2 {
+			t.Errorf("wait %d = %s, want between %s and %s", i+1, wait, backoff/2, backoff*3/2)
+		}
+		backoff *= 2
+	}
+}
+
+func TestPrefetchRolesGivesUpThrottled(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	server, client := newTestClient(t, sso.WithClock(clock))
+	client.SetAccessToken(server.AccessToken, time.Now().Add(time.Hour))
+	staging := []sso.Account{{AccountId: testAccounts[1].AccountId}}
+
+	server.ThrottleRoles(100)
+	_, err := client.PrefetchRoles(context.Background(), staging, 1)
+	if err == nil || !strings.Contains(err.Error(), "TooManyRequests") {
+		t.Fatalf("PrefetchRoles() error = %v, want TooManyRequestsException", err)
+	}
+	// The first call and five retries
+	if got := server.Calls(ssotest.OpListAccountRoles); got != 6 {
+		t.Errorf("ListAccountRoles calls = %d, want 6", got)
+	}
+}
+
+func TestSetAccessTokenWhileListing(t *testing.T) {
+	server, client := newTestClient(t)
+	client.SetAccessToken(server.AccessToken, time.Now().Add(time.Hour))
+	ctx := context.Background()
+
+	// A background listing may run while the caller signs in again; run
+	// with -race to check the token is not shared unguarded
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.PrefetchRoles(ctx, []sso.Account{{AccountId: testAccounts[0].AccountId}}, 1)
+		done <- err
+	}()
+	for i := 0; i < 10; i++ {
+		client.SetAccessToken(server.AccessToken, time.Now().Add(time.Hour))
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("PrefetchRoles() error = %v", err)
+	}
+
+	if token, _ := client.AccessToken(); token != server.AccessToken {
+		t.Errorf("AccessToken() = %q, want %q", token, server.AccessToken)
+	}
+}
+
+// stubPortal is a PortalAPI that fails every call
+type stubPortal struct{ err error }
+
+func (p stubPortal) ListAccounts(context.Context, *awssso.ListAccountsInput, ...func(*awssso.Options)) (*awssso.ListAccountsOutput, error) {
+	return nil, p.err
+}
+
+func (p stubPortal) ListAccountRoles(context.Context, *awssso.ListAccountRolesInput, ...func(*awssso.Options)) (*awssso.ListAccountRolesOutput, error) {
+	return nil, p.err
+}
+
+func (p stubPortal) GetRoleCredentials(context.Context, *awssso.GetRoleCredentialsInput, ...func(*awssso.Options)) (*awssso.GetRoleCredentialsOutput, error) {
+	return nil, p.err
+}
+
+func TestWithPortalAPI(t *testing.T) {
+	stubErr := errors.New("portal unavailable")
+	client := sso.NewSSOClient("https://example.awsapps.com/start", "us-east-1", sso.WithPortalAPI(stubPortal{stubErr}))
+
+	if _, err := client.ListAccounts(context.Background()); !errors.Is(err, stubErr) {
+		t.Fatalf("ListAccounts() error = %v, want %v", err, stubErr)
+	}
+}
+
+// stoppedClock never lets a wait finish
+type stoppedClock struct{}
+
+func (stoppedClock) Now() time.Time                       { return time.Now() }
+func (stoppedClock) After(time.Duration) <-chan time.Time { return nil }
+
+func TestAuthenticateCancel(t *testing.T) {
+	server, client := newTestClient(t, sso.WithClock(stoppedClock{}))
+	server.ScriptPolls(ssotest.Pending)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client.OnEvent = func(event sso.LoginEvent) {
+		if event.Type == sso.EventPending {
+			cancel()
+		}
+	}
+
+	if err := client.Authenticate(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Authenticate() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestPortalCancel(t *testing.T) {
+	_, client := newTestClient(t)
+	client.SetAccessToken("fake-access-token", time.Now().Add(time.Hour))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := client.ListAccounts(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("ListAccounts() error = %v, want %v", err, context.Canceled)
+	}
+	if _, err := client.ListRoles(ctx, "111111111111"); !errors.Is(err, context.Canceled) {
+		t.Errorf("ListRoles() error = %v, want %v", err, context.Canceled)
+	}
+	if _, err := client.GetRoleCredentials(ctx, "111111111111", "Admin"); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetRoleCredentials() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestExportCredentials(t *testing.T) {
+	creds := &sso.Credentials{AccessKeyId: "AKID", SecretAccessKey: "SECRET", SessionToken: "TOKEN"}
+
+	tests := []struct {
+		shell string
+		want  string
+	}{
+		{"/bin/zsh", "export AWS_ACCESS_KEY_ID=\"AKID\"\nexport AWS_SECRET_ACCESS_KEY=\"SECRET\"\nexport AWS_SESSION_TOKEN=\"TOKEN\"\n"},
+		{"/usr/local/bin/fish", "set -gx AWS_ACCESS_KEY_ID \"AKID\"\nset -gx AWS_SECRET_ACCESS_KEY \"SECRET\"\nset -gx AWS_SESSION_TOKEN \"TOKEN\"\n"},
+		{"pwsh", "$env:AWS_ACCESS_KEY_ID = \"AKID\"\n$env:AWS_SECRET_ACCESS_KEY = \"SECRET\"\n$env:AWS_SESSION_TOKEN = \"TOKEN\"\n"},
+		{`C:\Windows\System32\cmd.exe`, "set AWS_ACCESS_KEY_ID=AKID\r\nset AWS_SECRET_ACCESS_KEY=SECRET\r\nset AWS_SESSION_TOKEN=TOKEN\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.shell, func(t *testing.T) {
+			got, err := sso.ExportCredentials(creds, sso.ShellFormatFor(tt.shell))
+			if err != nil {
+				t.Fatalf("ExportCredentials() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ExportCredentials() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := sso.ExportCredentials(creds, "tcsh"); err == nil {
+		t.Error("ExportCredentials() accepted an unknown format")
+	}
+}
+
+func TestRoleFromARN(t *testing.T) {
+	tests := []struct {
+		arn  string
+		want string
+	}{
+		{"arn:aws:sts::123456789012:assumed-role/AWSReservedSSO_AdministratorAccess_0123456789abcdef/jane@example.com", "AdministratorAccess"},
+		{"arn:aws:sts::123456789012:assumed-role/AWSReservedSSO_Read_Only_0123456789abcdef/jane", "Read_Only"},
+		{"arn:aws:sts::123456789012:assumed-role/deploy/aws-term-jane", "deploy"},
+		{"arn:aws:iam::123456789012:user/jane", ""},
+	}
+
+	for _, tt := range tests {
+		if got := sso.RoleFromARN(tt.arn); got != tt.want {
+			t.Errorf("RoleFromARN(%q) = %q, want %q", tt.arn, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,427 @@
+// Package ssotest provides a fake IAM Identity Center OIDC and portal API for
+// exercising the SSO login flow offline.
+package ssotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Operation names, as counted by Server.Calls
+const (
+	OpRegisterClient           = "RegisterClient"
+	OpStartDeviceAuthorization = "StartDeviceAuthorization"
+	OpCreateToken              = "CreateToken"
+	OpListAccounts             = "ListAccounts"
+	OpListAccountRoles         = "ListAccountRoles"
+	OpGetRoleCredentials       = "GetRoleCredentials"
+)
+
+// bearerHeader carries the access token on portal requests
+const bearerHeader = "x-amz-sso_bearer_token"
+
+// Account is an account served by the fake portal, with the roles the user may assume
+type Account struct {
+	AccountId    string
+	AccountName  string
+	EmailAddress string
+	Roles        []string
+}
+
+// TokenState is the outcome of one CreateToken poll
+type TokenState int
+
+const (
+	// Approved issues the access token
+	Approved TokenState = iota
+
+	// Pending answers authorization_pending, as before the user approves
+	Pending
+
+	// SlowDown asks the client to poll less often
+	SlowDown
+
+	// Expired reports that the device code has expired
+	Expired
+
+	// Denied reports that the user rejected the request
+	Denied
+
+	// InvalidClient rejects the client credentials
+	InvalidClient
+)
+
+// Server is a fake OIDC and portal API. Both APIs are served from the same
+// URL, which is passed to sso.WithEndpoint.
+type Server struct {
+	*httptest.Server
+
+	// Accounts are returned by ListAccounts in order
+	Accounts []Account
+
+	// PageSize is the largest page ListAccounts and ListAccountRoles return
+	PageSize int
+
+	// AccessToken is issued once the device authorization is approved
+	AccessToken string
+
+	// ExpiresIn is the device code lifetime and Interval the poll interval, in seconds
+	ExpiresIn int32
+	Interval  int32
+
+	mu          sync.Mutex
+	polls       []TokenState
+	throttled   int
+	clients     map[string]string
+	deviceCodes map[string]bool
+	calls       map[string]int
+}
+
+// NewServer starts a fake serving the given accounts. Close it when done.
+func NewServer(accounts ...Account) *Server {
+	s := &Server{
+		Accounts:    accounts,
+		PageSize:    2,
+		AccessToken: "fake-access-token",
+		ExpiresIn:   600,
+		Interval:    1,
+		clients:     map[string]string{},
+		deviceCodes: map[string]bool{},
+		calls:       map[string]int{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /client/register", s.registerClient)
+	mux.HandleFunc("POST /device_authorization", s.startDeviceAuthorization)
+	mux.HandleFunc("POST /token", s.createToken)
+	mux.HandleFunc("GET /assignment/accounts", s.listAccounts)
+	mux.HandleFunc("GET /assignment/roles", s.listAccountRoles)
+	mux.HandleFunc("GET /federation/credentials", s.getRoleCredentials)
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// ScriptPolls sets the answers to the next CreateToken calls. Once they are
+// used up, polls are approved.
+func (s *Server) ScriptPolls(states ...TokenState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.polls = append(s.polls, states...)
+}
+
+// ThrottleRoles answers the next n ListAccountRoles calls with
+// TooManyRequestsException
+func (s *Server) ThrottleRoles(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.throttled += n
+}
+
+// Calls returns how often an operation has been called
+func (s *Server) Calls(op string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[op]
+}
+
+// Credentials returns the credentials the fake issues for an account and role
+func Credentials(accountId, roleName string) (accessKeyId, secretAccessKey, sessionToken string) {
+	return "ASIA" + accountId, "secret-" + roleName, "token-" + accountId + "-" + roleName
+}
+
+func (s *Server) count(op string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[op]++
+}
+
+func (s *Server) registerClient(w http.ResponseWriter, r *http.Request) {
+	s.count(OpRegisterClient)
+
+	var input struct {
+		ClientName string `json:"clientName"`
+		ClientType string `json:"clientType"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.ClientName == "" || input.ClientType == "" {
+		writeError(w, http.StatusBadRequest, "InvalidRequestException", "invalid_request", "clientName and clientType are required")
+		return
+	}
+
+	s.mu.Lock()
+	clientId := fmt.Sprintf("client-%d", len(s.clients)+1)
+	s.clients[clientId] = "secret-" + clientId
+	s.mu.Unlock()
+
+	now := time.Now()
+	writeJSON(w, map[string]any{
+		"clientId":              clientId,
+		"clientSecret":          "secret-" + clientId,
+		"clientIdIssuedAt":      now.Unix(),
+		"clientSecretExpiresAt": now.Add(90 * 24 * time.Hour).Unix(),
+	})
+}
+
+// validClient reports whether a client ID and secret were issued by RegisterClient
+func (s *Server) validClient(clientId, clientSecret string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secret, ok := s.clients[clientId]
+	return ok && secret == clientSecret
+}
+
+func (s *Server) startDeviceAuthorization(w http.ResponseWriter, r *http.Request) {
+	s.count(OpStartDeviceAuthorization)
+
+	var input struct {
+		ClientId     string `json:"clientId"`
+		ClientSecret string `json:"clientSecret"`
+		StartUrl     string `json:"startUrl"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.StartUrl == "" {
+		writeError(w, http.StatusBadRequest, "InvalidRequestException", "invalid_request", "startUrl is required")
+		return
+	}
+	if !s.validClient(input.ClientId, input.ClientSecret) {
+		writeError(w, http.StatusUnauthorized, "InvalidClientException", "invalid_client", "unknown client")
+		return
+	}
+
+	s.mu.Lock()
+	deviceCode := fmt.Sprintf("device-%d", len(s.deviceCodes)+1)
+	s.deviceCodes[deviceCode] = true
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]any{
+		"deviceCode":              deviceCode,
+		"userCode":                "ABCD-EFGH",
+		"verificationUri":         s.URL + "/device",
+		"verificationUriComplete": s.URL + "/device?user_code=ABCD-EFGH",
+		"expiresIn":               s.ExpiresIn,
+		"interval":                s.Interval,
+	})
+}
+
+func (s *Server) createToken(w http.ResponseWriter, r *http.Request) {
+	s.count(OpCreateToken)
+
+	var input struct {
+		ClientId     string `json:"clientId"`
+		ClientSecret string `json:"clientSecret"`
+		GrantType    string `json:"grantType"`
+		DeviceCode   string `json:"deviceCode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidRequestException", "invalid_request", "malformed request")
+		return
+	}
+	if !s.validClient(input.ClientId, input.ClientSecret) {
+		writeError(w, http.StatusUnauthorized, "InvalidClientException", "invalid_client", "unknown client")
+		return
+	}
+	if input.GrantType != "urn:ietf:params:oauth:grant-type:device_code" {
+		writeError(w, http.StatusBadRequest, "UnsupportedGrantTypeException", "unsupported_grant_type", "unsupported grant type")
+		return
+	}
+
+	s.mu.Lock()
+	known := s.deviceCodes[input.DeviceCode]
+	state := Approved
+	if len(s.polls) > 0 {
+		state, s.polls = s.polls[0], s.polls[1:]
+	}
+	s.mu.Unlock()
+
+	if !known {
+		writeError(w, http.StatusBadRequest, "InvalidGrantException", "invalid_grant", "unknown device code")
+		return
+	}
+
+	switch state {
+	case Pending:
+		writeError(w, http.StatusBadRequest, "AuthorizationPendingException", "authorization_pending", "authorization is pending")
+	case SlowDown:
+		writeError(w, http.StatusBadRequest, "SlowDownException", "slow_down", "polling too often")
+	case Expired:
+		writeError(w, http.StatusBadRequest, "ExpiredTokenException", "expired_token", "device code has expired")
+	case Denied:
+		writeError(w, http.StatusBadRequest, "AccessDeniedException", "access_denied", "authorization was denied")
+	case InvalidClient:
+		writeError(w, http.StatusUnauthorized, "InvalidClientException", "invalid_client", "client credentials are invalid")
+	default:
+		writeJSON(w, map[string]any{
+			"accessToken": s.AccessToken,
+			"tokenType":   "Bearer",
+			"expiresIn":   8 * 60 * 60,
+		})
+	}
+}
+
+// authorized checks the portal bearer token, writing an error if it is not valid
+func (s *Server) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get(bearerHeader) != s.AccessToken {
+		writeError(w, http.StatusUnauthorized, "UnauthorizedException", "", "session token not found or invalid")
+		return false
+	}
+	return true
+}
+
+// page returns the items of one page of n items starting at next_token, and
+// the token of the following page
+func (s *Server) page(r *http.Request, n int) (start, end int, nextToken string, ok bool) {
+	if token := r.URL.Query().Get("next_token"); token != "" {
+		var err error
+		start, err = strconv.Atoi(token)
+		if err != nil || start < 0 || start > n {
+			return 0, 0, "", false
+		}
+	}
+
+	size := s.PageSize
+	if max, err := strconv.Atoi(r.URL.Query().Get("max_result")); err == nil && max > 0 && (size <= 0 || max < size) {
+		size = max
+	}
+	if size <= 0 {
+		size = n
+	}
+
+	end = start + size
+	if end >= n {
+		return start, n, "", true
+	}
+	return start, end, strconv.Itoa(end), true
+}
+
+// account returns the account with the given ID
+func (s *Server) account(accountId string) (Account, bool) {
+	for _, acc := range s.Accounts {
+		if acc.AccountId == accountId {
+			return acc, true
+		}
+	}
+	return Account{}, false
+}
+
+func (s *Server) listAccounts(w http.ResponseWriter, r *http.Request) {
+	s.count(OpListAccounts)
+	if !s.authorized(w, r) {
+		return
+	}
+
+	start, end, nextToken, ok := s.page(r, len(s.Accounts))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "InvalidRequestException", "", "invalid next_token")
+		return
+	}
+
+	list := []map[string]string{}
+	for _, acc := range s.Accounts[start:end] {
+		list = append(list, map[string]string{
+			"accountId":    acc.AccountId,
+			"accountName":  acc.AccountName,
+			"emailAddress": acc.EmailAddress,
+		})
+	}
+
+	resp := map[string]any{"accountList": list}
+	if nextToken != "" {
+		resp["nextToken"] = nextToken
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) listAccountRoles(w http.ResponseWriter, r *http.Request) {
+	s.count(OpListAccountRoles)
+	if !s.authorized(w, r) {
+		return
+	}
+
+	s.mu.Lock()
+	throttle := s.throttled > 0
+	if throttle {
+		s.throttled--
+	}
+	s.mu.Unlock()
+	if throttle {
+		writeError(w, http.StatusTooManyRequests, "TooManyRequestsException", "", "rate exceeded")
+		return
+	}
+
+	acc, ok := s.account(r.URL.Query().Get("account_id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "ResourceNotFoundException", "", "account not found")
+		return
+	}
+
+	start, end, nextToken, ok := s.page(r, len(acc.Roles))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "InvalidRequestException", "", "invalid next_token")
+		return
+	}
+
+	list := []map[string]string{}
+	for _, role := range acc.Roles[start:end] {
+		list = append(list, map[string]string{
+			"accountId": acc.AccountId,
+			"roleName":  role,
+		})
+	}
+
+	resp := map[string]any{"roleList": list}
+	if nextToken != "" {
+		resp["nextToken"] = nextToken
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) getRoleCredentials(w http.ResponseWriter, r *http.Request) {
+	s.count(OpGetRoleCredentials)
+	if !s.authorized(w, r) {
+		return
+	}
+
+	accountId, roleName := r.URL.Query().Get("account_id"), r.URL.Query().Get("role_name")
+	acc, ok := s.account(accountId)
+	hasRole := false
+	for _, role := range acc.Roles {
+		hasRole = hasRole || role == roleName
+	}
+	if !ok || !hasRole {
+		writeError(w, http.StatusNotFound, "ResourceNotFoundException", "", "no access to the role")
+		return
+	}
+
+	accessKeyId, secretAccessKey, sessionToken := Credentials(accountId, roleName)
+	writeJSON(w, map[string]any{
+		"roleCredentials": map[string]any{
+			"accessKeyId":     accessKeyId,
+			"secretAccessKey": secretAccessKey,
+			"sessionToken":    sessionToken,
+			"expiration":      time.Now().Add(time.Hour).UnixMilli(),
+		},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a REST-JSON error the SDK maps to the named exception type
+func writeError(w http.ResponseWriter, status int, errorType, oauthError, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Amzn-ErrorType", errorType)
+	w.WriteHeader(status)
+
+	body := map[string]string{"message": message}
+	if oauthError != "" {
+		body["error"] = oauthError
+		body["error_description"] = message
+	}
+	json.NewEncoder(w).Encode(body)
+}
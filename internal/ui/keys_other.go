@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package ui
+
+// keysSupported reports whether readKeys can read single keys without
+// blocking. Elsewhere the live status line is shown without shortcuts.
+const keysSupported = false
+
+func readKeys(fd int, stop <-chan struct{}, keys chan<- byte) {}
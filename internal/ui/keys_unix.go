@@ -0,0 +1,50 @@
+//go:build linux || darwin
+
+package ui
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// keysSupported reports whether readKeys can read single keys without blocking
+const keysSupported = true
+
+// readKeys sends the bytes typed on fd to keys until stop is closed. It waits
+// with select(2) in short slices so that no read is left pending afterwards
+// to steal input from the next prompt.
+func readKeys(fd int, stop <-chan struct{}, keys chan<- byte) {
+	var buf [8]byte
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		var fds unix.FdSet
+		fds.Set(fd)
+		timeout := unix.NsecToTimeval(int64(statusRefresh))
+		n, err := unix.Select(fd+1, &fds, nil, nil, &timeout)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		if n == 0 || !fds.IsSet(fd) {
+			continue
+		}
+
+		n, err = unix.Read(fd, buf[:])
+		if err != nil || n == 0 {
+			return
+		}
+		for _, b := range buf[:n] {
+			select {
+			case keys <- b:
+			case <-stop:
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/ysaakpr/aws-term/internal/history"
+	"github.com/ysaakpr/aws-term/internal/sso"
+)
+
+// AccountGroup is a titled group of accounts shown together in the account picker
+type AccountGroup struct {
+	Title    string
+	Accounts []sso.Account
+}
+
+// RoleGroup is a titled group of roles shown together in the role picker
+type RoleGroup struct {
+	Title string
+	Roles []sso.Role
+}
+
+// AccountLabel formats an account for display in pickers
+type AccountLabel func(acc sso.Account) string
+
+// DefaultAccountLabel shows the account name followed by its ID
+func DefaultAccountLabel(acc sso.Account) string {
+	return fmt.Sprintf("%s (%s)", acc.AccountName, acc.AccountId)
+}
+
+// AccountRoleGroup is a titled group of account/role pairs shown in the flat picker
+type AccountRoleGroup struct {
+	Title string
+	Pairs []sso.AccountRole
+}
+
+// favoritesTitle is the heading of the favorites section in pickers
+func favoritesTitle() string {
+	return Symbols.Star + " Favorites"
+}
+
+// untitled reports whether ordered items are shown as a plain list: nothing
+// has been starred or used yet
+func untitled[T any](s history.Sections[T]) bool {
+	return len(s.Favorites) == 0 && len(s.Recent) == 0
+}
+
+// AccountGroups titles ordered accounts for SelectAccountFromGroups
+func AccountGroups(s history.Sections[sso.Account]) []AccountGroup {
+	if untitled(s) {
+		return []AccountGroup{{Accounts: s.Rest}}
+	}
+	return []AccountGroup{
+		{Title: favoritesTitle(), Accounts: s.Favorites},
+		{Title: "Recent", Accounts: s.Recent},
+		{Title: "All accounts", Accounts: s.Rest},
+	}
+}
+
+// RoleGroups titles ordered roles for SelectRoleFromGroups
+func RoleGroups(s history.Sections[sso.Role]) []RoleGroup {
+	if untitled(s) {
+		return []RoleGroup{{Roles: s.Rest}}
+	}
+	return []RoleGroup{
+		{Title: favoritesTitle(), Roles: s.Favorites},
+		{Title: "Recent", Roles: s.Recent},
+		{Title: "All roles", Roles: s.Rest},
+	}
+}
+
+// AccountRoleGroups titles ordered account/role pairs for SelectAccountRoleFromGroups
+func AccountRoleGroups(s history.Sections[sso.AccountRole]) []AccountRoleGroup {
+	if untitled(s) {
+		return []AccountRoleGroup{{Pairs: s.Rest}}
+	}
+	return []AccountRoleGroup{
+		{Title: favoritesTitle(), Pairs: s.Favorites},
+		{Title: "Recent", Pairs: s.Recent},
+		{Title: "All roles", Pairs: s.Rest},
+	}
+}
+
+// SelectAccount prompts the user to select an account
+func SelectAccount(accounts []sso.Account) (*sso.Account, error) {
+	return SelectAccountFromGroups([]AccountGroup{{Accounts: accounts}}, nil)
+}
+
+// SelectAccountFromGroups prompts the user to select an account from grouped sections.
+// A nil label uses DefaultAccountLabel.
+func SelectAccountFromGroups(groups []AccountGroup, label AccountLabel) (*sso.Account, error) {
+	if label == nil {
+		label = DefaultAccountLabel
+	}
+
+	var accounts []sso.Account
+	sections := make([]Section, len(groups))
+	for i, g := range groups {
+		sections[i].Title = g.Title
+		for _, acc := range g.Accounts {
+			sections[i].Items = append(sections[i].Items, label(acc))
+		}
+		accounts = append(accounts, g.Accounts...)
+	}
+
+	if len(accounts) == 0 {
+		return nil, fmt.Errorf("no accounts available")
+	}
+
+	if len(accounts) == 1 {
+		PrintInfo(fmt.Sprintf("Using account: %s", label(accounts[0])))
+		return &accounts[0], nil
+	}
+
+	idx, err := SelectFromSections("Select an AWS account:", sections)
+	if err != nil {
+		return nil, err
+	}
+
+	return &accounts[idx], nil
+}
+
+// SelectRole prompts the user to select a role
+func SelectRole(roles []sso.Role) (*sso.Role, error) {
+	return SelectRoleFromGroups([]RoleGroup{{Roles: roles}})
+}
+
+// SelectRoleFromGroups prompts the user to select a role from grouped sections
+func SelectRoleFromGroups(groups []RoleGroup) (*sso.Role, error) {
+	var roles []sso.Role
+	sections := make([]Section, len(groups))
+	for i, g := range groups {
+		sections[i].Title = g.Title
+		for _, role := range g.Roles {
+			sections[i].Items = append(sections[i].Items, role.RoleName)
+		}
+		roles = append(roles, g.Roles...)
+	}
+
+	if len(roles) == 0 {
+		return nil, fmt.Errorf("no roles available")
+	}
+
+	if len(roles) == 1 {
+		PrintInfo(fmt.Sprintf("Using role: %s", roles[0].RoleName))
+		return &roles[0], nil
+	}
+
+	idx, err := SelectFromSections("Select a role:", sections)
+	if err != nil {
+		return nil, err
+	}
+
+	return &roles[idx], nil
+}
+
+// SelectAccountRoleFromGroups prompts the user to pick an account and role in one step.
+// A nil label uses DefaultAccountLabel.
+func SelectAccountRoleFromGroups(groups []AccountRoleGroup, label AccountLabel) (*sso.AccountRole, error) {
+	if label == nil {
+		label = DefaultAccountLabel
+	}
+
+	var pairs []sso.AccountRole
+	sections := make([]Section, len(groups))
+	for i, g := range groups {
+		sections[i].Title = g.Title
+		for _, p := range g.Pairs {
+			sections[i].Items = append(sections[i].Items, fmt.Sprintf("%s / %s", label(p.Account), p.Role.RoleName))
+		}
+		pairs = append(pairs, g.Pairs...)
+	}
+
+	if len(pairs) == 0 {
+		return nil, fmt.Errorf("no roles available")
+	}
+
+	if len(pairs) == 1 {
+		PrintInfo(fmt.Sprintf("Using %s / %s", label(pairs[0].Account), pairs[0].Role.RoleName))
+		return &pairs[0], nil
+	}
+
+	idx, err := SelectFromSections("Select an AWS account and role:", sections)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pairs[idx], nil
+}
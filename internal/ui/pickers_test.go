@@ -0,0 +1,47 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ysaakpr/aws-term/internal/history"
+	"github.com/ysaakpr/aws-term/internal/sso"
+)
+
+func TestAccountGroups(t *testing.T) {
+	dev := sso.Account{AccountId: "111111111111", AccountName: "dev"}
+	prod := sso.Account{AccountId: "222222222222", AccountName: "prod"}
+
+	// Nothing starred or used yet: one list without headings
+	groups := AccountGroups(history.Sections[sso.Account]{Rest: []sso.Account{dev, prod}})
+	if len(groups) != 1 || groups[0].Title != "" || len(groups[0].Accounts) != 2 {
+		t.Errorf("AccountGroups() without history = %+v, want one untitled group", groups)
+	}
+
+	groups = AccountGroups(history.Sections[sso.Account]{Favorites: []sso.Account{prod}, Rest: []sso.Account{dev}})
+	var titles []string
+	for _, g := range groups {
+		titles = append(titles, g.Title)
+	}
+	if len(groups) != 3 || !strings.HasPrefix(titles[0], Symbols.Star) || titles[1] != "Recent" || titles[2] != "All accounts" {
+		t.Errorf("AccountGroups() titles = %q, want favorites, Recent, All accounts", titles)
+	}
+	if groups[0].Accounts[0] != prod || groups[2].Accounts[0] != dev {
+		t.Errorf("AccountGroups() = %+v, want prod starred and dev in the rest", groups)
+	}
+}
+
+func TestRoleGroups(t *testing.T) {
+	admin := sso.Role{RoleName: "Admin"}
+	readOnly := sso.Role{RoleName: "ReadOnly"}
+
+	groups := RoleGroups(history.Sections[sso.Role]{Recent: []sso.Role{readOnly}, Rest: []sso.Role{admin}})
+	if len(groups) != 3 || groups[1].Title != "Recent" || groups[1].Roles[0] != readOnly || groups[2].Title != "All roles" {
+		t.Errorf("RoleGroups() = %+v, want ReadOnly under Recent", groups)
+	}
+
+	pairs := AccountRoleGroups(history.Sections[sso.AccountRole]{Rest: []sso.AccountRole{{Role: admin}}})
+	if len(pairs) != 1 || pairs[0].Title != "" {
+		t.Errorf("AccountRoleGroups() without history = %+v, want one untitled group", pairs)
+	}
+}
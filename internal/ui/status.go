@@ -0,0 +1,225 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ysaakpr/aws-term/internal/sso"
+	"golang.org/x/term"
+)
+
+const (
+	// statusRefresh is how often a live status line is redrawn
+	statusRefresh = 100 * time.Millisecond
+
+	// noteDuration is how long a note replaces the shortcut hints
+	noteDuration = 3 * time.Second
+)
+
+// Shortcut is a key the user can press while a live status line is shown.
+// Action returns a short note that is shown on the line for a moment.
+type Shortcut struct {
+	Key    byte
+	Label  string
+	Action func() string
+}
+
+// LiveStatus redraws a single line with a spinner, a message and the time
+// left until a deadline, and runs shortcuts for keys pressed meanwhile. Only
+// use it when CanRedraw().
+type LiveStatus struct {
+	out         io.Writer
+	clock       sso.Clock
+	keys        <-chan byte
+	message     string
+	deadline    time.Time
+	shortcuts   []Shortcut
+	onInterrupt func()
+
+	mu        sync.Mutex
+	note      string
+	noteUntil time.Time
+
+	restore  func()
+	stop     chan struct{}
+	done     chan struct{}
+	keysDone chan struct{}
+	stopOnce sync.Once
+}
+
+// IsTerminal reports whether f is connected to a terminal
+func IsTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// StatusOption configures a LiveStatus
+type StatusOption func(*LiveStatus)
+
+// WithStatusClock tells the time and waits between redraws with clock
+// instead of package time
+func WithStatusClock(clock sso.Clock) StatusOption {
+	return func(s *LiveStatus) { s.clock = clock }
+}
+
+// WithKeys reads shortcut keys from keys instead of the terminal
+func WithKeys(keys <-chan byte) StatusOption {
+	return func(s *LiveStatus) { s.keys = keys }
+}
+
+// WithStatusOutput draws the status line on w instead of stderr
+func WithStatusOutput(w io.Writer) StatusOption {
+	return func(s *LiveStatus) { s.out = w }
+}
+
+// StartLiveStatus starts drawing the status line on stderr. Shortcuts are
+// read from stdin in raw mode if it is a terminal and the platform supports
+// it; Ctrl+C then calls onInterrupt, since raw mode turns off SIGINT.
+func StartLiveStatus(message string, deadline time.Time, shortcuts []Shortcut, onInterrupt func(), opts ...StatusOption) *LiveStatus {
+	s := &LiveStatus{
+		out:         os.Stderr,
+		clock:       sso.SystemClock{},
+		message:     message,
+		deadline:    deadline,
+		onInterrupt: onInterrupt,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+		keysDone:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	keys := s.keys
+	if keys != nil {
+		s.shortcuts = shortcuts
+		close(s.keysDone)
+	} else {
+		terminalKeys := make(chan byte, 8)
+		fd := int(os.Stdin.Fd())
+		if keysSupported && term.IsTerminal(fd) {
+			if restore, err := makeRaw(fd); err == nil {
+				s.shortcuts, s.restore = shortcuts, restore
+			}
+		}
+		if s.restore != nil {
+			go func() {
+				defer close(s.keysDone)
+				readKeys(fd, s.stop, terminalKeys)
+			}()
+		} else {
+			close(s.keysDone)
+		}
+		keys = terminalKeys
+	}
+
+	fmt.Fprint(s.out, HideCursor)
+	go s.run(keys)
+	return s
+}
+
+// Note shows text in place of the shortcut hints for a few seconds
+func (s *LiveStatus) Note(text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.note = text
+	s.noteUntil = s.clock.Now().Add(noteDuration)
+}
+
+// Stop clears the status line and gives the terminal back. It is safe to call
+// more than once.
+func (s *LiveStatus) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+		<-s.done
+		<-s.keysDone
+		if s.restore != nil {
+			s.restore()
+		}
+		fmt.Fprint(s.out, "\r"+ClearLine+ShowCursor)
+	})
+}
+
+func (s *LiveStatus) run(keys <-chan byte) {
+	defer close(s.done)
+
+	for frame := 0; ; frame++ {
+		s.draw(Symbols.Spinner[frame%len(Symbols.Spinner)])
+
+		select {
+		case <-s.stop:
+			return
+		case key := <-keys:
+			s.handleKey(key)
+		case <-s.clock.After(statusRefresh):
+		}
+	}
+}
+
+// handleKey runs the shortcut for key, or onInterrupt for Ctrl+C
+func (s *LiveStatus) handleKey(key byte) {
+	if key == 3 {
+		if s.onInterrupt != nil {
+			s.onInterrupt()
+		}
+		return
+	}
+	for _, sc := range s.shortcuts {
+		if key == sc.Key || (key >= 'A' && key <= 'Z' && key+'a'-'A' == sc.Key) {
+			if note := sc.Action(); note != "" {
+				s.Note(note)
+			}
+			return
+		}
+	}
+}
+
+// draw redraws the line, cut to the terminal width so it never wraps
+func (s *LiveStatus) draw(spinner string) {
+	now := s.clock.Now()
+	parts := []string{s.message, "expires in " + FormatCountdown(s.deadline.Sub(now))}
+
+	s.mu.Lock()
+	if s.note != "" && now.Before(s.noteUntil) {
+		parts = append(parts, s.note)
+	} else {
+		for _, sc := range s.shortcuts {
+			parts = append(parts, fmt.Sprintf("[%c] %s", sc.Key, sc.Label))
+		}
+	}
+	s.mu.Unlock()
+
+	line := strings.Join(parts, " "+Symbols.Separator+" ")
+	if f, ok := s.out.(*os.File); ok {
+		if width, _, err := term.GetSize(int(f.Fd())); err == nil && width > 4 {
+			line = truncate(line, width-3)
+		}
+	}
+
+	fmt.Fprintf(s.out, "\r%s%s%s%s %s", ClearLine, ColorCyan, spinner, ColorReset, line)
+}
+
+// FormatCountdown formats a duration as m:ss, never below 0:00
+func FormatCountdown(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	secs := int(d.Round(time.Second) / time.Second)
+	return fmt.Sprintf("%d:%02d", secs/60, secs%60)
+}
+
+// truncate cuts s to at most n runes, marking the cut with an ellipsis
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	ellipsis := []rune(Symbols.Ellipsis)
+	if n <= len(ellipsis) {
+		return string(runes[:n])
+	}
+	return string(runes[:n-len(ellipsis)]) + Symbols.Ellipsis
+}
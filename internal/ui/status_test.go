@@ -0,0 +1,160 @@
+package ui
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stepClock stands still until the test moves it, and redraws only when the
+// test sends on tick
+type stepClock struct {
+	mu   sync.Mutex
+	now  time.Time
+	tick chan time.Time
+}
+
+func newStepClock(now time.Time) *stepClock {
+	return &stepClock{now: now, tick: make(chan time.Time)}
+}
+
+func (c *stepClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *stepClock) After(time.Duration) <-chan time.Time {
+	return c.tick
+}
+
+// redrawAt moves the clock to now and waits for the status line to take the redraw
+func (c *stepClock) redrawAt(now time.Time) {
+	c.mu.Lock()
+	c.now = now
+	c.mu.Unlock()
+	c.tick <- now
+}
+
+// syncBuffer is an output the test can read while the status line draws on it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// lastLine returns the text of the last status line drawn to out, before
+// Stop cleared it
+func lastLine(out string) string {
+	draws := strings.Split(out, "\r")
+	last := draws[len(draws)-1]
+	if last == ClearLine+ShowCursor && len(draws) > 1 {
+		last = draws[len(draws)-2]
+	}
+	return strings.TrimPrefix(last, ClearLine)
+}
+
+func TestLiveStatusDraw(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := newStepClock(start)
+	var out bytes.Buffer
+
+	shortcuts := []Shortcut{{Key: 'c', Label: "copy code"}, {Key: 'o', Label: "open browser"}}
+	s := StartLiveStatus("Waiting for sign-in", start.Add(90*time.Second), shortcuts, nil,
+		WithStatusClock(clock), WithKeys(make(chan byte)), WithStatusOutput(&out))
+	clock.redrawAt(start.Add(30 * time.Second))
+	s.Stop()
+
+	sep := " " + Symbols.Separator + " "
+	want := "Waiting for sign-in" + sep + "expires in 1:00" + sep + "[c] copy code" + sep + "[o] open browser"
+	if line := lastLine(out.String()); !strings.HasSuffix(line, " "+want) {
+		t.Errorf("status line = %q, want it to end with %q", line, want)
+	}
+	if !strings.HasPrefix(out.String(), HideCursor) || !strings.HasSuffix(out.String(), "\r"+ClearLine+ShowCursor) {
+		t.Errorf("output = %q, want the cursor hidden while drawing and shown after Stop", out.String())
+	}
+}
+
+func TestLiveStatusExpired(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := newStepClock(start)
+	var out bytes.Buffer
+
+	s := StartLiveStatus("Waiting for sign-in", start.Add(time.Second), nil, nil,
+		WithStatusClock(clock), WithKeys(make(chan byte)), WithStatusOutput(&out))
+	clock.redrawAt(start.Add(time.Minute))
+	s.Stop()
+
+	if line := lastLine(out.String()); !strings.HasSuffix(line, "expires in 0:00") {
+		t.Errorf("status line = %q, want the countdown stopped at 0:00", line)
+	}
+}
+
+func TestLiveStatusKeys(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := newStepClock(start)
+	keys := make(chan byte)
+	var out syncBuffer
+
+	var copied, opened, interrupted int
+	shortcuts := []Shortcut{
+		{Key: 'c', Label: "copy code", Action: func() string { copied++; return "Code copied" }},
+		{Key: 'o', Label: "open browser", Action: func() string { opened++; return "" }},
+	}
+	s := StartLiveStatus("Waiting for sign-in", start.Add(time.Minute), shortcuts, func() { interrupted++ },
+		WithStatusClock(clock), WithKeys(keys), WithStatusOutput(&out))
+
+	// Keys match either case; unknown keys are ignored
+	for _, key := range []byte{'c', 'O', 'x', 3, 'C'} {
+		keys <- key
+	}
+	// The note replaces the hints until it times out
+	clock.redrawAt(start.Add(noteDuration - time.Second))
+	// An ignored key is taken only once the redraw is done
+	keys <- 'x'
+	noted := lastLine(out.String())
+	clock.redrawAt(start.Add(noteDuration))
+	s.Stop()
+
+	if copied != 2 || opened != 1 || interrupted != 1 {
+		t.Errorf("copied %d, opened %d, interrupted %d times; want 2, 1 and 1", copied, opened, interrupted)
+	}
+	if !strings.HasSuffix(noted, "Code copied") || strings.Contains(noted, "[c] copy code") {
+		t.Errorf("status line = %q, want the note in place of the hints", noted)
+	}
+	if line := lastLine(out.String()); !strings.HasSuffix(line, "[o] open browser") {
+		t.Errorf("status line = %q, want the hints back once the note timed out", line)
+	}
+}
+
+func TestFormatCountdown(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{d: 0, want: "0:00"},
+		{d: -time.Minute, want: "0:00"},
+		{d: 1499 * time.Millisecond, want: "0:01"},
+		{d: 59*time.Second + 600*time.Millisecond, want: "1:00"},
+		{d: 10*time.Minute + 5*time.Second, want: "10:05"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatCountdown(tt.d); got != tt.want {
+			t.Errorf("FormatCountdown(%s) = %s, want %s", tt.d, got, tt.want)
+		}
+	}
+}
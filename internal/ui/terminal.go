@@ -0,0 +1,71 @@
+package ui
+
+import (
+	"os"
+
+	"golang.org/x/term"
+)
+
+// Capabilities are the escape codes the output may contain
+type Capabilities struct {
+	// Color allows colors and bold text
+	Color bool
+
+	// Cursor allows moving the cursor and redrawing lines, for arrow-key
+	// pickers and the live status line
+	Cursor bool
+}
+
+var (
+	capabilities = Capabilities{Color: true, Cursor: true}
+	quietMode    bool
+)
+
+// DetectCapabilities decides what the output may contain. Colors need both
+// stdout and stderr to be terminals and are turned off by NO_COLOR; cursor
+// control needs stderr, where pickers are drawn. TERM=dumb turns off both.
+func DetectCapabilities(stdoutTerminal, stderrTerminal bool, getenv func(string) string) Capabilities {
+	dumb := getenv("TERM") == "dumb"
+	return Capabilities{
+		Color:  stdoutTerminal && stderrTerminal && !dumb && getenv("NO_COLOR") == "",
+		Cursor: stderrTerminal && !dumb,
+	}
+}
+
+// ConfigureTerminal detects the capabilities of this process's output and
+// empties the escape codes it cannot use. Call it once, after any change to
+// os.Stdout and before anything is printed.
+func ConfigureTerminal() {
+	capabilities = DetectCapabilities(IsTerminal(os.Stdout), IsTerminal(os.Stderr), os.Getenv)
+
+	if !capabilities.Color {
+		ColorReset, ColorRed, ColorGreen, ColorYellow = "", "", "", ""
+		ColorBlue, ColorMagenta, ColorCyan, ColorBold = "", "", "", ""
+		qrColors = ""
+	}
+	if !capabilities.Cursor {
+		ClearLine, MoveUp, HideCursor, ShowCursor = "", "", "", ""
+	}
+}
+
+// CanRedraw reports whether lines can be redrawn in place
+func CanRedraw() bool {
+	return capabilities.Cursor
+}
+
+// interactive reports whether arrow-key pickers can be used: keys are read
+// from stdin and the picker is redrawn on stderr
+func interactive() bool {
+	return capabilities.Cursor && term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// SetQuiet turns off the header, progress and success messages. Errors,
+// warnings and what the user has to act on are still shown.
+func SetQuiet(quiet bool) {
+	quietMode = quiet
+}
+
+// Quiet reports whether SetQuiet(true) was called
+func Quiet() bool {
+	return quietMode
+}
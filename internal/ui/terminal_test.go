@@ -0,0 +1,29 @@
+package ui
+
+import "testing"
+
+func TestDetectCapabilities(t *testing.T) {
+	tests := []struct {
+		name           string
+		stdout, stderr bool
+		env            map[string]string
+		want           Capabilities
+	}{
+		{name: "terminal", stdout: true, stderr: true, env: map[string]string{"TERM": "xterm-256color"}, want: Capabilities{Color: true, Cursor: true}},
+		{name: "NO_COLOR", stdout: true, stderr: true, env: map[string]string{"NO_COLOR": "1"}, want: Capabilities{Cursor: true}},
+		{name: "empty NO_COLOR", stdout: true, stderr: true, env: map[string]string{"NO_COLOR": ""}, want: Capabilities{Color: true, Cursor: true}},
+		{name: "dumb terminal", stdout: true, stderr: true, env: map[string]string{"TERM": "dumb"}, want: Capabilities{}},
+		{name: "stdout piped", stdout: false, stderr: true, want: Capabilities{Cursor: true}},
+		{name: "stderr redirected", stdout: true, stderr: false, want: Capabilities{}},
+		{name: "no terminal", want: Capabilities{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string { return tt.env[key] }
+			if got := DetectCapabilities(tt.stdout, tt.stderr, getenv); got != tt.want {
+				t.Errorf("DetectCapabilities() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
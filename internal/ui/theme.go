@@ -0,0 +1,183 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ysaakpr/aws-term/internal/config"
+)
+
+// DefaultTheme is used when the config does not name one
+const DefaultTheme = "dark"
+
+// SymbolSet is the set of characters the UI is drawn with
+type SymbolSet struct {
+	Pointer   string // marks the selected item in a picker
+	Success   string
+	Failure   string
+	Warning   string
+	Bullet    string
+	Star      string // marks favorites
+	Separator string // between the parts of the live status line
+	Ellipsis  string
+	Arrows    string // the arrow keys in picker hints
+	Rule      string // horizontal line under headings
+	HeavyRule string // horizontal line around the sign-in banner
+
+	// Frame draws the box around the header
+	FrameTopLeft, FrameTopRight, FrameBottomLeft, FrameBottomRight string
+	FrameHorizontal, FrameVertical                                 string
+
+	Spinner []string
+
+	// HalfBlocks draws QR codes with Unicode half blocks, two rows of
+	// modules per line. Without them each module is two characters wide.
+	HalfBlocks bool
+}
+
+// Theme is a named palette and symbol set
+type Theme struct {
+	Name string
+
+	// Each color replaces the escape code of the Color variable of the same
+	// name; Bold marks headings and the selected item in pickers
+	Red, Green, Yellow, Blue, Magenta, Cyan string
+	Bold                                    string
+
+	Symbols SymbolSet
+}
+
+var unicodeSymbols = SymbolSet{
+	Pointer:          "▸",
+	Success:          "✓",
+	Failure:          "✗",
+	Warning:          "⚠",
+	Bullet:           "•",
+	Star:             "★",
+	Separator:        "·",
+	Ellipsis:         "…",
+	Arrows:           "↑/↓",
+	Rule:             "─",
+	HeavyRule:        "═",
+	FrameTopLeft:     "╔",
+	FrameTopRight:    "╗",
+	FrameBottomLeft:  "╚",
+	FrameBottomRight: "╝",
+	FrameHorizontal:  "═",
+	FrameVertical:    "║",
+	Spinner:          []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"},
+	HalfBlocks:       true,
+}
+
+var asciiSymbols = SymbolSet{
+	Pointer:          ">",
+	Success:          "[ok]",
+	Failure:          "[error]",
+	Warning:          "[!]",
+	Bullet:           "*",
+	Star:             "*",
+	Separator:        "|",
+	Ellipsis:         "...",
+	Arrows:           "up/down",
+	Rule:             "-",
+	HeavyRule:        "=",
+	FrameTopLeft:     "+",
+	FrameTopRight:    "+",
+	FrameBottomLeft:  "+",
+	FrameBottomRight: "+",
+	FrameHorizontal:  "=",
+	FrameVertical:    "|",
+	Spinner:          []string{"|", "/", "-", "\\"},
+}
+
+// themes are the built-in themes. dark uses the terminal's standard colors;
+// light swaps them for darker shades that stay readable on a white
+// background; high-contrast uses bold bright colors and underlines emphasis.
+var themes = []Theme{
+	{
+		Name:    "dark",
+		Red:     "\033[31m",
+		Green:   "\033[32m",
+		Yellow:  "\033[33m",
+		Blue:    "\033[34m",
+		Magenta: "\033[35m",
+		Cyan:    "\033[36m",
+		Bold:    "\033[1m",
+		Symbols: unicodeSymbols,
+	},
+	{
+		Name:    "light",
+		Red:     "\033[38;5;160m",
+		Green:   "\033[38;5;28m",
+		Yellow:  "\033[38;5;130m",
+		Blue:    "\033[38;5;25m",
+		Magenta: "\033[38;5;127m",
+		Cyan:    "\033[38;5;30m",
+		Bold:    "\033[1m",
+		Symbols: unicodeSymbols,
+	},
+	{
+		Name:    "high-contrast",
+		Red:     "\033[1;91m",
+		Green:   "\033[1;92m",
+		Yellow:  "\033[1;93m",
+		Blue:    "\033[1;94m",
+		Magenta: "\033[1;95m",
+		Cyan:    "\033[1;96m",
+		Bold:    "\033[1;4m",
+		Symbols: unicodeSymbols,
+	},
+	{
+		Name:    "ascii",
+		Red:     "\033[31m",
+		Green:   "\033[32m",
+		Yellow:  "\033[33m",
+		Blue:    "\033[34m",
+		Magenta: "\033[35m",
+		Cyan:    "\033[36m",
+		Bold:    "\033[1m",
+		Symbols: asciiSymbols,
+	},
+}
+
+// Symbols are the characters of the current theme
+var Symbols = unicodeSymbols
+
+// ThemeNames lists the built-in themes
+func ThemeNames() []string {
+	names := make([]string, len(themes))
+	for i, t := range themes {
+		names[i] = t.Name
+	}
+	return names
+}
+
+// ThemeFor returns the theme chosen by the config's theme settings. An empty
+// name means DefaultTheme; ASCII swaps in plain ASCII symbols.
+func ThemeFor(settings *config.ThemeSettings) (Theme, error) {
+	name, ascii := DefaultTheme, false
+	if settings != nil {
+		if settings.Name != "" {
+			name = settings.Name
+		}
+		ascii = settings.ASCII
+	}
+
+	for _, t := range themes {
+		if strings.EqualFold(t.Name, name) {
+			if ascii {
+				t.Symbols = asciiSymbols
+			}
+			return t, nil
+		}
+	}
+	return themes[0], fmt.Errorf("unknown theme '%s' (use %s)", name, strings.Join(ThemeNames(), ", "))
+}
+
+// ApplyTheme switches the colors and symbols to t. Call it before
+// ConfigureTerminal, which may turn the colors off again.
+func ApplyTheme(t Theme) {
+	ColorRed, ColorGreen, ColorYellow, ColorBlue = t.Red, t.Green, t.Yellow, t.Blue
+	ColorMagenta, ColorCyan, ColorBold = t.Magenta, t.Cyan, t.Bold
+	Symbols = t.Symbols
+}
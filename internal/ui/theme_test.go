@@ -0,0 +1,60 @@
+package ui
+
+import (
+	"reflect"
+	"testing"
+	"unicode"
+
+	"github.com/ysaakpr/aws-term/internal/config"
+)
+
+func TestThemeFor(t *testing.T) {
+	tests := []struct {
+		name      string
+		settings  *config.ThemeSettings
+		want      string
+		wantASCII bool
+		wantErr   bool
+	}{
+		{name: "no settings", want: "dark"},
+		{name: "named", settings: &config.ThemeSettings{Name: "Light"}, want: "light"},
+		{name: "ascii theme", settings: &config.ThemeSettings{Name: "ascii"}, want: "ascii", wantASCII: true},
+		{name: "ascii symbols", settings: &config.ThemeSettings{Name: "high-contrast", ASCII: true}, want: "high-contrast", wantASCII: true},
+		{name: "unknown", settings: &config.ThemeSettings{Name: "solarized"}, want: "dark", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			theme, err := ThemeFor(tt.settings)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ThemeFor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if theme.Name != tt.want {
+				t.Errorf("ThemeFor() = %s, want %s", theme.Name, tt.want)
+			}
+			if got := reflect.DeepEqual(theme.Symbols, asciiSymbols); got != tt.wantASCII {
+				t.Errorf("ASCII symbols = %v, want %v", got, tt.wantASCII)
+			}
+		})
+	}
+}
+
+func TestASCIISymbols(t *testing.T) {
+	v := reflect.ValueOf(asciiSymbols)
+	for i := 0; i < v.NumField(); i++ {
+		var values []string
+		switch f := v.Field(i).Interface().(type) {
+		case string:
+			values = []string{f}
+		case []string:
+			values = f
+		}
+		for _, s := range values {
+			for _, r := range s {
+				if r > unicode.MaxASCII {
+					t.Errorf("%s contains non-ASCII %q", v.Type().Field(i).Name, r)
+				}
+			}
+		}
+	}
+}
@@ -2,50 +2,127 @@ package ui
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/ysaakpr/aws-term/internal/config"
 	"golang.org/x/term"
+	"rsc.io/qr"
 )
 
-const (
-	ColorReset  = "\033[0m"
-	ColorGreen  = "\033[32m"
-	ColorYellow = "\033[33m"
-	ColorBlue   = "\033[34m"
-	ColorCyan   = "\033[36m"
-	ColorBold   = "\033[1m"
-	ClearLine   = "\033[2K"
-	MoveUp      = "\033[1A"
-	HideCursor  = "\033[?25l"
-	ShowCursor  = "\033[?25h"
+// Escape codes for colors and cursor control. ConfigureTerminal empties
+// them when the terminal cannot use them.
+var (
+	ColorReset   = "\033[0m"
+	ColorRed     = "\033[31m"
+	ColorGreen   = "\033[32m"
+	ColorYellow  = "\033[33m"
+	ColorBlue    = "\033[34m"
+	ColorMagenta = "\033[35m"
+	ColorCyan    = "\033[36m"
+	ColorBold    = "\033[1m"
+	ClearLine    = "\033[2K"
+	MoveUp       = "\033[1A"
+	HideCursor   = "\033[?25l"
+	ShowCursor   = "\033[?25h"
+
+	// qrColors draws QR codes black on white whatever the terminal's theme
+	qrColors = "\033[30;47m"
 )
 
+// ErrCancelled is returned by the pickers when the user quits with q or Ctrl+C
+var ErrCancelled = errors.New("cancelled by user")
+
+// rawTerminal is the terminal a picker has put into raw mode, so it can be
+// restored if the program exits while the picker is open
+var rawTerminal struct {
+	sync.Mutex
+	fd    int
+	state *term.State
+}
+
+// makeRaw puts fd into raw mode and returns the function that restores it
+func makeRaw(fd int) (func(), error) {
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	rawTerminal.Lock()
+	rawTerminal.fd, rawTerminal.state = fd, state
+	rawTerminal.Unlock()
+
+	return RestoreTerminal, nil
+}
+
+// RestoreTerminal leaves raw mode and shows the cursor again if a picker is
+// open. It is safe to call at any time, e.g. before exiting on a signal.
+func RestoreTerminal() {
+	rawTerminal.Lock()
+	defer rawTerminal.Unlock()
+
+	if rawTerminal.state == nil {
+		return
+	}
+	term.Restore(rawTerminal.fd, rawTerminal.state)
+	rawTerminal.state = nil
+	fmt.Fprint(os.Stderr, ShowCursor)
+}
+
+// ColorByName returns the escape code for a color name such as "red",
+// or an empty string if the name is not known
+func ColorByName(name string) string {
+	switch strings.ToLower(name) {
+	case "red":
+		return ColorRed
+	case "green":
+		return ColorGreen
+	case "yellow":
+		return ColorYellow
+	case "blue":
+		return ColorBlue
+	case "magenta":
+		return ColorMagenta
+	case "cyan":
+		return ColorCyan
+	default:
+		return ""
+	}
+}
+
 // PrintHeader prints the application header
 func PrintHeader() {
-	fmt.Println()
-	fmt.Printf("%s%s╔══════════════════════════════════════════╗%s\n", ColorBold, ColorCyan, ColorReset)
-	fmt.Printf("%s%s║          AWS Terminal Session            ║%s\n", ColorBold, ColorCyan, ColorReset)
-	fmt.Printf("%s%s╚══════════════════════════════════════════╝%s\n", ColorBold, ColorCyan, ColorReset)
-	fmt.Println()
+	if quietMode {
+		return
+	}
+	fmt.Fprintln(os.Stderr)
+	const title, width = "AWS Terminal Session", 42
+	pad := (width - len(title)) / 2
+	sym := Symbols
+	fmt.Fprintf(os.Stderr, "%s%s%s%s%s%s\n", ColorBold, ColorCyan, sym.FrameTopLeft, strings.Repeat(sym.FrameHorizontal, width), sym.FrameTopRight, ColorReset)
+	fmt.Fprintf(os.Stderr, "%s%s%s%*s%s%*s%s%s\n", ColorBold, ColorCyan, sym.FrameVertical, pad, "", title, width-pad-len(title), "", sym.FrameVertical, ColorReset)
+	fmt.Fprintf(os.Stderr, "%s%s%s%s%s%s\n", ColorBold, ColorCyan, sym.FrameBottomLeft, strings.Repeat(sym.FrameHorizontal, width), sym.FrameBottomRight, ColorReset)
+	fmt.Fprintln(os.Stderr)
 }
 
 // PromptInput prompts the user for text input
 func PromptInput(prompt string) string {
 	reader := bufio.NewReader(os.Stdin)
-	fmt.Printf("%s%s%s: ", ColorYellow, prompt, ColorReset)
+	fmt.Fprintf(os.Stderr, "%s%s%s: ", ColorYellow, prompt, ColorReset)
 	input, _ := reader.ReadString('\n')
 	return strings.TrimSpace(input)
 }
 
 // PromptSSOUrl prompts the user for an AWS SSO URL
 func PromptSSOUrl() string {
-	fmt.Printf("\n%sNo AWS SSO configuration found.%s\n", ColorYellow, ColorReset)
-	fmt.Println("Please enter your AWS SSO start URL:")
-	fmt.Println("(e.g., https://my-company.awsapps.com/start)")
-	fmt.Println()
+	fmt.Fprintf(os.Stderr, "\n%sNo AWS SSO configuration found.%s\n", ColorYellow, ColorReset)
+	fmt.Fprintln(os.Stderr, "Please enter your AWS SSO start URL:")
+	fmt.Fprintln(os.Stderr, "(e.g., https://my-company.awsapps.com/start)")
+	fmt.Fprintln(os.Stderr)
 	return PromptInput("SSO URL")
 }
 
@@ -64,7 +141,7 @@ func SelectProfile(profiles []config.Profile) (*config.Profile, error) {
 		return &profiles[0], nil
 	}
 
-	fmt.Printf("\n%s%sSelect a profile:%s\n\n", ColorBold, ColorCyan, ColorReset)
+	fmt.Fprintf(os.Stderr, "\n%s%sSelect a profile:%s\n\n", ColorBold, ColorCyan, ColorReset)
 
 	selectedIndex := 0
 
@@ -78,19 +155,19 @@ func SelectProfile(profiles []config.Profile) (*config.Profile, error) {
 
 	// Try to enable raw mode for arrow key navigation
 	fd := int(os.Stdin.Fd())
-	if !term.IsTerminal(fd) {
+	if !interactive() {
 		return selectProfileFallback(profiles)
 	}
 
-	oldState, err := term.MakeRaw(fd)
+	restore, err := makeRaw(fd)
 	if err != nil {
 		return selectProfileFallback(profiles)
 	}
-	defer term.Restore(fd, oldState)
+	defer restore()
 
 	// Hide cursor during selection
-	fmt.Print(HideCursor)
-	defer fmt.Print(ShowCursor)
+	fmt.Fprint(os.Stderr, HideCursor)
+	defer fmt.Fprint(os.Stderr, ShowCursor)
 
 	for {
 		// Print profiles
@@ -101,15 +178,15 @@ func SelectProfile(profiles []config.Profile) (*config.Profile, error) {
 			}
 
 			if i == selectedIndex {
-				fmt.Printf("%s  ▸ %s%s%s\r\n", ClearLine, ColorBold, p.Name, ColorReset)
-				fmt.Printf("%s    %s%s%s%s\r\n", ClearLine, ColorBlue, p.SSOUrl, ColorReset, defaultMarker)
+				fmt.Fprintf(os.Stderr, "%s  %s %s%s%s\r\n", ClearLine, Symbols.Pointer, ColorBold, p.Name, ColorReset)
+				fmt.Fprintf(os.Stderr, "%s    %s%s%s%s\r\n", ClearLine, ColorBlue, p.SSOUrl, ColorReset, defaultMarker)
 			} else {
-				fmt.Printf("%s    %s\r\n", ClearLine, p.Name)
-				fmt.Printf("%s    %s%s%s%s\r\n", ClearLine, ColorBlue, p.SSOUrl, ColorReset, defaultMarker)
+				fmt.Fprintf(os.Stderr, "%s    %s\r\n", ClearLine, p.Name)
+				fmt.Fprintf(os.Stderr, "%s    %s%s%s%s\r\n", ClearLine, ColorBlue, p.SSOUrl, ColorReset, defaultMarker)
 			}
 		}
 
-		fmt.Printf("\r\n%sUse ↑/↓ arrows to navigate, Enter to select, q to quit%s\r\n", ColorYellow, ColorReset)
+		fmt.Fprintf(os.Stderr, "\r\n%sUse %s arrows to navigate, Enter to select, q to quit%s\r\n", ColorYellow, Symbols.Arrows, ColorReset)
 
 		// Read key
 		var buf [3]byte
@@ -120,19 +197,17 @@ func SelectProfile(profiles []config.Profile) (*config.Profile, error) {
 
 		// Move cursor up to redraw
 		for i := 0; i <= len(profiles)*2+1; i++ {
-			fmt.Print(MoveUp + ClearLine)
+			fmt.Fprint(os.Stderr, MoveUp+ClearLine)
 		}
 
 		if n == 1 {
 			switch buf[0] {
 			case 'q', 'Q', 3: // q, Q, or Ctrl+C
-				fmt.Print(ShowCursor)
-				term.Restore(fd, oldState)
-				fmt.Println("\r\nCancelled.")
-				os.Exit(0)
+				fmt.Fprint(os.Stderr, ShowCursor)
+				return nil, ErrCancelled
 			case 13, 10: // Enter (CR or LF)
-				fmt.Print(ShowCursor)
-				fmt.Printf("\r\n")
+				fmt.Fprint(os.Stderr, ShowCursor)
+				fmt.Fprintf(os.Stderr, "\r\n")
 				return &profiles[selectedIndex], nil
 			case 'j': // vim down
 				if selectedIndex < len(profiles)-1 {
@@ -160,18 +235,18 @@ func SelectProfile(profiles []config.Profile) (*config.Profile, error) {
 
 // selectProfileFallback is a fallback for when raw mode is not available
 func selectProfileFallback(profiles []config.Profile) (*config.Profile, error) {
-	fmt.Printf("\n%s%sAvailable profiles:%s\n\n", ColorBold, ColorCyan, ColorReset)
+	fmt.Fprintf(os.Stderr, "\n%s%sAvailable profiles:%s\n\n", ColorBold, ColorCyan, ColorReset)
 
 	for i, p := range profiles {
 		defaultMarker := ""
 		if p.Default {
 			defaultMarker = fmt.Sprintf(" %s(default)%s", ColorGreen, ColorReset)
 		}
-		fmt.Printf("  %d. %s%s\n", i+1, p.Name, defaultMarker)
-		fmt.Printf("     %s%s%s\n", ColorBlue, p.SSOUrl, ColorReset)
+		fmt.Fprintf(os.Stderr, "  %d. %s%s\n", i+1, p.Name, defaultMarker)
+		fmt.Fprintf(os.Stderr, "     %s%s%s\n", ColorBlue, p.SSOUrl, ColorReset)
 	}
 
-	fmt.Println()
+	fmt.Fprintln(os.Stderr)
 	input := PromptInput("Enter profile number")
 
 	var index int
@@ -190,41 +265,41 @@ func SelectBrowser(browsers []string) (string, error) {
 	}
 
 	if len(browsers) == 1 {
-		fmt.Printf("%sUsing %s...%s\n", ColorCyan, browsers[0], ColorReset)
+		fmt.Fprintf(os.Stderr, "%sUsing %s...%s\n", ColorCyan, browsers[0], ColorReset)
 		return browsers[0], nil
 	}
 
-	fmt.Printf("\n%s%sSelect a browser:%s\n\n", ColorBold, ColorCyan, ColorReset)
+	fmt.Fprintf(os.Stderr, "\n%s%sSelect a browser:%s\n\n", ColorBold, ColorCyan, ColorReset)
 
 	selectedIndex := 0
 
 	// Try to enable raw mode for arrow key navigation
 	fd := int(os.Stdin.Fd())
-	if !term.IsTerminal(fd) {
+	if !interactive() {
 		return selectBrowserFallback(browsers)
 	}
 
-	oldState, err := term.MakeRaw(fd)
+	restore, err := makeRaw(fd)
 	if err != nil {
 		return selectBrowserFallback(browsers)
 	}
-	defer term.Restore(fd, oldState)
+	defer restore()
 
 	// Hide cursor during selection
-	fmt.Print(HideCursor)
-	defer fmt.Print(ShowCursor)
+	fmt.Fprint(os.Stderr, HideCursor)
+	defer fmt.Fprint(os.Stderr, ShowCursor)
 
 	for {
 		// Print browsers
 		for i, b := range browsers {
 			if i == selectedIndex {
-				fmt.Printf("%s  ▸ %s%s%s\r\n", ClearLine, ColorBold, b, ColorReset)
+				fmt.Fprintf(os.Stderr, "%s  %s %s%s%s\r\n", ClearLine, Symbols.Pointer, ColorBold, b, ColorReset)
 			} else {
-				fmt.Printf("%s    %s\r\n", ClearLine, b)
+				fmt.Fprintf(os.Stderr, "%s    %s\r\n", ClearLine, b)
 			}
 		}
 
-		fmt.Printf("\r\n%sUse ↑/↓ arrows to navigate, Enter to select%s\r\n", ColorYellow, ColorReset)
+		fmt.Fprintf(os.Stderr, "\r\n%sUse %s arrows to navigate, Enter to select%s\r\n", ColorYellow, Symbols.Arrows, ColorReset)
 
 		// Read key
 		var buf [3]byte
@@ -235,19 +310,17 @@ func SelectBrowser(browsers []string) (string, error) {
 
 		// Move cursor up to redraw
 		for i := 0; i <= len(browsers)+1; i++ {
-			fmt.Print(MoveUp + ClearLine)
+			fmt.Fprint(os.Stderr, MoveUp+ClearLine)
 		}
 
 		if n == 1 {
 			switch buf[0] {
 			case 'q', 'Q', 3: // q, Q, or Ctrl+C
-				fmt.Print(ShowCursor)
-				term.Restore(fd, oldState)
-				fmt.Println("\r\nCancelled.")
-				os.Exit(0)
+				fmt.Fprint(os.Stderr, ShowCursor)
+				return "", ErrCancelled
 			case 13, 10: // Enter (CR or LF)
-				fmt.Print(ShowCursor)
-				fmt.Printf("\r\n")
+				fmt.Fprint(os.Stderr, ShowCursor)
+				fmt.Fprintf(os.Stderr, "\r\n")
 				return browsers[selectedIndex], nil
 			case 'j': // vim down
 				if selectedIndex < len(browsers)-1 {
@@ -276,10 +349,10 @@ func SelectBrowser(browsers []string) (string, error) {
 // selectBrowserFallback is a fallback for when raw mode is not available
 func selectBrowserFallback(browsers []string) (string, error) {
 	for i, b := range browsers {
-		fmt.Printf("  %d. %s\n", i+1, b)
+		fmt.Fprintf(os.Stderr, "  %d. %s\n", i+1, b)
 	}
 
-	fmt.Println()
+	fmt.Fprintln(os.Stderr)
 	input := PromptInput("Enter browser number")
 
 	var index int
@@ -297,35 +370,146 @@ func ConfirmSetDefault() bool {
 	return strings.ToLower(input) == "y" || strings.ToLower(input) == "yes"
 }
 
+// ConfirmTyped asks the user to type expected to confirm a dangerous action
+func ConfirmTyped(prompt, expected string) bool {
+	input := PromptInput(prompt)
+	return input == expected
+}
+
+// PrintWarning prints a warning message
+func PrintWarning(message string) {
+	fmt.Fprintf(os.Stderr, "\n%s%s%s %s%s\n", ColorBold, ColorRed, Symbols.Warning, message, ColorReset)
+}
+
 // PrintSuccess prints a success message
 func PrintSuccess(message string) {
-	fmt.Printf("\n%s✓ %s%s\n", ColorGreen, message, ColorReset)
+	if quietMode {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\n%s%s %s%s\n", ColorGreen, Symbols.Success, message, ColorReset)
 }
 
+// lastError is the message most recently passed to PrintError
+var lastError atomic.Value
+
 // PrintError prints an error message
 func PrintError(message string) {
-	fmt.Printf("\n%s✗ %s%s\n", "\033[31m", message, ColorReset)
+	lastError.Store(message)
+	fmt.Fprintf(os.Stderr, "\n%s%s %s%s\n", ColorRed, Symbols.Failure, message, ColorReset)
+}
+
+// LastError returns the message most recently passed to PrintError, or ""
+func LastError() string {
+	message, _ := lastError.Load().(string)
+	return message
 }
 
 // PrintInfo prints an info message
 func PrintInfo(message string) {
-	fmt.Printf("%s%s%s\n", ColorCyan, message, ColorReset)
+	if quietMode {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s%s%s\n", ColorCyan, message, ColorReset)
+}
+
+// PrintQRCode renders text as a QR code using Unicode half blocks, so each
+// character cell holds two rows of modules. The code is drawn black on white
+// with a quiet zone so it scans on both dark and light terminal themes.
+func PrintQRCode(text string) error {
+	code, err := qr.Encode(text, qr.L)
+	if err != nil {
+		return fmt.Errorf("failed to encode QR code: %w", err)
+	}
+
+	const quiet = 4
+	black := func(x, y int) bool {
+		x, y = x-quiet, y-quiet
+		if x < 0 || y < 0 || x >= code.Size || y >= code.Size {
+			return false
+		}
+		return code.Black(x, y)
+	}
+
+	size := code.Size + 2*quiet
+	if !Symbols.HalfBlocks {
+		printQRCodeFull(size, black)
+		return nil
+	}
+	for y := 0; y < size; y += 2 {
+		var sb strings.Builder
+		sb.WriteString("  " + qrColors)
+		for x := 0; x < size; x++ {
+			top, bottom := black(x, y), black(x, y+1)
+			switch {
+			case top && bottom:
+				sb.WriteString("█")
+			case top:
+				sb.WriteString("▀")
+			case bottom:
+				sb.WriteString("▄")
+			default:
+				sb.WriteString(" ")
+			}
+		}
+		sb.WriteString(ColorReset)
+		fmt.Fprintln(os.Stderr, sb.String())
+	}
+	return nil
+}
+
+// printQRCodeFull draws each module as two character cells, for themes
+// without half blocks: background colors when colors are on, # otherwise
+func printQRCodeFull(size int, black func(x, y int) bool) {
+	dark, light := "##", "  "
+	if qrColors != "" {
+		dark, light = "\033[40m  ", "\033[47m  "
+	}
+	for y := 0; y < size; y++ {
+		var sb strings.Builder
+		sb.WriteString("  ")
+		for x := 0; x < size; x++ {
+			if black(x, y) {
+				sb.WriteString(dark)
+			} else {
+				sb.WriteString(light)
+			}
+		}
+		sb.WriteString(ColorReset)
+		fmt.Fprintln(os.Stderr, sb.String())
+	}
 }
 
 // PrintCredentials prints the export commands for the user
 func PrintCredentials(accessKeyId, secretAccessKey, sessionToken string) {
-	fmt.Println()
-	fmt.Printf("%s%sAWS Credentials obtained successfully!%s\n", ColorBold, ColorGreen, ColorReset)
-	fmt.Println()
-	fmt.Printf("%sCopy and paste these commands to set your environment:%s\n\n", ColorYellow, ColorReset)
-	fmt.Printf("export AWS_ACCESS_KEY_ID=%s\n", accessKeyId)
-	fmt.Printf("export AWS_SECRET_ACCESS_KEY=%s\n", secretAccessKey)
-	fmt.Printf("export AWS_SESSION_TOKEN=%s\n", sessionToken)
-	fmt.Println()
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintf(os.Stderr, "%s%sAWS Credentials obtained successfully!%s\n", ColorBold, ColorGreen, ColorReset)
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintf(os.Stderr, "%sCopy and paste these commands to set your environment:%s\n\n", ColorYellow, ColorReset)
+	fmt.Fprintf(os.Stderr, "export AWS_ACCESS_KEY_ID=%s\n", accessKeyId)
+	fmt.Fprintf(os.Stderr, "export AWS_SECRET_ACCESS_KEY=%s\n", secretAccessKey)
+	fmt.Fprintf(os.Stderr, "export AWS_SESSION_TOKEN=%s\n", sessionToken)
+	fmt.Fprintln(os.Stderr)
+}
+
+// Section is a titled group of items shown together in a picker
+type Section struct {
+	Title string
+	Items []string
 }
 
 // SelectFromList displays a generic list and allows the user to select one item
 func SelectFromList(title string, items []string) (int, error) {
+	return SelectFromSections(title, []Section{{Items: items}})
+}
+
+// SelectFromSections displays items grouped under section headings and allows
+// the user to select one. The returned index counts items across all sections.
+func SelectFromSections(title string, sections []Section) (int, error) {
+	var items []string
+	for _, sec := range sections {
+		items = append(items, sec.Items...)
+	}
+
 	if len(items) == 0 {
 		return -1, fmt.Errorf("no items available")
 	}
@@ -334,37 +518,50 @@ func SelectFromList(title string, items []string) (int, error) {
 		return 0, nil
 	}
 
-	fmt.Printf("\n%s%s%s%s\n\n", ColorBold, ColorCyan, title, ColorReset)
+	fmt.Fprintf(os.Stderr, "\n%s%s%s%s\n\n", ColorBold, ColorCyan, title, ColorReset)
 
 	selectedIndex := 0
 
 	// Try to enable raw mode for arrow key navigation
 	fd := int(os.Stdin.Fd())
-	if !term.IsTerminal(fd) {
-		return selectFromListFallback(items)
+	if !interactive() {
+		return selectFromListFallback(sections)
 	}
 
-	oldState, err := term.MakeRaw(fd)
+	restore, err := makeRaw(fd)
 	if err != nil {
-		return selectFromListFallback(items)
+		return selectFromListFallback(sections)
 	}
-	defer term.Restore(fd, oldState)
+	defer restore()
 
 	// Hide cursor during selection
-	fmt.Print(HideCursor)
-	defer fmt.Print(ShowCursor)
+	fmt.Fprint(os.Stderr, HideCursor)
+	defer fmt.Fprint(os.Stderr, ShowCursor)
 
 	for {
-		// Print items
-		for i, item := range items {
-			if i == selectedIndex {
-				fmt.Printf("%s  ▸ %s%s%s\r\n", ClearLine, ColorBold, item, ColorReset)
-			} else {
-				fmt.Printf("%s    %s\r\n", ClearLine, item)
+		// Print items, with a heading above each titled section
+		lines := 0
+		i := 0
+		for _, sec := range sections {
+			if len(sec.Items) == 0 {
+				continue
+			}
+			if sec.Title != "" {
+				fmt.Fprintf(os.Stderr, "%s  %s%s%s\r\n", ClearLine, ColorBlue, sec.Title, ColorReset)
+				lines++
+			}
+			for _, item := range sec.Items {
+				if i == selectedIndex {
+					fmt.Fprintf(os.Stderr, "%s  %s %s%s%s\r\n", ClearLine, Symbols.Pointer, ColorBold, item, ColorReset)
+				} else {
+					fmt.Fprintf(os.Stderr, "%s    %s\r\n", ClearLine, item)
+				}
+				lines++
+				i++
 			}
 		}
 
-		fmt.Printf("\r\n%sUse ↑/↓ arrows to navigate, Enter to select, q to quit%s\r\n", ColorYellow, ColorReset)
+		fmt.Fprintf(os.Stderr, "\r\n%sUse %s arrows to navigate, Enter to select, q to quit%s\r\n", ColorYellow, Symbols.Arrows, ColorReset)
 
 		// Read key
 		var buf [3]byte
@@ -374,20 +571,18 @@ func SelectFromList(title string, items []string) (int, error) {
 		}
 
 		// Move cursor up to redraw
-		for i := 0; i <= len(items)+1; i++ {
-			fmt.Print(MoveUp + ClearLine)
+		for i := 0; i <= lines+1; i++ {
+			fmt.Fprint(os.Stderr, MoveUp+ClearLine)
 		}
 
 		if n == 1 {
 			switch buf[0] {
 			case 'q', 'Q', 3: // q, Q, or Ctrl+C
-				fmt.Print(ShowCursor)
-				term.Restore(fd, oldState)
-				fmt.Println("\r\nCancelled.")
-				os.Exit(0)
+				fmt.Fprint(os.Stderr, ShowCursor)
+				return -1, ErrCancelled
 			case 13, 10: // Enter (CR or LF)
-				fmt.Print(ShowCursor)
-				fmt.Printf("\r\n")
+				fmt.Fprint(os.Stderr, ShowCursor)
+				fmt.Fprintf(os.Stderr, "\r\n")
 				return selectedIndex, nil
 			case 'j': // vim down
 				if selectedIndex < len(items)-1 {
@@ -414,17 +609,27 @@ func SelectFromList(title string, items []string) (int, error) {
 }
 
 // selectFromListFallback is a fallback for when raw mode is not available
-func selectFromListFallback(items []string) (int, error) {
-	for i, item := range items {
-		fmt.Printf("  %d. %s\n", i+1, item)
+func selectFromListFallback(sections []Section) (int, error) {
+	i := 0
+	for _, sec := range sections {
+		if len(sec.Items) == 0 {
+			continue
+		}
+		if sec.Title != "" {
+			fmt.Fprintf(os.Stderr, "  %s%s%s\n", ColorBlue, sec.Title, ColorReset)
+		}
+		for _, item := range sec.Items {
+			fmt.Fprintf(os.Stderr, "  %d. %s\n", i+1, item)
+			i++
+		}
 	}
 
-	fmt.Println()
+	fmt.Fprintln(os.Stderr)
 	input := PromptInput("Enter number")
 
 	var index int
 	_, err := fmt.Sscanf(input, "%d", &index)
-	if err != nil || index < 1 || index > len(items) {
+	if err != nil || index < 1 || index > i {
 		return -1, fmt.Errorf("invalid selection")
 	}
 